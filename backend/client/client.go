@@ -0,0 +1,684 @@
+// Пакет client - клієнт API бекенда youtubemeter. Методи відповідають операціям специфікації
+// backend/server/openapi.json (operationId), при зміні специфікації клієнт оновлюється разом з нею.
+//
+//	c := client.New("http://localhost:3000", token)
+//	metrics, err := c.GetMetrics(ctx, videoId, from, to)
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DEFAULT_TIMEOUT = time.Second * 30
+
+type Client struct {
+	// Адреса бекенда, наприклад "http://localhost:3000"
+	BaseURL string
+
+	// Токен для авторизації Bearer, пустий - без авторизації
+	Token string
+
+	// Ім'я та пароль для авторизації Basic, використовуються, якщо Token пустий
+	User     string
+	Password string
+
+	HTTPClient *http.Client
+}
+
+// Помилка, яку повернув бекенд
+type Error struct {
+	// HTTP-статус відповіді
+	Status int
+
+	// Машинний код помилки: bad_request, unauthorized, forbidden, not_found, conflict, internal, unavailable
+	Code string `json:"code"`
+
+	Message string `json:"message"`
+
+	// id запиту, за ним помилку можна знайти в лозі бекенда
+	RequestId string `json:"request_id"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v %v: %v (request_id: %v)", e.Status, e.Code, e.Message, e.RequestId)
+}
+
+// Новий клієнт з авторизацією токеном, token може бути пустим
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: baseURL, Token: token, HTTPClient: &http.Client{Timeout: DEFAULT_TIMEOUT}}
+}
+
+// Виконати запит. in - тіло запиту (nil - без тіла), out - куди розібрати відповідь (nil - відповідь не потрібна)
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	resp, err := c.send(ctx, method, path, query, in, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Відправити запит та перевірити статус відповіді. Тіло успішної відповіді закриває той, хто викликав
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, in interface{}, accept string) (
+	*http.Response, error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", accept)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.User != "" {
+		req.SetBasicAuth(c.User, c.Password)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		apiErr := &Error{Status: resp.StatusCode}
+		b, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(b, apiErr) != nil || apiErr.Code == "" {
+			apiErr.Message = string(b)
+		}
+		return nil, apiErr
+	}
+
+	return resp, nil
+}
+
+// Експорт у форматі format (FORMAT_CSV, FORMAT_NDJSON, FORMAT_XLSX, FORMAT_JSON). Повертає тіло відповіді,
+// яке треба закрити після читання
+func (c *Client) export(ctx context.Context, path string, query url.Values, format string, raw bool) (
+	io.ReadCloser, error) {
+	query.Set("format", format)
+	if raw {
+		query.Set("raw", "true")
+	}
+
+	resp, err := c.send(ctx, "GET", path, query, nil, "*/*")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Параметри запиту: період, заданий мілісекундами, нульовий час - не обмежений
+func periodQuery(from, to time.Time) url.Values {
+	q := url.Values{}
+	if !from.IsZero() {
+		q.Set("from", strconv.FormatInt(from.UnixNano()/int64(time.Millisecond), 10))
+	}
+	if !to.IsZero() {
+		q.Set("to", strconv.FormatInt(to.UnixNano()/int64(time.Millisecond), 10))
+	}
+	return q
+}
+
+func skipQuery(skip int) url.Values {
+	q := url.Values{}
+	if skip > 0 {
+		q.Set("skip", strconv.Itoa(skip))
+	}
+	return q
+}
+
+// Активні плейлисти
+func (c *Client) GetPlaylists(ctx context.Context) (*ResponcePlayList, error) {
+	var out ResponcePlayList
+	if err := c.do(ctx, "GET", "/playlists", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Всі плейлисти (роль editor)
+func (c *Client) GetPlaylistsAdmin(ctx context.Context) (*ResponcePlayList, error) {
+	var out ResponcePlayList
+	if err := c.do(ctx, "GET", "/playlists/admin", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Додати плейлист (роль editor)
+func (c *Client) AppendPlaylist(ctx context.Context, playlist *PlayList) error {
+	return c.do(ctx, "POST", "/playlists/admin", nil, playlist, nil)
+}
+
+// Оновити плейлист (роль editor)
+func (c *Client) UpdatePlaylist(ctx context.Context, id string, playlist *PlayList) error {
+	return c.do(ctx, "PUT", "/playlists/admin/"+url.PathEscape(id), nil, playlist, nil)
+}
+
+// Видалити плейлист - перенести в архів (роль editor)
+func (c *Client) DeletePlaylist(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/playlists/admin/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// Повернути плейлист з архіву (роль editor)
+func (c *Client) RestorePlaylist(ctx context.Context, id string) error {
+	return c.do(ctx, "POST", "/playlists/admin/"+url.PathEscape(id)+"/restore", nil, nil, nil)
+}
+
+// Остаточно видалити архівний плейлист з відео, метриками та коментарями (роль admin).
+// dryRun - тільки порахувати рядки, які будуть видалені
+func (c *Client) PurgePlaylist(ctx context.Context, id string, dryRun bool) (*ResponcePurge, error) {
+	q := url.Values{}
+	q.Set("dryrun", strconv.FormatBool(dryRun))
+
+	var out ResponcePurge
+	if err := c.do(ctx, "POST", "/playlists/admin/"+url.PathEscape(id)+"/purge", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Всі запити, що відстежуються (роль editor)
+func (c *Client) GetQueriesAdmin(ctx context.Context) ([]Query, error) {
+	var out []Query
+	err := c.do(ctx, "GET", "/queries/admin", nil, nil, &out)
+	return out, err
+}
+
+// Додати запит (роль editor)
+func (c *Client) AppendQuery(ctx context.Context, query *Query) error {
+	return c.do(ctx, "POST", "/queries/admin", nil, query, nil)
+}
+
+// Оновити запит (роль editor)
+func (c *Client) UpdateQuery(ctx context.Context, id int64, query *Query) error {
+	return c.do(ctx, "PUT", "/queries/admin/"+strconv.FormatInt(id, 10), nil, query, nil)
+}
+
+// Видалити запит разом з історією позицій (роль editor)
+func (c *Client) DeleteQuery(ctx context.Context, id int64) error {
+	return c.do(ctx, "DELETE", "/queries/admin/"+strconv.FormatInt(id, 10), nil, nil, nil)
+}
+
+// Перечитати налаштування бекенда (роль admin)
+func (c *Client) ReloadConfig(ctx context.Context) (*ResponceReload, error) {
+	var out ResponceReload
+	if err := c.do(ctx, "POST", "/admin/config/reload", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Користувачі API (роль admin)
+func (c *Client) GetUsers(ctx context.Context) ([]ApiUser, error) {
+	var out []ApiUser
+	err := c.do(ctx, "GET", "/admin/users", nil, nil, &out)
+	return out, err
+}
+
+// Додати користувача API, повертає його токен. Існуючий користувач не змінюється - помилка 409 (роль admin)
+func (c *Client) AppendUser(ctx context.Context, user *ApiUser) (*ResponceApiUser, error) {
+	var out ResponceApiUser
+	if err := c.do(ctx, "POST", "/admin/users", nil, user, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Видати користувачу API новий токен, попередній перестає діяти (роль admin)
+func (c *Client) UpdateUserToken(ctx context.Context, name string) (*ResponceApiUser, error) {
+	var out ResponceApiUser
+	if err := c.do(ctx, "POST", "/admin/users/"+url.PathEscape(name)+"/token", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Видалити користувача API (роль admin)
+func (c *Client) DeleteUser(ctx context.Context, name string) error {
+	return c.do(ctx, "DELETE", "/admin/users/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// Журнал аудиту змін за період, користувачем та плейлистом, від новіших до старіших (роль admin).
+// Нульовий час, пустий рядок - без обмеження, limit = 0 - кількість записів за замовчуванням (100)
+func (c *Client) GetAudit(ctx context.Context, from, to time.Time, actor, playlistId string, limit int) (
+	[]AuditRecord, error) {
+	q := periodQuery(from, to)
+	if actor != "" {
+		q.Set("actor", actor)
+	}
+	if playlistId != "" {
+		q.Set("idpl", playlistId)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	var out []AuditRecord
+	err := c.do(ctx, "GET", "/admin/audit", q, nil, &out)
+	return out, err
+}
+
+// Глобальні лічильники та налаштування
+func (c *Client) GetGlobalCounts(ctx context.Context) (*GlobalCounts, error) {
+	var out GlobalCounts
+	if err := c.do(ctx, "GET", "/view/counts", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Останні відео активних плейлистів
+func (c *Client) GetVideos(ctx context.Context, skip int) ([]YoutubeVideoShort, error) {
+	var out []YoutubeVideoShort
+	err := c.do(ctx, "GET", "/view/videos", skipQuery(skip), nil, &out)
+	return out, err
+}
+
+// Фільтри та сортування списку відео. Пусті рядки, нульовий час та nil - без обмеження
+type VideoFilter struct {
+	Sort string
+
+	// Період публікації
+	From time.Time
+	To   time.Time
+
+	ChannelId string
+
+	// Межі останньої кількості переглядів
+	MinViews *int64
+	MaxViews *int64
+
+	Status string
+}
+
+// Відео з фільтрами та сортуванням, playlistId = "" - відео всіх активних плейлистів
+func (c *Client) GetVideosFiltered(ctx context.Context, playlistId string, filter *VideoFilter, skip int) (
+	[]YoutubeVideoShort, error) {
+	q := filter.query()
+	if skip > 0 {
+		q.Set("skip", strconv.Itoa(skip))
+	}
+
+	var out []YoutubeVideoShort
+	err := c.do(ctx, "GET", videosPath(playlistId), q, nil, &out)
+	return out, err
+}
+
+// Сторінка відео за курсором (cursor = "" - перша сторінка, далі - NextCursor попередньої), limit = 0 - розмір
+// сторінки за замовчуванням. Курсор можливий тільки з сортуванням SORT_PUBLISHED (або без сортування)
+func (c *Client) GetVideosPage(ctx context.Context, playlistId string, filter *VideoFilter, cursor string, limit int) (
+	*ResponceVideos, error) {
+	q := filter.query()
+	q.Set("cursor", cursor)
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	var out ResponceVideos
+	if err := c.do(ctx, "GET", videosPath(playlistId), q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Шлях списку відео, playlistId = "" - відео всіх активних плейлистів
+func videosPath(playlistId string) string {
+	if playlistId == "" {
+		return "/view/videos"
+	}
+	return "/view/videos/" + url.PathEscape(playlistId)
+}
+
+// Параметри запиту фільтрів списку відео
+func (filter *VideoFilter) query() url.Values {
+	q := periodQuery(filter.From, filter.To)
+	if filter.Sort != "" {
+		q.Set("sort", filter.Sort)
+	}
+	if filter.ChannelId != "" {
+		q.Set("chid", filter.ChannelId)
+	}
+	if filter.MinViews != nil {
+		q.Set("minviews", strconv.FormatInt(*filter.MinViews, 10))
+	}
+	if filter.MaxViews != nil {
+		q.Set("maxviews", strconv.FormatInt(*filter.MaxViews, 10))
+	}
+	if filter.Status != "" {
+		q.Set("status", filter.Status)
+	}
+	return q
+}
+
+// Останні відео плейлиста
+func (c *Client) GetPlaylistVideos(ctx context.Context, playlistId string, skip int) ([]YoutubeVideoShort, error) {
+	var out []YoutubeVideoShort
+	err := c.do(ctx, "GET", "/view/videos/"+url.PathEscape(playlistId), skipQuery(skip), nil, &out)
+	return out, err
+}
+
+// Експорт списку відео, playlistId = "" - відео всіх активних плейлистів, raw = true - всі відео без обмеження
+// кількості, skip при цьому не враховується
+func (c *Client) ExportVideos(ctx context.Context, playlistId string, skip int, format string, raw bool) (
+	io.ReadCloser, error) {
+	path := "/view/videos"
+	if playlistId != "" {
+		path += "/" + url.PathEscape(playlistId)
+	}
+	return c.export(ctx, path, skipQuery(skip), format, raw)
+}
+
+// Порівняти відео ids за віком. age та step = 0 - за замовчуванням
+func (c *Client) CompareVideos(ctx context.Context, ids []string, age, step time.Duration) (*ResponceCompare, error) {
+	q := compareQuery(age, step)
+	q.Set("ids", strings.Join(ids, ","))
+	return c.compare(ctx, q)
+}
+
+// Порівняти count останніх відео плейлиста за віком. count, age та step = 0 - за замовчуванням
+func (c *Client) ComparePlaylist(ctx context.Context, playlistId string, count int, age, step time.Duration) (
+	*ResponceCompare, error) {
+	q := compareQuery(age, step)
+	q.Set("playlist", playlistId)
+	if count > 0 {
+		q.Set("count", strconv.Itoa(count))
+	}
+	return c.compare(ctx, q)
+}
+
+func compareQuery(age, step time.Duration) url.Values {
+	q := url.Values{}
+	if age > 0 {
+		q.Set("age", age.String())
+	}
+	if step > 0 {
+		q.Set("step", step.String())
+	}
+	return q
+}
+
+func (c *Client) compare(ctx context.Context, q url.Values) (*ResponceCompare, error) {
+	var out ResponceCompare
+	if err := c.do(ctx, "GET", "/view/compare", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Типові показники плейлиста: перцентилі переглядів його останніх відео для кожного віку
+func (c *Client) GetBaseline(ctx context.Context, playlistId string) (*ResponceBaseline, error) {
+	var out ResponceBaseline
+	if err := c.do(ctx, "GET", "/view/baseline/"+url.PathEscape(playlistId), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Опис відео
+func (c *Client) GetVideo(ctx context.Context, id string) (*YoutubeVideo, error) {
+	var out YoutubeVideo
+	if err := c.do(ctx, "GET", "/view/video/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Метрики відео за період, нульовий час - період не обмежений
+func (c *Client) GetMetrics(ctx context.Context, id string, from, to time.Time) ([]Metrics, error) {
+	var out []Metrics
+	err := c.do(ctx, "GET", "/view/metrics/"+url.PathEscape(id), periodQuery(from, to), nil, &out)
+	return out, err
+}
+
+// Метрики відео за період, проріджені до points точок алгоритмом algo (ALGO_LTTB, ALGO_MINMAX, ALGO_AVG).
+// points = 0 та algo = "" - за замовчуванням
+func (c *Client) GetMetricsDownsampled(ctx context.Context, id string, from, to time.Time, points int, algo string) (
+	[]Metrics, error) {
+	q := periodQuery(from, to)
+	if points > 0 {
+		q.Set("points", strconv.Itoa(points))
+	}
+	if algo != "" {
+		q.Set("algo", algo)
+	}
+
+	var out []Metrics
+	err := c.do(ctx, "GET", "/view/metrics/"+url.PathEscape(id), q, nil, &out)
+	return out, err
+}
+
+// Похідні метрики відео за період: прирости, швидкості за годину, залученість та пік переглядів.
+// interval - мінімальний інтервал між вимірами, 0 - за замовчуванням (1h)
+func (c *Client) GetDerivedMetrics(ctx context.Context, id string, interval time.Duration, from, to time.Time) (
+	*ResponceDerivedMetrics, error) {
+	q := periodQuery(from, to)
+	if interval > 0 {
+		q.Set("interval", interval.String())
+	}
+
+	var out ResponceDerivedMetrics
+	if err := c.do(ctx, "GET", "/view/metrics/"+url.PathEscape(id)+"/derived", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Експорт метрик відео за період, raw = true - всі збережені метрики без проріджування
+func (c *Client) ExportMetrics(ctx context.Context, id string, from, to time.Time, format string, raw bool) (
+	io.ReadCloser, error) {
+	return c.export(ctx, "/view/metrics/"+url.PathEscape(id), periodQuery(from, to), format, raw)
+}
+
+// Кількість коментарів відео за інтервали, interval = 0 - за замовчуванням (1h)
+func (c *Client) GetCommentRate(ctx context.Context, id string, interval time.Duration, from, to time.Time) (
+	*ResponceCommentRate, error) {
+	q := periodQuery(from, to)
+	if interval > 0 {
+		q.Set("interval", interval.String())
+	}
+
+	var out ResponceCommentRate
+	if err := c.do(ctx, "GET", "/view/comments/"+url.PathEscape(id)+"/rate", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Найпопулярніші коментарі відео, limit = 0 - за замовчуванням (10)
+func (c *Client) GetTopComments(ctx context.Context, id string, limit int) ([]Comment, error) {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	var out []Comment
+	err := c.do(ctx, "GET", "/view/comments/"+url.PathEscape(id)+"/top", q, nil, &out)
+	return out, err
+}
+
+// Параметри пошуку відео. Пусті рядки, нульовий час та нульові числа - без обмеження (за замовчуванням)
+type SearchParams struct {
+	// Текст у форматі websearch: слова, "фраза", or, -слово
+	Text string
+
+	PlaylistId string
+	ChannelId  string
+
+	// Період публікації
+	From time.Time
+	To   time.Time
+
+	Limit int
+	Skip  int
+}
+
+// Повнотекстовий пошук по назві та опису відео, результати впорядковані за релевантністю
+func (c *Client) SearchVideos(ctx context.Context, params *SearchParams) ([]SearchResult, error) {
+	q := periodQuery(params.From, params.To)
+	q.Set("q", params.Text)
+	if params.PlaylistId != "" {
+		q.Set("idpl", params.PlaylistId)
+	}
+	if params.ChannelId != "" {
+		q.Set("chid", params.ChannelId)
+	}
+	if params.Limit > 0 {
+		q.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Skip > 0 {
+		q.Set("skip", strconv.Itoa(params.Skip))
+	}
+
+	var out []SearchResult
+	err := c.do(ctx, "GET", "/view/search", q, nil, &out)
+	return out, err
+}
+
+// Рейтинг відео за приростом метрики за вікно. Пусті period та metric - 24h та views, playlistId = "" - відео
+// всіх активних плейлистів, limit = 0 - за замовчуванням (20)
+func (c *Client) GetTop(ctx context.Context, period, metric, playlistId string, limit int) (*ResponceTop, error) {
+	q := url.Values{}
+	if period != "" {
+		q.Set("period", period)
+	}
+	if metric != "" {
+		q.Set("metric", metric)
+	}
+	if playlistId != "" {
+		q.Set("idpl", playlistId)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	var out ResponceTop
+	if err := c.do(ctx, "GET", "/view/top", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Живий потік подій відео videos та відео плейлистів playlists. since - час в мілісекундах, з якого повторити
+// пропущені події (0 - без повторення). Кожна подія передається в handler, потік читається, поки бекенд його
+// не закриє, не скасується ctx або handler не поверне помилку. Повертає id останньої отриманої події, з яким
+// потік відновлюється (події з цим часом повторюються). Загальний таймаут HTTPClient до потоку не застосовується
+func (c *Client) Stream(ctx context.Context, videos, playlists []string, since int64,
+	handler func(*StreamEvent) error) (int64, error) {
+	q := url.Values{}
+	if len(videos) > 0 {
+		q.Set("videos", strings.Join(videos, ","))
+	}
+	if len(playlists) > 0 {
+		q.Set("playlists", strings.Join(playlists, ","))
+	}
+	if since > 0 {
+		q.Set("since", strconv.FormatInt(since, 10))
+	}
+
+	stream := *c
+	if c.HTTPClient != nil {
+		httpClient := *c.HTTPClient
+		httpClient.Timeout = 0
+		stream.HTTPClient = &httpClient
+	}
+
+	resp, err := stream.send(ctx, "GET", "/view/stream", q, nil, "text/event-stream")
+	if err != nil {
+		return since, err
+	}
+	defer resp.Body.Close()
+
+	// Рядки події: id, event, data; пустий рядок завершує подію, рядки з ":" - heartbeat
+	event := &StreamEvent{}
+	var data string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			event.Id, _ = strconv.ParseInt(strings.TrimPrefix(line, "id: "), 10, 64)
+		case strings.HasPrefix(line, "event: "):
+			event.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && data != "":
+			switch event.Event {
+			case STREAM_EVENT_METRIC:
+				event.Metric = &StreamMetric{}
+				err = json.Unmarshal([]byte(data), event.Metric)
+			case STREAM_EVENT_VIDEO:
+				event.Video = &StreamVideo{}
+				err = json.Unmarshal([]byte(data), event.Video)
+			}
+			if err != nil {
+				return since, err
+			}
+			if err = handler(event); err != nil {
+				return since, err
+			}
+			since = event.Id
+			event, data = &StreamEvent{}, ""
+		}
+	}
+	return since, scanner.Err()
+}
+
+// Активні запити, що відстежуються
+func (c *Client) GetQueries(ctx context.Context) ([]Query, error) {
+	var out []Query
+	err := c.do(ctx, "GET", "/view/queries", nil, nil, &out)
+	return out, err
+}
+
+// Історія позицій відео в результатах запиту за період
+func (c *Client) GetQueryRanks(ctx context.Context, id int64, from, to time.Time) (*ResponceQueryRanks, error) {
+	var out ResponceQueryRanks
+	if err := c.do(ctx, "GET", "/view/queries/"+strconv.FormatInt(id, 10)+"/ranks", periodQuery(from, to), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Специфікація API (openapi.json)
+func (c *Client) GetOpenApi(ctx context.Context) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, "GET", "/openapi.json", nil, nil, &out)
+	return out, err
+}
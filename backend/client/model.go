@@ -0,0 +1,459 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Типи відповідають схемам openapi.json (components/schemas) та структурам backend/server/model.go
+
+type PlayList struct {
+	// Id плейлиста YouTube
+	Id string `json:"id"`
+
+	Title string `json:"title"`
+
+	Enable bool `json:"enable"`
+
+	// Id каналу YouTube
+	Idch string `json:"idch"`
+
+	Timeadd time.Time `json:"timeadd"`
+
+	Countvideo int `json:"countvideo"`
+
+	// Час перенесення в архів, для активних плейлистів nil
+	Archived *time.Time `json:"archived,omitempty"`
+
+	// Індивідуальні налаштування збору метрик, періоди у форматі "72h", "30m". Не задані - глобальні налаштування
+	PeriodCollect        string `json:"periodcollect,omitempty"`
+	PeriodMetric         string `json:"periodmetric,omitempty"`
+	PeriodSaveMetricIdle string `json:"periodsavemetricidle,omitempty"`
+	MaxRequestVideos     int    `json:"maxrequestvideos,omitempty"`
+}
+
+type ResponcePlayList struct {
+	MaxVideoCount int        `json:"maxvideocount"`
+	PlayLists     []PlayList `json:"playlists"`
+}
+
+type YoutubeVideo struct {
+	// Id плейлиста, пустий для відео, знайдених тільки запитом
+	PlaylistId string `json:"idpl"`
+
+	Title string `json:"title"`
+
+	Description string `json:"description"`
+
+	ChannelTitle string `json:"chtitle"`
+
+	ChannelId string `json:"chid"`
+
+	PublishedAt time.Time `json:"publishedat"`
+
+	// Кількість збережених метрик
+	CountMetrics int `json:"count"`
+
+	MinTimeMetric time.Time `json:"mintime"`
+
+	MaxTimeMetric time.Time `json:"maxtime"`
+
+	// Позиція відносно типових показників плейлиста, nil - порівняти немає з чим
+	Baseline *BaselinePosition `json:"baseline,omitempty"`
+}
+
+type BaselinePosition struct {
+	// Вік в мілісекундах
+	Age int64 `json:"age"`
+
+	View float64 `json:"view"`
+
+	// Частка інших відео плейлиста з меншою кількістю переглядів в цьому віці, %
+	Percentile float64 `json:"percentile"`
+
+	// "<p10", "p10-p25", "p25-p50", "p50-p75", "p75-p90", ">p90"
+	Band string `json:"band"`
+
+	Videos int `json:"videos"`
+}
+
+// Перцентилі переглядів останніх відео плейлиста для кожного віку, nil - замало відео з вимірами
+type ResponceBaseline struct {
+	PlaylistId string `json:"idpl"`
+
+	Step string `json:"step"`
+
+	Videos int `json:"videos"`
+
+	TimeUpdate time.Time `json:"timeupdate"`
+
+	// Вік в мілісекундах
+	Ages []int64 `json:"ages"`
+
+	Count []int `json:"count"`
+
+	P10    []*float64 `json:"p10"`
+	P25    []*float64 `json:"p25"`
+	Median []*float64 `json:"median"`
+	P75    []*float64 `json:"p75"`
+	P90    []*float64 `json:"p90"`
+}
+
+// Сторінка списку відео, NextCursor = "" - остання сторінка
+type ResponceVideos struct {
+	Items      []YoutubeVideoShort `json:"items"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+	Total      int64               `json:"total"`
+}
+
+type YoutubeVideoShort struct {
+	Id string `json:"id"`
+
+	Title string `json:"title"`
+
+	PublishedAt time.Time `json:"publishedat"`
+
+	// Назва плейлиста
+	Ptitle string `json:"ptitle"`
+
+	// Останній вимір метрик, TimeMetric = nil - метрик ще немає
+	ViewCount    uint64     `json:"view"`
+	LikeCount    uint64     `json:"like"`
+	CommentCount uint64     `json:"comment"`
+	TimeMetric   *time.Time `json:"mtime,omitempty"`
+
+	// Приріст переглядів за годину за останню добу вимірів
+	Growth float64 `json:"growth"`
+}
+
+type Metrics struct {
+	CommentCount uint64 `json:"comment"`
+
+	LikeCount uint64 `json:"like"`
+
+	DislikeCount uint64 `json:"dislike"`
+
+	ViewCount uint64 `json:"view"`
+
+	Time time.Time `json:"mtime"`
+}
+
+// Приріст метрик за інтервал, що закінчується в Time, та приріст за годину
+type MetricsDelta struct {
+	Time time.Time `json:"mtime"`
+
+	// Довжина інтервалу в мілісекундах
+	Period int64 `json:"period"`
+
+	View    int64 `json:"view"`
+	Like    int64 `json:"like"`
+	Dislike int64 `json:"dislike"`
+	Comment int64 `json:"comment"`
+
+	ViewRate    float64 `json:"viewrate"`
+	LikeRate    float64 `json:"likerate"`
+	DislikeRate float64 `json:"dislikerate"`
+	CommentRate float64 `json:"commentrate"`
+}
+
+type Engagement struct {
+	LikeView    float64 `json:"likeview"`
+	DislikeView float64 `json:"dislikeview"`
+	CommentView float64 `json:"commentview"`
+	LikeRatio   float64 `json:"likeratio"`
+}
+
+type ResponceDerivedMetrics struct {
+	Id string `json:"id"`
+
+	PublishedAt time.Time `json:"publishedat"`
+
+	Interval string `json:"interval"`
+
+	// Перше та останнє вимірювання за період, nil - метрик немає
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+
+	Total MetricsDelta `json:"total"`
+
+	// Залученість за лічильниками на кінець періоду та за приростами за період
+	Engagement      Engagement `json:"engagement"`
+	RangeEngagement Engagement `json:"rangeengagement"`
+
+	PeakViewRate float64    `json:"peakviewrate"`
+	PeakTime     *time.Time `json:"peaktime"`
+
+	// Мілісекунд від публікації до PeakTime
+	TimeToPeak int64 `json:"timetopeak"`
+
+	Deltas []MetricsDelta `json:"deltas"`
+}
+
+// Метрики відео на сітці віку, nil - вік поза періодом вимірів
+type CompareSeries struct {
+	Id string `json:"id"`
+
+	Title string `json:"title"`
+
+	PublishedAt time.Time `json:"publishedat"`
+
+	View    []*float64 `json:"view"`
+	Like    []*float64 `json:"like"`
+	Dislike []*float64 `json:"dislike"`
+	Comment []*float64 `json:"comment"`
+}
+
+type ResponceCompare struct {
+	Step string `json:"step"`
+
+	// Вік в мілісекундах для кожного значення серій
+	Ages []int64 `json:"ages"`
+
+	Series []*CompareSeries `json:"series"`
+}
+
+type GlobalCounts struct {
+	TimeUpdate time.Time `json:"timeupdate"`
+
+	CountPlaylists int `json:"countpl"`
+
+	CountVideos int `json:"countvideo"`
+
+	MaxVideoCount int `json:"maxcountvideo"`
+
+	// Період кешу списку відео в мілісекундах
+	PeriodVideoCache int64 `json:"periodvideocache"`
+
+	Version string `json:"version"`
+
+	ListenAdmin bool `json:"listenadmin"`
+}
+
+// Види запитів
+const QUERY_SEARCH = "search"
+const QUERY_TRENDING = "trending"
+
+type Query struct {
+	Id int64 `json:"id"`
+
+	// QUERY_SEARCH або QUERY_TRENDING
+	Kind string `json:"kind"`
+
+	// Ключові слова для пошуку
+	Query string `json:"query"`
+
+	// Код регіону (ISO 3166-1 alpha-2), для чарту обов'язковий
+	RegionCode string `json:"regioncode"`
+
+	Title string `json:"title"`
+
+	Enable bool `json:"enable"`
+
+	MaxResults int `json:"maxresults,omitempty"`
+
+	PeriodQuery string `json:"periodquery,omitempty"`
+
+	Timeadd time.Time `json:"timeadd"`
+}
+
+type QueryRank struct {
+	Rank int `json:"rank"`
+
+	Time time.Time `json:"rtime"`
+}
+
+type QueryVideoRanks struct {
+	Id string `json:"id"`
+
+	Title string `json:"title"`
+
+	Ranks []QueryRank `json:"ranks"`
+}
+
+type ResponceQueryRanks struct {
+	Query Query `json:"query"`
+
+	Videos []*QueryVideoRanks `json:"videos"`
+}
+
+type Comment struct {
+	Id string `json:"id"`
+
+	PublishedAt time.Time `json:"publishedat"`
+
+	LikeCount int64 `json:"like"`
+
+	ReplyCount int64 `json:"reply"`
+
+	Text string `json:"text"`
+
+	TimeUpdate time.Time `json:"timeupdate"`
+}
+
+type CommentRate struct {
+	Time time.Time `json:"ctime"`
+
+	Count int `json:"count"`
+}
+
+type ResponceCommentRate struct {
+	Interval string `json:"interval"`
+
+	Rates []CommentRate `json:"rates"`
+}
+
+// Результат очищення архівного плейлиста, для DryRun - кількість рядків, які будуть видалені
+type ResponcePurge struct {
+	DryRun   bool  `json:"dryrun"`
+	Videos   int64 `json:"videos"`
+	Metrics  int64 `json:"metrics"`
+	Rollups  int64 `json:"rollups"`
+	Comments int64 `json:"comments"`
+}
+
+// Відео, знайдене повнотекстовим пошуком. В TitleHighlight та DescriptionHighlight знайдені слова виділені
+// тегами <b></b>, текст не екранується
+type SearchResult struct {
+	Id                   string    `json:"id"`
+	PlaylistId           string    `json:"idpl,omitempty"`
+	Title                string    `json:"title"`
+	ChannelTitle         string    `json:"chtitle"`
+	ChannelId            string    `json:"chid"`
+	PublishedAt          time.Time `json:"publishedat"`
+	Rank                 float64   `json:"rank"`
+	TitleHighlight       string    `json:"titlehighlight"`
+	DescriptionHighlight string    `json:"descriptionhighlight"`
+}
+
+// Рейтинг відео за приростом метрики за вікно. Updated = nil - приріст ще не рахувався
+type ResponceTop struct {
+	Period  string     `json:"period"`
+	Metric  string     `json:"metric"`
+	Updated *time.Time `json:"updated,omitempty"`
+	Items   []TopVideo `json:"items"`
+}
+
+// Відео рейтингу: приріст метрики (Gain) з TimeFrom до останнього виміру TimeLast та її останнє значення (Count)
+type TopVideo struct {
+	Id          string    `json:"id"`
+	PlaylistId  string    `json:"idpl"`
+	Title       string    `json:"title"`
+	Ptitle      string    `json:"ptitle"`
+	PublishedAt time.Time `json:"publishedat"`
+	Gain        int64     `json:"gain"`
+	Count       int64     `json:"count"`
+	TimeFrom    time.Time `json:"timefrom"`
+	TimeLast    time.Time `json:"timelast"`
+	TimeUpdate  time.Time `json:"timeupdate"`
+}
+
+// Новий вимір метрик відео в живому потоці
+type StreamMetric struct {
+	Id           string    `json:"id"`
+	PlaylistId   string    `json:"idpl,omitempty"`
+	CommentCount uint64    `json:"comment"`
+	LikeCount    uint64    `json:"like"`
+	DislikeCount uint64    `json:"dislike"`
+	ViewCount    uint64    `json:"view"`
+	Time         time.Time `json:"mtime"`
+}
+
+// Нове відео плейлиста в живому потоці
+type StreamVideo struct {
+	Id          string    `json:"id"`
+	PlaylistId  string    `json:"idpl"`
+	Title       string    `json:"title"`
+	PublishedAt time.Time `json:"publishedat"`
+	TimeAdd     time.Time `json:"timeadd"`
+}
+
+// Подія живого потоку: Id - час події в мілісекундах, заповнене Metric або Video відповідно до Event
+type StreamEvent struct {
+	Id     int64
+	Event  string
+	Metric *StreamMetric
+	Video  *StreamVideo
+}
+
+// Запис журналу аудиту змін
+type AuditRecord struct {
+	Id         int64           `json:"id"`
+	Time       time.Time       `json:"time"`
+	Actor      string          `json:"actor,omitempty"`
+	Action     string          `json:"action"`
+	Target     string          `json:"target"`
+	PlaylistId string          `json:"idpl,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Addr       string          `json:"addr,omitempty"`
+}
+
+type ResponceReload struct {
+	Changed []string `json:"changed"`
+}
+
+// Алгоритми проріджування метрик
+const ALGO_LTTB = "lttb"
+const ALGO_MINMAX = "minmax"
+const ALGO_AVG = "avg"
+
+// Сортування списку відео (VideoFilter.Sort), за спаданням
+const SORT_PUBLISHED = "published"
+const SORT_VIEWS = "views"
+const SORT_LIKES = "likes"
+const SORT_COMMENTS = "comments"
+const SORT_GROWTH = "growth"
+
+// Стан збору метрик (VideoFilter.Status)
+const STATUS_ACTIVE = "active"
+const STATUS_FINISHED = "finished"
+
+// Вікна рейтингу відео (GetTop)
+const TOP_PERIOD_HOUR = "1h"
+const TOP_PERIOD_DAY = "24h"
+const TOP_PERIOD_WEEK = "7d"
+
+// Метрики рейтингу відео (GetTop)
+const TOP_METRIC_VIEWS = "views"
+const TOP_METRIC_LIKES = "likes"
+const TOP_METRIC_COMMENTS = "comments"
+
+// Типи подій живого потоку (StreamEvent.Event)
+const STREAM_EVENT_METRIC = "metric"
+const STREAM_EVENT_VIDEO = "video"
+
+// Формати експорту
+const FORMAT_JSON = "json"
+const FORMAT_CSV = "csv"
+const FORMAT_NDJSON = "ndjson"
+const FORMAT_XLSX = "xlsx"
+
+// Ролі користувачів API
+const ROLE_VIEWER = "viewer"
+const ROLE_EDITOR = "editor"
+const ROLE_ADMIN = "admin"
+
+type ApiUser struct {
+	Name string `json:"name"`
+
+	Role string `json:"role"`
+
+	Enable bool `json:"enable"`
+
+	// Пароль для авторизації Basic, тільки при створенні користувача
+	Password string `json:"password,omitempty"`
+
+	HasPassword bool `json:"haspassword"`
+	HasToken    bool `json:"hastoken"`
+
+	Timeadd time.Time `json:"timeadd"`
+}
+
+type ResponceApiUser struct {
+	Name string `json:"name"`
+
+	Role string `json:"role"`
+
+	// Токен для авторизації Bearer, видається тільки один раз
+	Token string `json:"token"`
+}
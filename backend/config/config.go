@@ -1,125 +1,154 @@
-package config
-
-import (
-	"flag"
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
-	"time"
-	"github.com/vharitonsky/iniflags"
-	"strings"	
-)
-
-var (
-	Addr = flag.String("Addr", "0.0.0.0:3000", "")
-	Timeout = flag.Duration("timeout", time.Second * 15, "")
-	ListenAdmin = flag.Bool("ListenAdmin", false, "")
-	Origin = flag.String("Origin", "*", "")
-	MaxViewVideosInPlayLists = flag.Int("MaxViewVideosInPlayLists", 30, "")
-
-	EnableCache = flag.Bool("enableCache", true, "Enable cache?")
-	PeriodPlayListCache = flag.Duration("periodPlayListCache", time.Minute * 30, "")
-	PeriodMeterCache = flag.Duration("periodMetricCache", time.Second * 60, "")	
-	PeriodCollectionCache = flag.Duration("periodCollectCache", time.Hour * 24 * 14, "")
-	PeriodVideoCache = flag.Duration("periodVideoCache", time.Minute * 5, "")
-
-	MaxSizeCacheVideo = flag.Int("maxSizeCacheVideo", 1000, "")
-	MaxSizeCacheVideoDescription = flag.Int("maxSizeCacheVideoDescription", 1000, "")
-	MaxSizeCachePlaylists = flag.Int("maxSizeCachePlaylists", 1000, "")
-
-	debugLevel = flag.String("debugLevel", "info", "")
-	Log = flag.String("Log", "backend.log", "")
-	LogError = flag.String("LogError", "backend_error.log", "")
-	LogTimeFormat = flag.String("LogTimeFormat", "02-01-2006 15:04:05", "")
-
-	DBHost = flag.String("dbhost", "localhost", "")
-	DBPort = flag.String("dbport", "5432", "")
-	DBName = flag.String("dbname", "basename", "")
-	DBUser = flag.String("dbuser", "username", "")
-	DBPassword = flag.String("dbpasswd", "userpasswd", "")
-	DBSSLMode = flag.String("dbsslmode", "disable", "")
-
-	Logger *zap.SugaredLogger	
-)
-
-func myTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-	enc.AppendString(t.Format(*LogTimeFormat))
-}
-
-func init() {
-
-	iniflags.Parse() 
-
-	// set debug level
-	var atomicLevel zapcore.Level
-
-	switch *debugLevel {
-	case "debug":
-		atomicLevel = zapcore.DebugLevel
-	case "info":
-		atomicLevel = zapcore.InfoLevel
-	case "warn":
-		atomicLevel = zapcore.WarnLevel
-	case "error":
-		atomicLevel = zapcore.ErrorLevel
-	case "dpanic":
-		atomicLevel = zapcore.DPanicLevel
-	case "panic":
-		atomicLevel = zapcore.PanicLevel
-	case "fatal":
-		atomicLevel = zapcore.FatalLevel
-	default:
-		atomicLevel = zapcore.InfoLevel
-	}
-
-	// Set loggin systems
-	cfg := zap.Config{
-		Encoding:         "console",
-		Level:            zap.NewAtomicLevelAt(atomicLevel),
-		OutputPaths:      strings.Split( *Log, ","),
-		ErrorOutputPaths: strings.Split( *LogError, ","),
-		EncoderConfig: zapcore.EncoderConfig{
-			MessageKey: "message",
-
-			LevelKey:    "level",
-			EncodeLevel: zapcore.CapitalLevelEncoder,
-
-			TimeKey:    "time",
-			EncodeTime: myTimeEncoder,
-
-			CallerKey:    "caller",
-			EncodeCaller: zapcore.ShortCallerEncoder,
-		},
-	}
-
-	logger, _ := cfg.Build()
-	defer logger.Sync() // flushes buffer, if any	
-	Logger = logger.Sugar()
-
-	Logger.Warnf("debug level=%v", atomicLevel)
-	Logger.Debugf("Log=%v", *Log)
-	Logger.Debugf("LogError=%v", *LogError)
-	Logger.Debugf("LogTimeFormat=%v", *LogTimeFormat)
-	
-	Logger.Debugf("addr=%v", *Addr)
-	Logger.Debugf("timeout=%v", *Timeout)
-	Logger.Debugf("ListenAdmin=%v", *ListenAdmin)
-	Logger.Debugf("Origin=%v", *Origin)
-	Logger.Debugf("MaxViewVideosInPlayLists=%v", *MaxViewVideosInPlayLists)
-		
-	Logger.Debugf("PeriodPlayListCache=%v", *PeriodPlayListCache)
-	Logger.Debugf("PeriodVideoCache=%v", *PeriodVideoCache)
-	Logger.Debugf("PeriodMeterCache=%v", *PeriodMeterCache)
-	Logger.Debugf("PeriodСollectionCache=%v", *PeriodCollectionCache)
-
-	Logger.Debugf("EnableCache=%v", *EnableCache)
-	Logger.Debugf("MaxSizeCacheVideo=%v", *MaxSizeCacheVideo)
-	Logger.Debugf("MaxSizeCacheVideoDescription=%v", *MaxSizeCacheVideoDescription)	
-	Logger.Debugf("MaxSizeCachePlaylists=%v", *MaxSizeCachePlaylists)
-
-	Logger.Debugf("dbhost=%s", *DBHost)
-	Logger.Debugf("dbport=%s", *DBPort)	
-	Logger.Debugf("dbname=%s", *DBName)
-	Logger.Debugf("dbuser=%s", *DBUser)
-	Logger.Debugf("dbpasswd=%s", *DBPassword)
-	Logger.Debugf("dbsslmode=%s", *DBSSLMode)
-}
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"time"
+	"github.com/vharitonsky/iniflags"
+	"github.com/AleksandrKuts/youtubemeter-service/reload"
+	"github.com/AleksandrKuts/youtubemeter-service/settings"
+	"strings"	
+)
+
+var (
+	Addr = flag.String("Addr", "0.0.0.0:3000", "")
+	Timeout = flag.Duration("timeout", time.Second * 15, "")
+	ListenAdmin = flag.Bool("ListenAdmin", false, "")
+	Origin = reload.String("Origin", "*", "Allowed CORS origins, comma separated, wildcards allowed")
+	CorsCredentials = reload.Bool("corsCredentials", false, "Allow credentials in CORS requests")
+	CorsMaxAge = reload.Duration("corsMaxAge", time.Minute * 10, "How long browsers may cache CORS preflight")
+	MaxViewVideosInPlayLists = reload.Int("MaxViewVideosInPlayLists", 30, "")
+	MaxPageSize = reload.Int("maxPageSize", 200, "Max number of videos in a page of a video list (limit=)")
+	MetricPoints = reload.Int("metricPoints", 100, "Default number of points of downsampled metrics (points=)")
+	MaxMetricPoints = reload.Int("maxMetricPoints", 2000, "Max number of points of downsampled metrics (points=)")
+	RollupHourlySpan = reload.Duration("rollupHourlySpan", time.Hour * 72, "Periods of metrics longer than this are read from hourly rollups")
+	RollupDailySpan = reload.Duration("rollupDailySpan", time.Hour * 24 * 60, "Periods of metrics longer than this are read from daily rollups")
+	ExportTimeout = reload.Duration("exportTimeout", time.Minute * 10, "Max time to write an export (format=, raw=true)")
+	BaselineVideos = reload.Int("baselineVideos", 30, "Number of latest playlist videos in the baseline percentile bands")
+	BaselineStep = reload.Duration("baselineStep", time.Hour, "Age bucket of the baseline percentile bands")
+	PeriodBaseline = reload.Duration("periodBaseline", time.Hour, "Period of recomputing the baseline percentile bands")
+	PurgeBatch = reload.Int("purgeBatch", 10000, "Number of metrics deleted in one statement when purging a playlist")
+	StreamHeartbeat = reload.Duration("streamHeartbeat", time.Second * 15, "Interval of heartbeats in the live stream (/view/stream)")
+	StreamResume = reload.Duration("streamResume", time.Hour, "Max age of events replayed when the live stream is resumed")
+	MaxStreamConnections = reload.Int("maxStreamConnections", 100, "Max number of live stream connections")
+	MaxStreamSubscriptions = reload.Int("maxStreamSubscriptions", 50, "Max number of videos and playlists in one live stream")
+	AuthViewer = reload.Bool("authViewer", false, "Require authentication (viewer role) for read requests")
+
+	EnableCache = flag.Bool("enableCache", true, "Enable cache?")
+	PeriodPlayListCache = reload.Duration("periodPlayListCache", time.Minute * 30, "")
+	PeriodMeterCache = reload.Duration("periodMetricCache", time.Second * 60, "")	
+	PeriodCollectionCache = reload.Duration("periodCollectCache", time.Hour * 24 * 14, "")
+	PeriodVideoCache = reload.Duration("periodVideoCache", time.Minute * 5, "")
+
+	MaxSizeCacheVideo = flag.Int("maxSizeCacheVideo", 1000, "")
+	MaxSizeCacheVideoDescription = flag.Int("maxSizeCacheVideoDescription", 1000, "")
+	MaxSizeCachePlaylists = flag.Int("maxSizeCachePlaylists", 1000, "")
+
+	debugLevel = reload.String("debugLevel", "info", "")
+	Log = flag.String("Log", "backend.log", "")
+	LogError = flag.String("LogError", "backend_error.log", "")
+	LogTimeFormat = flag.String("LogTimeFormat", "02-01-2006 15:04:05", "")
+
+	DBHost = flag.String("dbhost", "localhost", "")
+	DBPort = flag.String("dbport", "5432", "")
+	DBName = flag.String("dbname", "basename", "")
+	DBUser = flag.String("dbuser", "username", "")
+	DBPassword = flag.String("dbpasswd", "userpasswd", "")
+	DBPasswordFile = flag.String("dbpasswdFile", "", "File with database password, overrides dbpasswd")
+	DBSSLMode = flag.String("dbsslmode", "disable", "")
+
+	printConfig = flag.Bool("print-config", false, "Print configuration with hidden secrets and exit")
+	CreateAdmin = flag.String("create-admin", "", "Create API user with admin role (or reset its token), print token and exit")
+	Migrate = flag.String("migrate", "", "Migrate database schema (up, down or status) and exit")
+
+	Logger *zap.SugaredLogger	
+
+	// Рівень налагодження, може змінюватись під час роботи (див. Reload)
+	atomicLevel zap.AtomicLevel
+)
+
+func myTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(t.Format(*LogTimeFormat))
+}
+
+// Рівень налагодження за назвою, невідома назва - info
+func parseLevel(level string) zapcore.Level {
+	switch level {
+	case "debug":
+		return zapcore.DebugLevel
+	case "info":
+		return zapcore.InfoLevel
+	case "warn":
+		return zapcore.WarnLevel
+	case "error":
+		return zapcore.ErrorLevel
+	case "dpanic":
+		return zapcore.DPanicLevel
+	case "panic":
+		return zapcore.PanicLevel
+	case "fatal":
+		return zapcore.FatalLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+// Завантажити налаштування (ini-файл, командний рядок, змінні оточення YTM_*), перевірити їх та створити логер.
+// main викликає Load до роботи з іншими пакетами програми
+func Load() {
+	iniflags.Parse() 
+
+	// Змінні оточення YTM_* мають пріоритет над ini-файлом, пароль з файлу - над dbpasswd
+	errs := settings.ApplyEnv(envFlags)
+	if err := readPasswordFile(); err != nil {
+		errs = append(errs, "cannot read dbpasswdFile: "+err.Error())
+	}
+	settings.ExitOnErrors(append(errs, validate()...))
+
+	if *printConfig {
+		fmt.Println(formatConfig())
+		os.Exit(0)
+	}
+
+	// set debug level
+	atomicLevel = zap.NewAtomicLevelAt(parseLevel(debugLevel.Get()))
+
+	// Set loggin systems
+	cfg := zap.Config{
+		Encoding:         "console",
+		Level:            atomicLevel,
+		OutputPaths:      strings.Split( *Log, ","),
+		ErrorOutputPaths: strings.Split( *LogError, ","),
+		EncoderConfig: zapcore.EncoderConfig{
+			MessageKey: "message",
+
+			LevelKey:    "level",
+			EncodeLevel: zapcore.CapitalLevelEncoder,
+
+			TimeKey:    "time",
+			EncodeTime: myTimeEncoder,
+
+			CallerKey:    "caller",
+			EncodeCaller: zapcore.ShortCallerEncoder,
+		},
+	}
+
+	// всі повідомлення логера проходять через settings.RedactCore, щоб секрети не потрапили в лог
+	logger, err := cfg.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return settings.RedactCore{Core: core}
+	}))
+	if err != nil {
+		settings.ExitOnErrors([]string{"cannot create logger: " + err.Error()})
+	}
+
+	defer logger.Sync() // flushes buffer, if any	
+	Logger = logger.Sugar()
+
+	Logger.Warnf("debug level=%v", atomicLevel.Level())
+	for _, line := range strings.Split(formatConfig(), "\n") {
+		Logger.Debug(line)
+	}
+}
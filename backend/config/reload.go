@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/AleksandrKuts/youtubemeter-service/reload"
+)
+
+// Налаштування, які можна змінити під час роботи без перезапуску програми. Всі інші налаштування
+// (адреса сервера, база даних, лог-файли, розміри кешів тощо) потребують перезапуску
+var reloadable = map[string]bool{
+	"debugLevel":               true,
+	"Origin":                   true,
+	"corsCredentials":          true,
+	"corsMaxAge":               true,
+	"MaxViewVideosInPlayLists": true,
+	"maxPageSize":              true,
+	"metricPoints":             true,
+	"maxMetricPoints":          true,
+	"rollupHourlySpan":         true,
+	"rollupDailySpan":          true,
+	"exportTimeout":            true,
+	"baselineVideos":           true,
+	"baselineStep":             true,
+	"periodBaseline":           true,
+	"purgeBatch":               true,
+	"streamHeartbeat":          true,
+	"streamResume":             true,
+	"maxStreamConnections":     true,
+	"maxStreamSubscriptions":   true,
+	"authViewer":               true,
+	"periodPlayListCache":      true,
+	"periodMetricCache":        true,
+	"periodCollectCache":       true,
+	"periodVideoCache":         true,
+}
+
+// Перечитування налаштувань без перезапуску програми
+var reloader = reload.New(reloadable, validate, displayValue)
+
+// Зареєструвати функцію, яка буде викликана після успішного перечитування налаштувань
+func OnReload(f func()) {
+	reloader.OnReload(f)
+}
+
+// Перечитати ini-файл налаштувань та застосувати зміни (див. reload.Reloader.Reload). Налаштування задані
+// змінними оточення мають пріоритет над ini-файлом, тому не перечитуються.
+// Повертає перелік змінених налаштувань у форматі "name: old -> new"
+func Reload() ([]string, error) {
+	changed, err := reloader.Reload(envFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	atomicLevel.SetLevel(parseLevel(debugLevel.Get()))
+
+	if len(changed) > 0 {
+		Logger.Warnf("config reloaded, changed: %v", strings.Join(changed, ", "))
+	} else {
+		Logger.Info("config reloaded, nothing changed")
+	}
+
+	return changed, nil
+}
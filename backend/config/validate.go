@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/AleksandrKuts/youtubemeter-service/reload"
+	"github.com/AleksandrKuts/youtubemeter-service/settings"
+)
+
+// Максимальна кількість вузлів сітки порівняння відео за віком (/view/compare) та типових показників плейлиста
+// (periodCollectCache / baselineStep)
+const MAX_COMPARE_POINTS = 2000
+
+// Налаштування, задані змінними оточення. Вони мають пріоритет над ini-файлом, тому не перечитуються (див. Reload)
+var envFlags = make(map[string]bool)
+
+// Правила перевірки налаштувань
+var rules = []settings.Rule{
+	settings.NotEmpty("Log", reload.Fixed(Log)),
+	settings.NotEmpty("LogError", reload.Fixed(LogError)),
+	settings.OneOf("debugLevel", debugLevel.Candidate, "debug", "info", "warn", "error", "dpanic", "panic", "fatal"),
+	settings.HostPort("Addr", reload.Fixed(Addr)),
+	originList("Origin", Origin.Candidate),
+	originCredentials("Origin", Origin.Candidate, "corsCredentials", CorsCredentials.Candidate),
+	settings.PositiveDuration("corsMaxAge", CorsMaxAge.Candidate),
+	settings.PositiveDuration("timeout", reload.Fixed(Timeout)),
+	settings.IntBetween("MaxViewVideosInPlayLists", MaxViewVideosInPlayLists.Candidate, 1, 1000),
+	settings.IntBetween("maxPageSize", MaxPageSize.Candidate, 1, 10000),
+	settings.IntNotGreater("MaxViewVideosInPlayLists", MaxViewVideosInPlayLists.Candidate,
+		"maxPageSize", MaxPageSize.Candidate),
+	settings.IntBetween("metricPoints", MetricPoints.Candidate, 2, 100000),
+	settings.IntBetween("maxMetricPoints", MaxMetricPoints.Candidate, 2, 100000),
+	settings.IntNotGreater("metricPoints", MetricPoints.Candidate, "maxMetricPoints", MaxMetricPoints.Candidate),
+	settings.PositiveDuration("rollupHourlySpan", RollupHourlySpan.Candidate),
+	settings.PositiveDuration("rollupDailySpan", RollupDailySpan.Candidate),
+	settings.PositiveDuration("exportTimeout", ExportTimeout.Candidate),
+	settings.IntBetween("baselineVideos", BaselineVideos.Candidate, 3, 200),
+	settings.PositiveDuration("baselineStep", BaselineStep.Candidate),
+	settings.PositiveDuration("periodBaseline", PeriodBaseline.Candidate),
+	settings.IntBetween("purgeBatch", PurgeBatch.Candidate, 1, 1000000),
+	settings.PositiveDuration("streamHeartbeat", StreamHeartbeat.Candidate),
+	settings.PositiveDuration("streamResume", StreamResume.Candidate),
+	settings.IntBetween("maxStreamConnections", MaxStreamConnections.Candidate, 1, 100000),
+	settings.IntBetween("maxStreamSubscriptions", MaxStreamSubscriptions.Candidate, 1, 1000),
+	settings.PositiveDuration("periodPlayListCache", PeriodPlayListCache.Candidate),
+	settings.PositiveDuration("periodMetricCache", PeriodMeterCache.Candidate),
+	settings.PositiveDuration("periodCollectCache", PeriodCollectionCache.Candidate),
+	settings.PositiveDuration("periodVideoCache", PeriodVideoCache.Candidate),
+	settings.StepsLess("periodCollectCache", PeriodCollectionCache.Candidate, "baselineStep", BaselineStep.Candidate,
+		MAX_COMPARE_POINTS),
+	settings.IntBetween("maxSizeCacheVideo", reload.Fixed(MaxSizeCacheVideo), 1, 1000000),
+	settings.IntBetween("maxSizeCacheVideoDescription", reload.Fixed(MaxSizeCacheVideoDescription), 1, 1000000),
+	settings.IntBetween("maxSizeCachePlaylists", reload.Fixed(MaxSizeCachePlaylists), 1, 1000000),
+	settings.NotEmpty("dbhost", reload.Fixed(DBHost)),
+	settings.Port("dbport", reload.Fixed(DBPort)),
+	settings.NotEmpty("dbname", reload.Fixed(DBName)),
+	settings.NotEmpty("dbuser", reload.Fixed(DBUser)),
+	settings.OneOf("migrate", reload.Fixed(Migrate), "", "up", "down", "status"),
+	settings.OneOf("dbsslmode", reload.Fixed(DBSSLMode),
+		"disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+}
+
+// Перевірити всі налаштування, повертає перелік помилок
+func validate() []string {
+	return settings.Validate(rules)
+}
+
+func originList(name string, value func() string) settings.Rule {
+	return func() string {
+		for _, origin := range Origins(value()) {
+			if _, err := path.Match(origin, ""); err != nil {
+				return fmt.Sprintf("%v has invalid origin pattern %q: %v", name, origin, err)
+			}
+		}
+		return ""
+	}
+}
+
+// Браузер не приймає облікові дані у відповіді з Access-Control-Allow-Origin: *, тому джерело "*"
+// разом з дозволом облікових даних - помилка налаштувань
+func originCredentials(name string, value func() string, credentialsName string,
+	credentials func() bool) settings.Rule {
+	return func() string {
+		if !credentials() {
+			return ""
+		}
+		for _, origin := range Origins(value()) {
+			if origin == "*" {
+				return fmt.Sprintf("%v must not be \"*\" when %v = true", name, credentialsName)
+			}
+		}
+		return ""
+	}
+}
+
+// Перелік дозволених джерел (Origin) з налаштування: через кому, шаблони як у path.Match,
+// наприклад "https://example.com, https://*.example.com"; "*" - будь-яке джерело
+func Origins(value string) []string {
+	origins := []string{}
+	for _, origin := range strings.Split(value, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// Всі налаштування у форматі ini-файлу, секретні значення приховані
+func formatConfig() string {
+	return settings.FormatConfig(displayValue, "config", "print-config", "create-admin", "migrate")
+}
@@ -1,16 +1,38 @@
-package main
-
-import (
-	"fmt"
-	"github.com/AleksandrKuts/youtubemeter-service/backend/server"
-)
-const versionMajor = "1.0"
-
-var (
-	version string
-)
-
-func main() {
-	fmt.Printf("version: %s.%s\n", versionMajor, version)
-	server.StartService(versionMajor, version)
-}
+package main
+
+import (
+	"fmt"
+	"os"
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+	"github.com/AleksandrKuts/youtubemeter-service/backend/server"
+)
+const versionMajor = "1.0"
+
+var (
+	version string
+)
+
+func main() {
+	config.Load()
+	fmt.Printf("version: %s.%s\n", versionMajor, version)
+
+	// Міграція схеми БД: backend --migrate=up|down|status
+	if *config.Migrate != "" {
+		if err := server.Migrate(*config.Migrate); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot migrate database: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Створення першого адміністратора API: backend --create-admin=<ім'я>
+	if *config.CreateAdmin != "" {
+		if err := server.CreateAdmin(*config.CreateAdmin); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot create admin: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	server.StartService(versionMajor, version)
+}
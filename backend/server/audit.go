@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// Журнал аудиту: кожна зміна через API адміністратора (плейлисти, запити, користувачі) записується в таблицю
+// audit в тій самій транзакції, що і сама зміна, тому запис є тоді і тільки тоді, коли зміна відбулась
+
+// Дії журналу аудиту
+const AUDIT_PLAYLIST_APPEND = "playlist.append"
+const AUDIT_PLAYLIST_UPDATE = "playlist.update"
+const AUDIT_PLAYLIST_ARCHIVE = "playlist.archive"
+const AUDIT_PLAYLIST_RESTORE = "playlist.restore"
+const AUDIT_PLAYLIST_PURGE = "playlist.purge"
+const AUDIT_QUERY_APPEND = "query.append"
+const AUDIT_QUERY_UPDATE = "query.update"
+const AUDIT_QUERY_DELETE = "query.delete"
+const AUDIT_USER_APPEND = "user.append"
+const AUDIT_USER_TOKEN = "user.token"
+const AUDIT_USER_DELETE = "user.delete"
+
+// Кількість записів журналу у відповіді: за замовчуванням та максимальна (limit=)
+const DEFAULT_AUDIT_RECORDS = 100
+const MAX_AUDIT_RECORDS = 1000
+
+// Хто, що і звідки змінює. Записується в журнал разом зі станом об'єкта до та після зміни
+type auditEntry struct {
+	// Користувач API, пусто - зміна з командного рядка
+	Actor string
+
+	Action string
+
+	// Id плейлиста, запиту або ім'я користувача
+	Target string
+
+	// Плейлист, якого стосується зміна
+	PlaylistId string
+
+	// Адреса клієнта
+	Addr string
+}
+
+// Запис аудиту для зміни, яку виконує запит r
+func newAuditEntry(r *http.Request, action, target string) *auditEntry {
+	audit := &auditEntry{Action: action, Target: target}
+
+	if user := requestUser(r); user != nil {
+		audit.Actor = user.Name
+	}
+
+	audit.Addr = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		audit.Addr = host
+	}
+
+	return audit
+}
+
+// Запис аудиту для зміни плейлиста
+func newPlaylistAuditEntry(r *http.Request, action, playlistId string) *auditEntry {
+	audit := newAuditEntry(r, action, playlistId)
+	audit.PlaylistId = playlistId
+	return audit
+}
+
+// Отримати записи журналу аудиту за період (мілісекунди), користувачем та плейлистом, пусто - без обмеження
+func getAudit(from, to, actor, playlistId string, limit int) ([]byte, error) {
+	response, err := getAuditFromDB(from, to, actor, playlistId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	auditJson, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("Error convert select to AuditRecord: error=%v", err)
+		return nil, err
+	}
+
+	return auditJson, nil
+}
+
+// Оброблювач запиту на отримання записів журналу аудиту (from, to, actor, idpl, limit - за замовчуванням 100)
+func getAuditHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := q.Get("req")
+
+	limit := DEFAULT_AUDIT_RECORDS
+	if s := q.Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > MAX_AUDIT_RECORDS {
+			writeError(w, r, badRequest("limit must be between 1 and %v", MAX_AUDIT_RECORDS))
+			return
+		}
+	}
+	log.Debugf("req=%v(%v), limit=%v", req, formatStringDate(req), limit)
+
+	auditJson, err := getAudit(q.Get("from"), q.Get("to"), q.Get("actor"), q.Get("idpl"), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(auditJson)
+}
@@ -0,0 +1,358 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Авторизація запитів до API. Користувачі зберігаються в БД (таблиця apiuser) і авторизуються
+// API-токеном (Authorization: Bearer <токен>) або іменем та паролем (Authorization: Basic).
+// Ролі впорядковані за правами: viewer < editor < admin, користувач з вищою роллю має всі права нижчих
+
+const ROLE_VIEWER = "viewer"
+const ROLE_EDITOR = "editor"
+const ROLE_ADMIN = "admin"
+
+// Довжина токена в байтах, в hex-вигляді вдвічі довша
+const TOKEN_LENGTH = 32
+const MAX_USER_NAME_LENGTH = 64
+
+const AUTH_REALM = "youtubemeter"
+
+// Рівень прав ролі
+var roleLevels = map[string]int{
+	ROLE_VIEWER: 1,
+	ROLE_EDITOR: 2,
+	ROLE_ADMIN:  3,
+}
+
+// Роль, потрібна для маршруту (по імені маршруту). Маршрути без ролі доступні всім,
+// якщо не встановлено authViewer (тоді потрібна роль viewer)
+var routeRoles = map[string]string{
+	"playlistsAdmin":  ROLE_EDITOR,
+	"appendPlaylist":  ROLE_EDITOR,
+	"updatePlaylist":  ROLE_EDITOR,
+	"deletePlaylist":  ROLE_EDITOR,
+	"restorePlaylist": ROLE_EDITOR,
+	"purgePlaylist":   ROLE_ADMIN,
+	"queriesAdmin":    ROLE_EDITOR,
+	"appendQuery":     ROLE_EDITOR,
+	"updateQuery":     ROLE_EDITOR,
+	"deleteQuery":     ROLE_EDITOR,
+	"reloadConfig":    ROLE_ADMIN,
+	"users":           ROLE_ADMIN,
+	"appendUser":      ROLE_ADMIN,
+	"updateUserToken": ROLE_ADMIN,
+	"deleteUser":      ROLE_ADMIN,
+	"audit":           ROLE_ADMIN,
+}
+
+type contextKey string
+
+// Ключ авторизованого користувача в контексті запиту
+const USER_CONTEXT_KEY = contextKey("user")
+
+var errUnauthorized = errors.New("invalid credentials")
+
+// Роль, потрібна для запиту, пустий рядок - авторизація не потрібна
+func requiredRole(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if role, ok := routeRoles[route.GetName()]; ok {
+			return role
+		}
+		// специфікація API доступна завжди
+		if route.GetName() == "openapi" {
+			return ""
+		}
+	}
+	if config.AuthViewer.Get() {
+		return ROLE_VIEWER
+	}
+	return ""
+}
+
+// Перевірка прав на запит. Якщо права є, повертається запит з авторизованим користувачем в контексті,
+// інакше у відповідь пишеться помилка 401 або 403 і повертається nil
+func authorize(w http.ResponseWriter, r *http.Request) *http.Request {
+	role := requiredRole(r)
+	if role == "" {
+		return r
+	}
+
+	user, err := authenticate(r)
+	if err == errUnauthorized {
+		log.Warnf("unauthorized, uri: %v, addr: %v", r.RequestURI, r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", "Bearer realm=\""+AUTH_REALM+"\"")
+		writeError(w, r, newApiError(http.StatusUnauthorized, ERR_UNAUTHORIZED, "authentication required"))
+		return nil
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return nil
+	}
+
+	if roleLevels[user.Role] < roleLevels[role] {
+		log.Warnf("forbidden, user: %v, role: %v, required: %v, uri: %v", user.Name, user.Role, role, r.RequestURI)
+		writeError(w, r, newApiError(http.StatusForbidden, ERR_FORBIDDEN, "role "+role+" required"))
+		return nil
+	}
+	log.Debugf("user: %v, role: %v", user.Name, user.Role)
+
+	return r.WithContext(context.WithValue(r.Context(), USER_CONTEXT_KEY, user))
+}
+
+// Авторизований користувач запиту, nil - запит без авторизації
+func requestUser(r *http.Request) *ApiUser {
+	user, _ := r.Context().Value(USER_CONTEXT_KEY).(*ApiUser)
+	return user
+}
+
+// Знайти користувача по заголовку Authorization. errUnauthorized - заголовка немає, невірні дані
+// або користувач деактивований
+func authenticate(r *http.Request) (*ApiUser, error) {
+	var user *ApiUser
+	var err error
+
+	header := r.Header.Get("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		if token == "" {
+			return nil, errUnauthorized
+		}
+		user, err = getApiUserByTokenFromDB(hashToken(token))
+	} else if name, password, ok := r.BasicAuth(); ok {
+		user, err = getApiUserByNameFromDB(name)
+		if err == nil && user != nil &&
+			(user.passwordHash == "" || bcrypt.CompareHashAndPassword([]byte(user.passwordHash), []byte(password)) != nil) {
+			user = nil
+		}
+	} else {
+		return nil, errUnauthorized
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.Enable {
+		return nil, errUnauthorized
+	}
+
+	return user, nil
+}
+
+// Хеш токена, в такому вигляді токен зберігається в БД
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Новий випадковий токен
+func newToken() (string, error) {
+	b := make([]byte, TOKEN_LENGTH)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Додати користувача API, у відповіді - його токен. Якщо користувач з таким іменем вже є - conflict,
+// новий токен існуючому користувачу видає updateApiUserToken
+func addApiUser(user *ApiUser, audit *auditEntry) (*ResponceApiUser, error) {
+	if user.Name == "" || len(user.Name) > MAX_USER_NAME_LENGTH || strings.ContainsAny(user.Name, ":") {
+		return nil, badRequest("user name must be 1-64 characters without ':'")
+	}
+	if _, ok := roleLevels[user.Role]; !ok {
+		return nil, badRequest("unknown role: %v, expected: %v, %v or %v", user.Role, ROLE_VIEWER, ROLE_EDITOR, ROLE_ADMIN)
+	}
+
+	passwordHash := ""
+	if user.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return nil, err
+		}
+		passwordHash = string(hash)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+
+	err = addApiUserDB(user, hashToken(token), passwordHash, audit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponceApiUser{user.Name, user.Role, token}, nil
+}
+
+// Видати користувачу API новий токен, попередній токен перестає діяти. Роль, пароль та стан користувача
+// не змінюються
+func updateApiUserToken(name string, audit *auditEntry) (*ResponceApiUser, error) {
+	user, err := getApiUserByNameFromDB(name)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, notFound("user not found")
+	}
+
+	token, err := newToken()
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+
+	err = updateApiUserTokenDB(name, hashToken(token), audit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponceApiUser{user.Name, user.Role, token}, nil
+}
+
+// Видалити користувача API
+func deleteApiUser(name string, audit *auditEntry) error {
+	return deleteApiUserDB(name, audit)
+}
+
+// Отримати список користувачів API
+func getApiUsers() ([]byte, error) {
+	response, err := getApiUsersFromDB()
+	if err != nil {
+		return nil, err
+	}
+
+	usersJson, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("Error convert select to ApiUsers: error=%v", err)
+		return nil, err
+	}
+
+	return usersJson, nil
+}
+
+// Створити першого адміністратора (або видати новий токен існуючому адміністратору) та вивести його токен.
+// Викликається з командного рядка: backend --create-admin=<ім'я>
+func CreateAdmin(name string) error {
+	setup()
+	openDB()
+	defer closeDB()
+
+	user, err := getApiUserByNameFromDB(name)
+	if err != nil {
+		return err
+	}
+
+	// в журнал аудиту записується без користувача та адреси
+	var response *ResponceApiUser
+	switch {
+	case user == nil:
+		response, err = addApiUser(&ApiUser{Name: name, Role: ROLE_ADMIN}, &auditEntry{Action: AUDIT_USER_APPEND, Target: name})
+	case user.Role != ROLE_ADMIN || !user.Enable:
+		return conflict("user %v exists and is not an enabled admin", name)
+	default:
+		response, err = updateApiUserToken(name, &auditEntry{Action: AUDIT_USER_TOKEN, Target: name})
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Warnf("created admin user: %v", name)
+	fmt.Printf("user: %s\nrole: %s\ntoken: %s\n", response.Name, response.Role, response.Token)
+
+	return nil
+}
+
+// Оброблювач запиту на отримання всіх користувачів API
+func getUsersHandler(w http.ResponseWriter, r *http.Request) {
+	usersJson, err := getApiUsers()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(usersJson)
+}
+
+// Оброблювач запиту на додавання користувача API. У відповідь виводиться токен користувача
+func appendUserHandler(w http.ResponseWriter, r *http.Request) {
+	// тіло запиту містить пароль, тому не логується (на відміну від parseBody)
+	var user ApiUser
+	err := json.NewDecoder(r.Body).Decode(&user)
+	if err != nil {
+		writeError(w, r, badRequest("invalid json: %v", err))
+		return
+	}
+
+	response, err := addApiUser(&user, newAuditEntry(r, AUDIT_USER_APPEND, user.Name))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	userJson, err := json.Marshal(response)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(userJson)
+
+	log.Warnf("appended api user: %v, role: %v, by: %v", user.Name, user.Role, requestUser(r).Name)
+}
+
+// Оброблювач запиту на видачу нового токена користувачу API. У відповідь виводиться новий токен
+func updateUserTokenHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	response, err := updateApiUserToken(name, newAuditEntry(r, AUDIT_USER_TOKEN, name))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	userJson, err := json.Marshal(response)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(userJson)
+
+	log.Warnf("updated api user token: %v, by: %v", name, requestUser(r).Name)
+}
+
+// Оброблювач запиту на видалення користувача API
+func deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	err := deleteApiUser(name, newAuditEntry(r, AUDIT_USER_DELETE, name))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	log.Warnf("deleted api user: %v, by: %v", name, requestUser(r).Name)
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+)
+
+// Типові показники плейлиста: для кожного віку відео (часу з публікації) рахуються перцентилі переглядів
+// останніх baselineVideos відео плейлиста. Показники перераховуються у фоні кожні periodBaseline та
+// зберігаються в пам'яті, для плейлиста, якого ще немає в кеші, розраховуються при першому запиті
+
+// Мінімальна кількість відео з вимірами у вузлі сітки, з якої рахуються перцентилі
+const MIN_BASELINE_VIDEOS = 3
+
+// Розраховані показники плейлиста
+type playlistBaseline struct {
+	response *ResponceBaseline
+
+	// Відповідь в json-форматі
+	responceJson []byte
+
+	// Перегляди кожного відео на сітці віку, для визначення позиції відео
+	series []*CompareSeries
+}
+
+var baselines = make(map[string]*playlistBaseline)
+var baselinesMux sync.RWMutex
+
+// Запустити фоновий перерахунок показників всіх активних плейлистів
+func startBaselineUpdater() {
+	go func() {
+		for {
+			updateBaselines()
+			time.Sleep(config.PeriodBaseline.Get())
+		}
+	}()
+}
+
+// Перерахувати показники всіх активних плейлистів. Якщо розрахунок плейлиста не вдався, залишаються
+// попередні показники, показники неактивних плейлистів видаляються з кешу
+func updateBaselines() {
+	playlists, err := getPlaylistsFromDB(true)
+	if err != nil {
+		log.Errorf("baseline is not updated, err=%v", err)
+		return
+	}
+
+	next := make(map[string]*playlistBaseline)
+	for _, playlist := range playlists {
+		b, err := buildBaseline(playlist.Id)
+		if err != nil {
+			log.Errorf("playlist: %v, baseline is not updated, err=%v", playlist.Id, err)
+
+			baselinesMux.RLock()
+			b = baselines[playlist.Id]
+			baselinesMux.RUnlock()
+			if b == nil {
+				continue
+			}
+		}
+		next[playlist.Id] = b
+	}
+
+	baselinesMux.Lock()
+	baselines = next
+	baselinesMux.Unlock()
+
+	log.Infof("baseline updated, playlists: %v", len(next))
+}
+
+// Показники плейлиста з кешу, якщо їх немає - розрахувати
+func getPlaylistBaseline(playlistId string) (*playlistBaseline, error) {
+	baselinesMux.RLock()
+	b, ok := baselines[playlistId]
+	baselinesMux.RUnlock()
+	if ok {
+		return b, nil
+	}
+
+	b, err := buildBaseline(playlistId)
+	if err != nil {
+		return nil, err
+	}
+
+	baselinesMux.Lock()
+	baselines[playlistId] = b
+	baselinesMux.Unlock()
+
+	return b, nil
+}
+
+// Розрахувати показники плейлиста за його останніми відео
+func buildBaseline(playlistId string) (*playlistBaseline, error) {
+	videos, err := getCompareVideosFromDB(nil, playlistId, config.BaselineVideos.Get())
+	if err != nil {
+		return nil, err
+	}
+
+	grid, err := compareVideos(videos, config.PeriodCollectionCache.Get(), config.BaselineStep.Get())
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(grid.Ages)
+	response := &ResponceBaseline{
+		PlaylistId: playlistId,
+		Step:       grid.Step,
+		Videos:     len(videos),
+		TimeUpdate: time.Now(),
+		Ages:       grid.Ages,
+		Count:      make([]int, n),
+		P10:        make([]*float64, n),
+		P25:        make([]*float64, n),
+		Median:     make([]*float64, n),
+		P75:        make([]*float64, n),
+		P90:        make([]*float64, n),
+	}
+
+	for i := range grid.Ages {
+		values := baselineValues(grid.Series, i, "")
+		response.Count[i] = len(values)
+		if len(values) < MIN_BASELINE_VIDEOS {
+			continue
+		}
+
+		response.P10[i] = percentile(values, 10)
+		response.P25[i] = percentile(values, 25)
+		response.Median[i] = percentile(values, 50)
+		response.P75[i] = percentile(values, 75)
+		response.P90[i] = percentile(values, 90)
+	}
+
+	responceJson, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &playlistBaseline{response, responceJson, grid.Series}, nil
+}
+
+// Впорядковані перегляди відео у вузлі i, крім відео excludeId
+func baselineValues(series []*CompareSeries, i int, excludeId string) []float64 {
+	values := []float64{}
+	for _, s := range series {
+		if s.Id != excludeId && s.View[i] != nil {
+			values = append(values, *s.View[i])
+		}
+	}
+	sort.Float64s(values)
+	return values
+}
+
+// Перцентиль p впорядкованих значень з лінійною інтерполяцією між сусідніми рангами
+func percentile(sorted []float64, p float64) *float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	value := sorted[lower]
+	if lower+1 < len(sorted) {
+		value += (sorted[lower+1] - sorted[lower]) * (rank - float64(lower))
+	}
+	return &value
+}
+
+// Позиція відео відносно показників плейлиста в останньому вузлі сітки, за який є виміри відео.
+// nil - відео не належить плейлисту або в цьому вузлі замало інших відео
+func getBaselinePosition(id, playlistId string) (*BaselinePosition, error) {
+	if playlistId == "" {
+		return nil, nil
+	}
+
+	b, err := getPlaylistBaseline(playlistId)
+	if err != nil {
+		return nil, err
+	}
+
+	// виміри відео беруться з БД, а не з кешу показників, щоб позиція нового відео була актуальною
+	videos, err := getCompareVideosFromDB([]string{id}, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	step, err := time.ParseDuration(b.response.Step)
+	if err != nil {
+		return nil, err
+	}
+	age := time.Duration(b.response.Ages[len(b.response.Ages)-1]) * time.Millisecond
+	grid, err := compareVideos(videos, age, step)
+	if err != nil {
+		return nil, err
+	}
+	series := grid.Series[0]
+
+	i := len(series.View) - 1
+	for i >= 0 && series.View[i] == nil {
+		i--
+	}
+	if i < 0 {
+		return nil, nil
+	}
+
+	values := baselineValues(b.series, i, id)
+	if len(values) < MIN_BASELINE_VIDEOS {
+		return nil, nil
+	}
+
+	view := *series.View[i]
+	var below float64
+	for _, v := range values {
+		if v < view {
+			below++
+		} else if v == view {
+			below += 0.5
+		}
+	}
+	position := &BaselinePosition{
+		Age:        b.response.Ages[i],
+		View:       view,
+		Percentile: below / float64(len(values)) * 100,
+		Videos:     len(values),
+	}
+
+	switch {
+	case position.Percentile < 10:
+		position.Band = "<p10"
+	case position.Percentile < 25:
+		position.Band = "p10-p25"
+	case position.Percentile < 50:
+		position.Band = "p25-p50"
+	case position.Percentile < 75:
+		position.Band = "p50-p75"
+	case position.Percentile < 90:
+		position.Band = "p75-p90"
+	default:
+		position.Band = ">p90"
+	}
+
+	return position, nil
+}
+
+// Отримати типові показники плейлиста в json-форматі
+func getBaseline(playlistId string) ([]byte, error) {
+	log.Debugf("getBaseline(playlistId: %v)", playlistId)
+	if playlistId == "" {
+		return nil, badRequest("playlist id is null")
+	}
+
+	b, err := getPlaylistBaseline(playlistId)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.responceJson, nil
+}
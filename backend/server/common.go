@@ -1,12 +1,15 @@
-package server
-
-import (
-	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
-	"go.uber.org/zap"
-)
-
-var log *zap.SugaredLogger
-
-func init() {
-	log = config.Logger
-}
+package server
+
+import (
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+	"go.uber.org/zap"
+)
+
+var log *zap.SugaredLogger
+
+// Підготувати пакет до роботи після завантаження налаштувань (config.Load): логер та кеші.
+// Викликається з StartService, Migrate та CreateAdmin
+func setup() {
+	log = config.Logger
+	initCache()
+}
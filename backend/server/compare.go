@@ -0,0 +1,112 @@
+package server
+
+import (
+	"time"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+)
+
+// Порівняння відео за віком: метрики кожного відео переносяться на шкалу часу з публікації та
+// інтерполюються на спільну сітку, щоб криві різних відео накладались одна на одну
+
+// Максимальна кількість відео в порівнянні
+const MAX_COMPARE_VIDEOS = 20
+
+// Кількість відео плейлиста за замовчуванням: останнє та десять попередніх
+const DEFAULT_COMPARE_COUNT = 11
+
+// Максимальна кількість вузлів сітки, baselineStep перевіряється з тим же обмеженням
+const MAX_COMPARE_POINTS = config.MAX_COMPARE_POINTS
+
+// Відео для порівняння з вимірами метрик, впорядкованими за часом
+type compareVideo struct {
+	id          string
+	title       string
+	publishedAt time.Time
+	metrics     []*Metrics
+}
+
+// Побудувати сітку та перенести на неї метрики відео. age = 0 - до найбільшого віку, за який є виміри;
+// step = 0 - крок 1h, який збільшується, якщо вузлів більше MAX_COMPARE_POINTS
+func compareVideos(videos []*compareVideo, age, step time.Duration) (*ResponceCompare, error) {
+	if age == 0 {
+		for _, v := range videos {
+			if len(v.metrics) > 0 {
+				if a := v.metrics[len(v.metrics)-1].Time.Sub(v.publishedAt); a > age {
+					age = a
+				}
+			}
+		}
+	}
+
+	if step == 0 {
+		step = time.Hour
+		if age/step >= MAX_COMPARE_POINTS {
+			step = (age/(MAX_COMPARE_POINTS-1) + time.Minute - 1).Truncate(time.Minute)
+		}
+	} else if age/step >= MAX_COMPARE_POINTS {
+		return nil, badRequest("too many points: age / step must be less than %v", MAX_COMPARE_POINTS)
+	}
+
+	ages := []int64{}
+	for a := time.Duration(0); a <= age; a += step {
+		ages = append(ages, int64(a/time.Millisecond))
+	}
+
+	response := &ResponceCompare{Step: step.String(), Ages: ages, Series: []*CompareSeries{}}
+	for _, v := range videos {
+		series := &CompareSeries{
+			Id:          v.id,
+			Title:       v.title,
+			PublishedAt: v.publishedAt,
+			View:        make([]*float64, len(ages)),
+			Like:        make([]*float64, len(ages)),
+			Dislike:     make([]*float64, len(ages)),
+			Comment:     make([]*float64, len(ages)),
+		}
+
+		// виміри та вузли сітки впорядковані, тому проходимо їх одночасно
+		j := 0
+		for i := range ages {
+			t := v.publishedAt.Add(time.Duration(ages[i]) * time.Millisecond)
+			for j < len(v.metrics)-1 && v.metrics[j+1].Time.Before(t) {
+				j++
+			}
+			if j >= len(v.metrics)-1 {
+				// останній вимір збігається з вузлом
+				if len(v.metrics) > 0 && v.metrics[len(v.metrics)-1].Time.Equal(t) {
+					m := v.metrics[len(v.metrics)-1]
+					setComparePoint(series, i, m, m, 0)
+				}
+				continue
+			}
+
+			from, to := v.metrics[j], v.metrics[j+1]
+			if t.Before(from.Time) {
+				continue
+			}
+			var k float64
+			if span := to.Time.Sub(from.Time); span > 0 {
+				k = float64(t.Sub(from.Time)) / float64(span)
+			}
+			setComparePoint(series, i, from, to, k)
+		}
+
+		response.Series = append(response.Series, series)
+	}
+
+	return response, nil
+}
+
+// Записати у вузол i значення, лінійно інтерпольоване між вимірами from та to (k - частка інтервалу)
+func setComparePoint(series *CompareSeries, i int, from, to *Metrics, k float64) {
+	series.View[i] = interpolate(from.ViewCount, to.ViewCount, k)
+	series.Like[i] = interpolate(from.LikeCount, to.LikeCount, k)
+	series.Dislike[i] = interpolate(from.DislikeCount, to.DislikeCount, k)
+	series.Comment[i] = interpolate(from.CommentCount, to.CommentCount, k)
+}
+
+func interpolate(from, to uint64, k float64) *float64 {
+	value := float64(from) + (float64(to)-float64(from))*k
+	return &value
+}
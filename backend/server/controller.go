@@ -11,33 +11,49 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
 const CONTENT_TYPE_KEY = "Content-Type"
 const CONTENT_TYPE_VALUE = "application/json"
 
-var version string
+// Параметри запитів вибірки коментарів
+const DEFAULT_COMMENT_INTERVAL = time.Hour
+const MIN_COMMENT_INTERVAL = time.Minute
+const DEFAULT_TOP_COMMENTS = 10
+const MAX_TOP_COMMENTS = 100
 
-func init() {
-	log = config.Logger
-}
+// Мінімальна довжина інтервалу похідних метрик за замовчуванням та найменша допустима
+const DEFAULT_DERIVED_INTERVAL = time.Hour
+const MIN_DERIVED_INTERVAL = time.Minute
+
+var version string
 
 func StartService(versionMajor, versionMin string) {
+	setup()
 	log.Warnf("server start, version: %s.%s", versionMajor, versionMin)
 	version = versionMajor + "." + versionMin
 	log.Debugf("port=%s", *config.Addr)
 
+	openDB()
+	checkSchema()
+
 	r := newRouter()
 
+	startBaselineUpdater()
+	startStream()
+
 	srv := &http.Server{
 		Addr: *config.Addr,
 		// Good practice to set timeouts to avoid Slowloris attacks.
 		WriteTimeout: time.Second * 15,
 		ReadTimeout:  time.Second * 15,
 		IdleTimeout:  time.Second * 60,
-		Handler:      r, // Pass our instance of gorilla/mux in.
+		Handler:      requestIdHandler(corsHandler(r)), // Pass our instance of gorilla/mux in.
 	}
+	// Потоки подій не завершуються самі, при зупинці сервера їх треба закрити
+	srv.RegisterOnShutdown(closeStreamSubscribers)
 
 	// Run our server in a goroutine so that it doesn't block.
 	go func() {
@@ -47,6 +63,21 @@ func StartService(versionMajor, versionMin string) {
 		}
 	}()
 
+	// Налаштування перечитуються по сигналу SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Warn("reload config")
+			changed, err := config.Reload()
+			if err != nil {
+				log.Errorf("config is not reloaded: %v", err)
+				continue
+			}
+			log.Infof("config reloaded, changed: %v", changed)
+		}
+	}()
+
 	c := make(chan os.Signal, 1)
 	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C)
 	// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught.
@@ -76,25 +107,57 @@ func newRouter() *mux.Router {
 	r := mux.NewRouter()
 	r.Use(handlerMiddleware)
 
+	r.HandleFunc("/openapi.json", getOpenApiHandler).Methods("GET").Name("openapi")
 	r.HandleFunc("/playlists", getPlaylistsHandler).Methods("GET")
 
 	if *config.ListenAdmin {
 		routeAdminPlaylist := r.PathPrefix("/playlists/admin").Subrouter()
-		routeAdminPlaylist.Methods("GET").HandlerFunc(getPlaylistsHandlerAdmin)
-		routeAdminPlaylist.Methods("OPTIONS", "POST").HandlerFunc(appendPlaylistHandler)
-		routeAdminPlaylist.Path("/{id}").Methods("OPTIONS", "PUT").HandlerFunc(updatePlaylistHandler)
-		routeAdminPlaylist.Path("/{id}").Methods("OPTIONS", "DELETE").HandlerFunc(deletePlaylistHandler)
+		routeAdminPlaylist.Methods("GET").HandlerFunc(getPlaylistsHandlerAdmin).Name("playlistsAdmin")
+		routeAdminPlaylist.Methods("POST").HandlerFunc(appendPlaylistHandler).Name("appendPlaylist")
+		routeAdminPlaylist.Path("/{id}").Methods("PUT").HandlerFunc(updatePlaylistHandler).Name("updatePlaylist")
+		routeAdminPlaylist.Path("/{id}").Methods("DELETE").HandlerFunc(deletePlaylistHandler).Name("deletePlaylist")
+		routeAdminPlaylist.Path("/{id}/restore").Methods("POST").HandlerFunc(restorePlaylistHandler).Name("restorePlaylist")
+		routeAdminPlaylist.Path("/{id}/purge").Methods("POST").HandlerFunc(purgePlaylistHandler).Name("purgePlaylist")
+
+		routeAdminQuery := r.PathPrefix("/queries/admin").Subrouter()
+		routeAdminQuery.Methods("GET").HandlerFunc(getQueriesHandlerAdmin).Name("queriesAdmin")
+		routeAdminQuery.Methods("POST").HandlerFunc(appendQueryHandler).Name("appendQuery")
+		routeAdminQuery.Path("/{id:[0-9]+}").Methods("PUT").HandlerFunc(updateQueryHandler).Name("updateQuery")
+		routeAdminQuery.Path("/{id:[0-9]+}").Methods("DELETE").HandlerFunc(deleteQueryHandler).Name("deleteQuery")
+
+		routeAdmin := r.PathPrefix("/admin").Subrouter()
+		routeAdmin.Path("/config/reload").Methods("POST").HandlerFunc(reloadConfigHandler).Name("reloadConfig")
+		routeAdmin.Path("/users").Methods("GET").HandlerFunc(getUsersHandler).Name("users")
+		routeAdmin.Path("/users").Methods("POST").HandlerFunc(appendUserHandler).Name("appendUser")
+		routeAdmin.Path("/users/{name}/token").Methods("POST").HandlerFunc(updateUserTokenHandler).Name("updateUserToken")
+		routeAdmin.Path("/users/{name}").Methods("DELETE").HandlerFunc(deleteUserHandler).Name("deleteUser")
+		routeAdmin.Path("/audit").Methods("GET").HandlerFunc(getAuditHandler).Name("audit")
 	}
 
 	routeVideo := r.PathPrefix("/view").Subrouter()
 	routeVideo.Path("/counts").Methods("GET").HandlerFunc(getGlobalCountsHandler)
 	routeVideo.Path("/videos").Methods("GET").HandlerFunc(getVidesHandler)
 	routeVideo.Path("/videos/{id}").Methods("GET").HandlerFunc(getVideoByIdPlayListHandler)
+	routeVideo.Path("/search").Methods("GET").HandlerFunc(searchVideosHandler)
+	routeVideo.Path("/top").Methods("GET").HandlerFunc(getTopHandler)
+	routeVideo.Path("/stream").Methods("GET").HandlerFunc(getStreamHandler)
+	routeVideo.Path("/compare").Methods("GET").HandlerFunc(getCompareHandler)
+	routeVideo.Path("/baseline/{id}").Methods("GET").HandlerFunc(getBaselineHandler)
 	routeVideo.Path("/video/{id}").Methods("GET").HandlerFunc(getVideoByIdHandler)
 	routeVideo.Path("/metrics/{id}").Methods("GET").HandlerFunc(getMetricsByVideoIdHandler)
+	routeVideo.Path("/metrics/{id}/derived").Methods("GET").HandlerFunc(getDerivedMetricsHandler)
+	routeVideo.Path("/comments/{id}/rate").Methods("GET").HandlerFunc(getCommentRateHandler)
+	routeVideo.Path("/comments/{id}/top").Methods("GET").HandlerFunc(getTopCommentsHandler)
+	routeVideo.Path("/queries").Methods("GET").HandlerFunc(getQueriesHandler)
+	routeVideo.Path("/queries/{id:[0-9]+}/ranks").Methods("GET").HandlerFunc(getQueryRanksHandler)
 
 	printRouter(r)
 
+	// маршрути повинні відповідати специфікації API, розбіжності перевіряє тест (openapi_test.go)
+	for _, err := range checkOpenApi(r) {
+		log.Warn(err)
+	}
+
 	return r
 }
 
@@ -129,31 +192,16 @@ func printRouter(r *mux.Router) {
 	}
 }
 
-// Перехоплення та обробка всіх запитів
+// Перехоплення та обробка всіх запитів: логування та авторизація. CORS обробляється в corsHandler
 func handlerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		method := r.Method
-		origin := r.Header.Get("Origin")
-		log.Infof("method: %v, uri: %v, addr: %v, origin: [%v], host: %v", method, r.RequestURI, r.RemoteAddr, origin, r.Host)
-
-		// Перевірка на валідний Origin  
-		if origin == *config.Origin {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-
-			if method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-			} else {
-				// Call the next handler, which can be another middleware in the chain, or the final handler.
-				next.ServeHTTP(w, r)
-			}
-		} else {
-			log.Errorf("origin is not: [%v] ", *config.Origin)
-			http.Error(w, "Access denied", http.StatusForbidden)
-			return
-		}
+		log.Infof("method: %v, uri: %v, addr: %v, origin: [%v], host: %v, request_id: %v", r.Method, r.RequestURI,
+			r.RemoteAddr, r.Header.Get("Origin"), r.Host, getRequestId(r))
 
+		if r = authorize(w, r); r != nil {
+			// Call the next handler, which can be another middleware in the chain, or the final handler.
+			next.ServeHTTP(w, r)
+		}
 	})
 }
 
@@ -167,7 +215,7 @@ func parseBody(r *http.Request, i interface{}) error {
 
 	err = json.Unmarshal(b, i)
 	if err != nil {
-		return err
+		return badRequest("invalid json: %v", err)
 	}
 
 	return nil
@@ -182,15 +230,15 @@ func appendPlaylistHandler(w http.ResponseWriter, r *http.Request) {
 	var playlist PlayList
 	err := parseBody(r, &playlist)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	log.Debugf("playlist=%v", playlist)
 
-	err = addPlayList(&playlist)
+	err = addPlayList(&playlist, newPlaylistAuditEntry(r, AUDIT_PLAYLIST_APPEND, playlist.Id))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -211,15 +259,15 @@ func updatePlaylistHandler(w http.ResponseWriter, r *http.Request) {
 	var playlist PlayList
 	err := parseBody(r, &playlist)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	log.Debugf("playlist=%v", playlist)
 
-	err = updatePlayList(id, &playlist)
+	err = updatePlayList(id, &playlist, newPlaylistAuditEntry(r, AUDIT_PLAYLIST_UPDATE, id))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -237,15 +285,100 @@ func deletePlaylistHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Debugf("req=%v(%v)", req, formatStringDate(req))
 
-	err := deletePlayList(id)
+	err := deletePlayList(id, newPlaylistAuditEntry(r, AUDIT_PLAYLIST_ARCHIVE, id))
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	log.Infof("deleted playlist with id=%v", id)
+	log.Infof("archived playlist with id=%v", id)
+}
+
+// Оброблювач запиту на повернення плей-листа з архіву
+func restorePlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	q := r.URL.Query()
+	req := q.Get("req")
+
+	log.Debugf("req=%v(%v)", req, formatStringDate(req))
+
+	err := restorePlayList(id, newPlaylistAuditEntry(r, AUDIT_PLAYLIST_RESTORE, id))
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	log.Infof("restored playlist with id=%v", id)
+}
+
+// Оброблювач запиту на очищення архівного плей-листа. Без dryrun=false тільки рахуються рядки, які будуть видалені
+func purgePlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	q := r.URL.Query()
+	req := q.Get("req")
+
+	log.Debugf("req=%v(%v)", req, formatStringDate(req))
+
+	dryRun := true
+	if value := q.Get("dryrun"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, r, badRequest("dryrun is not a boolean: %v", value))
+			return
+		}
+	}
+
+	response, err := purgePlayList(id, dryRun, newPlaylistAuditEntry(r, AUDIT_PLAYLIST_PURGE, id))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	purgeJson, err := json.Marshal(response)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(purgeJson)
+	log.Infof("purge playlist with id=%v, dryrun=%v", id, dryRun)
+}
+
+// Оброблювач запиту на перечитування налаштувань з ini-файлу без перезапуску сервера
+func reloadConfigHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := q.Get("req")
+	log.Debugf("req=%v(%v)", req, formatStringDate(req))
+
+	changed, err := config.Reload()
+	if err != nil {
+		log.Errorf("config is not reloaded: %v", err)
+		writeError(w, r, badRequest("%v", err))
+		return
+	}
+
+	reloadJson, err := json.Marshal(&ResponceReload{changed})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(reloadJson)
+
+	log.Warnf("config reloaded, changed: %v", changed)
 }
 
 func formatStringDate(sdt string) string {
@@ -267,7 +400,7 @@ func getPlaylistsHandler(w http.ResponseWriter, r *http.Request) {
 	playlistJson, err := getPlaylists(true)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -287,7 +420,7 @@ func getPlaylistsHandlerAdmin(w http.ResponseWriter, r *http.Request) {
 	playlistJson, err := getPlaylists(false)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -298,13 +431,38 @@ func getPlaylistsHandlerAdmin(w http.ResponseWriter, r *http.Request) {
 	w.Write(playlistJson)
 }
 
+// Параметри проріджування метрик: points - кількість точок (за замовчуванням metricPoints, не більше
+// maxMetricPoints), algo - алгоритм (lttb, minmax, avg)
+func downsampleParams(r *http.Request) (int, string, error) {
+	q := r.URL.Query()
+
+	points := config.MetricPoints.Get()
+	if s := q.Get("points"); s != "" {
+		var err error
+		points, err = strconv.Atoi(s)
+		if err != nil || points < MIN_POINTS || points > config.MaxMetricPoints.Get() {
+			return 0, "", badRequest("points must be from %v to %v", MIN_POINTS, config.MaxMetricPoints.Get())
+		}
+	}
+
+	algo := DEFAULT_ALGO
+	if s := q.Get("algo"); s != "" {
+		if !validAlgo(s) {
+			return 0, "", badRequest("algo must be one of %v, %v, %v", ALGO_LTTB, ALGO_MINMAX, ALGO_AVG)
+		}
+		algo = s
+	}
+
+	return points, algo, nil
+}
+
 // Оброблювач запиту на отриматння метрик по відео id за заданий період
 func getMetricsByVideoIdHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
 	id := vars["id"]
 	if id == "" {
-		http.Error(w, "video id is null", http.StatusInternalServerError)
+		writeError(w, r, badRequest("video id is null"))
 		return
 	}
 
@@ -314,10 +472,26 @@ func getMetricsByVideoIdHandler(w http.ResponseWriter, r *http.Request) {
 	to := q.Get("to")
 	log.Debugf("req=%v(%v), id=%v, from=%v, to=%v", req, formatStringDate(req), id, from, to)
 
-	metricsVideoJson, err := getMetricsById(id, from, to)
+	points, algo, err := downsampleParams(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	format, raw, export, err := exportParams(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if export {
+		exportMetrics(w, r, format, id, from, to, raw, points, algo)
+		return
+	}
+
+	metricsVideoJson, err := getMetricsById(id, from, to, points, algo)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -328,13 +502,136 @@ func getMetricsByVideoIdHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(metricsVideoJson)
 }
 
+// Оброблювач запиту похідних метрик відео (прирости, швидкості, залученість) за заданий період.
+// interval - мінімальна довжина інтервалу між вимірами, за замовчуванням 1h
+func getDerivedMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	q := r.URL.Query()
+	req := q.Get("req")
+	from := q.Get("from")
+	to := q.Get("to")
+
+	interval := DEFAULT_DERIVED_INTERVAL
+	if s := q.Get("interval"); s != "" {
+		var err error
+		interval, err = time.ParseDuration(s)
+		if err != nil || interval < MIN_DERIVED_INTERVAL {
+			writeError(w, r, badRequest("interval must be a duration of at least %v", MIN_DERIVED_INTERVAL))
+			return
+		}
+	}
+	log.Debugf("req=%v(%v), id=%v, interval=%v, from=%v, to=%v", req, formatStringDate(req), id, interval, from, to)
+
+	derivedJson, err := getDerivedMetrics(id, interval, from, to)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(derivedJson)
+}
+
+// Параметр запиту з тривалістю не менше хвилини, 0 - параметр не заданий
+func durationParam(r *http.Request, name string) (time.Duration, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Minute {
+		return 0, badRequest("%v must be a duration of at least 1m", name)
+	}
+	return d, nil
+}
+
+// Оброблювач запиту порівняння відео за віком. Відео задаються списком ids (через кому) або плейлистом
+// playlist та кількістю останніх відео count (за замовчуванням 11). age - до якого віку порівнювати,
+// step - крок сітки
+func getCompareHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := q.Get("req")
+	playlistId := q.Get("playlist")
+
+	ids := []string{}
+	for _, id := range strings.Split(q.Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	if (len(ids) == 0) == (playlistId == "") {
+		writeError(w, r, badRequest("either ids or playlist must be set"))
+		return
+	}
+	if len(ids) > MAX_COMPARE_VIDEOS {
+		writeError(w, r, badRequest("at most %v videos can be compared", MAX_COMPARE_VIDEOS))
+		return
+	}
+
+	count := DEFAULT_COMPARE_COUNT
+	if s := q.Get("count"); s != "" {
+		var err error
+		count, err = strconv.Atoi(s)
+		if err != nil || count < 1 || count > MAX_COMPARE_VIDEOS {
+			writeError(w, r, badRequest("count must be from 1 to %v", MAX_COMPARE_VIDEOS))
+			return
+		}
+	}
+
+	age, err := durationParam(r, "age")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	step, err := durationParam(r, "step")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	log.Debugf("req=%v(%v), ids=%v, playlist=%v, count=%v, age=%v, step=%v", req, formatStringDate(req),
+		ids, playlistId, count, age, step)
+
+	compareJson, err := getCompare(ids, playlistId, count, age, step)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(compareJson)
+}
+
+// Оброблювач запиту типових показників плейлиста id
+func getBaselineHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	req := r.URL.Query().Get("req")
+	log.Debugf("req=%v(%v), id=%v", req, formatStringDate(req), id)
+
+	baselineJson, err := getBaseline(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(baselineJson)
+}
+
 // Оброблювач запиту даних по відео id
 func getVideoByIdHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
 	id := vars["id"]
 	if id == "" {
-		http.Error(w, "video id is null", http.StatusInternalServerError)
+		writeError(w, r, badRequest("video id is null"))
 		return
 	}
 
@@ -345,7 +642,7 @@ func getVideoByIdHandler(w http.ResponseWriter, r *http.Request) {
 	videoJson, err := getVideoById(id)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -370,10 +667,26 @@ func getVidesHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Debugf("req=%v(%v), offset=%v", req, formatStringDate(req), offset)
 
-	videosJson, err := getVideos(offset)
+	filter, err := parseVideoFilter(q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	format, raw, export, err := exportParams(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if export {
+		exportVideos(w, r, format, "", filter, offset, raw)
+		return
+	}
+
+	videosJson, err := getVideos(filter, offset)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -401,10 +714,26 @@ func getVideoByIdPlayListHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Debugf("req=%v(%v), id=%v, offset=%v", req, formatStringDate(req), id, offset)
 
-	videosJson, err := getVideosByPlayListId(id, offset)
+	filter, err := parseVideoFilter(q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 
+	format, raw, export, err := exportParams(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
+		return
+	}
+	if export {
+		exportVideos(w, r, format, id, filter, offset, raw)
+		return
+	}
+
+	videosJson, err := getVideosByPlayListId(id, filter, offset)
+
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
@@ -425,7 +754,7 @@ func getGlobalCountsHandler(w http.ResponseWriter, r *http.Request) {
 	globalCountsJson, err := getGlobalCounts(version)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -435,3 +764,199 @@ func getGlobalCountsHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(globalCountsJson)
 }
+
+// Оброблювач запиту на додавання запиту для відстеження
+func appendQueryHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := q.Get("req")
+	log.Debugf("req=%v(%v)", req, formatStringDate(req))
+
+	var query Query
+	err := parseBody(r, &query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	log.Debugf("query=%v", query)
+
+	err = addQuery(&query, newAuditEntry(r, AUDIT_QUERY_APPEND, ""))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	log.Warnf("appended query=%v", query)
+}
+
+// Оброблювач запиту на оновлення запиту для відстеження
+func updateQueryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		writeError(w, r, badRequest("invalid id: %v", vars["id"]))
+		return
+	}
+
+	q := r.URL.Query()
+	req := q.Get("req")
+	log.Debugf("req=%v(%v)", req, formatStringDate(req))
+
+	var query Query
+	err = parseBody(r, &query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	log.Debugf("query=%v", query)
+
+	err = updateQuery(id, &query, newAuditEntry(r, AUDIT_QUERY_UPDATE, vars["id"]))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	log.Infof("updated query=%v", query)
+}
+
+// Оброблювач запиту на видалення запиту для відстеження
+func deleteQueryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		writeError(w, r, badRequest("invalid id: %v", vars["id"]))
+		return
+	}
+
+	q := r.URL.Query()
+	req := q.Get("req")
+	log.Debugf("req=%v(%v)", req, formatStringDate(req))
+
+	err = deleteQuery(id, newAuditEntry(r, AUDIT_QUERY_DELETE, vars["id"]))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	log.Infof("deleted query with id=%v", id)
+}
+
+// Оброблювач запиту на отримання всіх активних запитів
+func getQueriesHandler(w http.ResponseWriter, r *http.Request) {
+	writeQueries(w, r, true)
+}
+
+// Оброблювач запиту на отримання всіх запитів для адміністрування
+func getQueriesHandlerAdmin(w http.ResponseWriter, r *http.Request) {
+	writeQueries(w, r, false)
+}
+
+func writeQueries(w http.ResponseWriter, r *http.Request, onlyEnable bool) {
+	q := r.URL.Query()
+	req := q.Get("req")
+	log.Debugf("req=%v(%v)", req, formatStringDate(req))
+
+	queriesJson, err := getQueries(onlyEnable)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(queriesJson)
+}
+
+// Оброблювач запиту на отримання історії позицій відео в результатах запиту за заданий період
+func getQueryRanksHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		writeError(w, r, badRequest("invalid id: %v", vars["id"]))
+		return
+	}
+
+	q := r.URL.Query()
+	req := q.Get("req")
+	from := q.Get("from")
+	to := q.Get("to")
+	log.Debugf("req=%v(%v), id=%v, from=%v, to=%v", req, formatStringDate(req), id, from, to)
+
+	ranksJson, err := getQueryRanks(id, from, to)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(ranksJson)
+}
+
+// Оброблювач запиту на отримання кількості коментарів відео за інтервали (interval, за замовчуванням 1h)
+// за заданий період
+func getCommentRateHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	q := r.URL.Query()
+	req := q.Get("req")
+	from := q.Get("from")
+	to := q.Get("to")
+
+	interval := DEFAULT_COMMENT_INTERVAL
+	if s := q.Get("interval"); s != "" {
+		var err error
+		interval, err = time.ParseDuration(s)
+		if err != nil || interval < MIN_COMMENT_INTERVAL {
+			writeError(w, r, badRequest("interval must be a duration of at least %v", MIN_COMMENT_INTERVAL))
+			return
+		}
+	}
+	log.Debugf("req=%v(%v), id=%v, interval=%v, from=%v, to=%v", req, formatStringDate(req), id, interval, from, to)
+
+	rateJson, err := getCommentRate(id, interval, from, to)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(rateJson)
+}
+
+// Оброблювач запиту на отримання найпопулярніших коментарів відео (limit, за замовчуванням 10)
+func getTopCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	q := r.URL.Query()
+	req := q.Get("req")
+
+	limit := DEFAULT_TOP_COMMENTS
+	if s := q.Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > MAX_TOP_COMMENTS {
+			writeError(w, r, badRequest("limit must be between 1 and %v", MAX_TOP_COMMENTS))
+			return
+		}
+	}
+	log.Debugf("req=%v(%v), id=%v, limit=%v", req, formatStringDate(req), id, limit)
+
+	commentsJson, err := getTopComments(id, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(commentsJson)
+}
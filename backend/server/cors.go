@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+)
+
+// Обробка CORS (Cross-Origin Resource Sharing). CORS стосується тільки браузерів: запити без заголовка Origin
+// (скрипти, curl, інші сервіси) пропускаються без змін, доступ до даних перевіряється авторизацією (див. auth.go).
+// Запити з браузера з недозволеного джерела відхиляються з кодом 403
+
+const CORS_ALLOW_METHODS = "GET, POST, PUT, DELETE, OPTIONS"
+const CORS_ALLOW_HEADERS = "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization"
+
+// Обгортка над маршрутизатором: відповідає на попередні (preflight) запити для будь-якого маршруту
+// та додає CORS-заголовки до відповіді
+func corsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// відповідь залежить від Origin, проміжні кеші повинні це враховувати
+		w.Header().Add("Vary", "Origin")
+
+		if !allowedOrigin(origin) {
+			log.Warnf("origin is not allowed: [%v], uri: %v, addr: %v", origin, r.RequestURI, r.RemoteAddr)
+			writeError(w, r, newApiError(http.StatusForbidden, ERR_ORIGIN_NOT_ALLOWED, "origin is not allowed"))
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if config.CorsCredentials.Get() {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		// попередній запит браузера перед основним: авторизація не потрібна, браузер не передає її в preflight
+		if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", CORS_ALLOW_METHODS)
+			w.Header().Set("Access-Control-Allow-Headers", CORS_ALLOW_HEADERS)
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(config.CorsMaxAge.Get().Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Чи дозволене джерело запиту
+func allowedOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range config.Origins(config.Origin.Get()) {
+		if pattern == "*" {
+			return true
+		}
+		if ok, _ := path.Match(strings.ToLower(pattern), origin); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+)
+
+func TestCorsHandler(t *testing.T) {
+	origin, credentials := config.Origin.String(), config.CorsCredentials.String()
+	config.Origin.Set("https://example.com, https://*.example.com")
+	config.CorsCredentials.Set("true")
+	defer func() {
+		config.Origin.Set(origin)
+		config.CorsCredentials.Set(credentials)
+	}()
+
+	tests := []struct {
+		name      string
+		method    string
+		origin    string
+		preflight bool
+		status    int
+		allowed   bool
+		vary      []string
+	}{
+		{"no origin", "GET", "", false, http.StatusOK, false, nil},
+		{"no origin preflight", "OPTIONS", "", true, http.StatusOK, false, nil},
+		{"allowed", "GET", "https://example.com", false, http.StatusOK, true, []string{"Origin"}},
+		{"allowed by pattern", "GET", "https://App.Example.com", false, http.StatusOK, true, []string{"Origin"}},
+		{"allowed preflight", "OPTIONS", "https://example.com", true, http.StatusNoContent, true,
+			[]string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}},
+		{"allowed options without preflight", "OPTIONS", "https://example.com", false, http.StatusOK, true,
+			[]string{"Origin"}},
+		{"not allowed", "GET", "https://example.org", false, http.StatusForbidden, false, []string{"Origin"}},
+		{"not allowed preflight", "OPTIONS", "https://example.org", true, http.StatusForbidden, false, []string{"Origin"}},
+		{"not allowed by pattern", "GET", "https://app.example.com.evil", false,
+			http.StatusForbidden, false, []string{"Origin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := corsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			r := httptest.NewRequest(tt.method, "/view/videos", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", "GET")
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status || called != (tt.status == http.StatusOK) {
+				t.Errorf("status = %v, handler called = %v, want %v", w.Code, called, tt.status)
+			}
+			if !equalStrings(w.Header().Values("Vary"), tt.vary) {
+				t.Errorf("Vary = %v, want %v", w.Header().Values("Vary"), tt.vary)
+			}
+
+			allowOrigin := w.Header().Get("Access-Control-Allow-Origin")
+			if tt.allowed && (allowOrigin != tt.origin || w.Header().Get("Access-Control-Allow-Credentials") != "true") {
+				t.Errorf("Access-Control-Allow-Origin = %q, credentials = %q", allowOrigin,
+					w.Header().Get("Access-Control-Allow-Credentials"))
+			}
+			if !tt.allowed && allowOrigin != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q for origin %q", allowOrigin, tt.origin)
+			}
+
+			if preflight := w.Code == http.StatusNoContent; preflight != (w.Header().Get("Access-Control-Allow-Methods") != "") {
+				t.Errorf("Access-Control-Allow-Methods = %q", w.Header().Get("Access-Control-Allow-Methods"))
+			}
+
+			if w.Code == http.StatusForbidden {
+				e := &ResponceError{}
+				if err := json.Unmarshal(w.Body.Bytes(), e); err != nil || e.Code != ERR_ORIGIN_NOT_ALLOWED {
+					t.Errorf("error = %+v, %v", e, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowedOrigin(t *testing.T) {
+	origin := config.Origin.String()
+	defer config.Origin.Set(origin)
+
+	tests := []struct {
+		origins string
+		origin  string
+		want    bool
+	}{
+		{"*", "https://example.org", true},
+		{"https://example.com", "https://example.com", true},
+		{"https://example.com", "HTTPS://EXAMPLE.COM", true},
+		{"https://example.com", "http://example.com", false},
+		{"https://example.com", "https://example.com:8080", false},
+		{"https://*.example.com", "https://app.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://example.com, http://localhost:*", "http://localhost:3000", true},
+		{"", "https://example.com", false},
+	}
+
+	for _, tt := range tests {
+		config.Origin.Set(tt.origins)
+		if got := allowedOrigin(tt.origin); got != tt.want {
+			t.Errorf("allowedOrigin(%q) with Origin = %q is %v, want %v", tt.origin, tt.origins, got, tt.want)
+		}
+	}
+}
@@ -1,377 +1,1693 @@
-package server
-
-import (
-	"database/sql"
-	"encoding/json"
-	"errors"
-	_ "github.com/lib/pq"
-	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
-	"strconv"
-	"strings"
-	"time"
-)
-
-// The layout defines the format by showing how the reference time, defined to be.
-// timestamp with time zone;
-const TIME_LAYOUT = "2006-01-02T15:04:05.999999-07:00"
-
-const INSERT_PLAYLIST = "INSERT INTO playlist ( id, title, enable, idch ) VALUES ( $1, $2, $3, $4)"
-const UPDATE_PLAYLIST = "UPDATE playlist SET title=$2, enable=$3, idch=$4 WHERE id = $1"
-const DELETE_PLAYLIST = "DELETE FROM playlist WHERE id = $1"
-const GET_PLAYLISTS = "SELECT id, TRIM(title), enable, idch, timeadd, countvideo FROM playlist ORDER BY title"
-const GET_PLAYLISTS_ENABLE = "SELECT id, TRIM(title), enable, idch, timeadd, countvideo FROM playlist " +
-	"WHERE enable = true ORDER BY title"
-const GET_METRICS_BY_IDVIDEO = "Select * FROM return_metrics($1)"
-const GET_METRICS_BY_IDVIDEO_BETWEEN_DATE = "Select * FROM return_metrics($1, $2, $3)"
-
-const GET_VIDEO_BY_ID = "SELECT * FROM return_video($1)"
-
-const GET_VIDEOS= "SELECT v.id, TRIM(v.title), v.publishedat, TRIM(p.title) as ptitle FROM video v" +
-	" LEFT JOIN playlist p ON p.id = v.idpl" +
-	" WHERE p.enable = true ORDER BY publishedat DESC LIMIT $1 OFFSET $2"
-	
-const GET_VIDEOS_BY_ID_PLAYLIST = "SELECT id, TRIM(title), publishedat, '' ptitle FROM video v" + 
-	" WHERE idpl = $1 " +
-	" ORDER BY publishedat DESC LIMIT $2 OFFSET $3"
-	
-const GET_GLOBAL_COUNTS = "select count(*) as count, SUM(countvideo) as countvideo FROM playlist WHERE enable = TRUE"	
-
-const NO_DATA = "No data"
-
-// creat connections string
-// example: host=127.0.0.100 port=5432 dbname=base1 user=user1 password=lalala sslmode=disable"
-var connStrForDatabse string
-
-var db *sql.DB
-var errDB error
-
-// формуємо підключення до Бази Даних (БД), підключення відкрита на протязі всієї роботи програми
-func init() {
-	// creat connections string
-	// example: host=127.0.0.100 port=5432 dbname=base1 user=user1 password=lalala sslmode=disable"
-	connStrForDatabse = "host=" + *config.DBHost +
-		" port=" + *config.DBPort +
-		" dbname=" + *config.DBName +
-		" user=" + *config.DBUser +
-		" password=" + *config.DBPassword +
-		" sslmode=" + *config.DBSSLMode
-
-	log.Debugf("connStr=%s", connStrForDatabse)
-
-	db, errDB = sql.Open("postgres", connStrForDatabse)
-	if errDB != nil {
-		log.Errorf("error open database: %v", errDB)
-	}
-
-	// перевірка доступності
-	err := db.Ping()
-	if err != nil { // далі робити не можна
-		log.Fatalf("error ping database: %v", err)
-	}
-
-}
-
-// коректне закриття з'єднання з БД
-func closeDB() {
-	log.Infof("close database with %v open connections", db.Stats().OpenConnections)
-
-	err := db.Close()
-	if err != nil {
-		log.Errorf("error close database: %v", err)
-	}
-}
-
-// Додати плей-лист до БД
-func addPlayListDB(playlist *PlayList) error {
-	res, err := db.Exec(INSERT_PLAYLIST, playlist.Id, playlist.Title, playlist.Enable, playlist.Idch)
-	if err != nil {
-		log.Errorf("err=%v", err)
-		return err
-	}
-
-	_, err = res.RowsAffected()
-	if err != nil {
-		log.Errorf("err=%v", err)
-		return err
-	} else {
-		log.Debugf("insert playlist: id=%v, title=%v, enable=%v, idch=%v", playlist.Id, playlist.Title, playlist.Enable, playlist.Idch)
-	}
-
-	return nil
-}
-
-// Оновити плей-лист в БД
-func updatePlayListDB(id string, playlist *PlayList) error {
-	res, err := db.Exec(UPDATE_PLAYLIST, id, playlist.Title, playlist.Enable, playlist.Idch)
-	if err != nil {
-		log.Errorf("err=%v", err)
-		return err
-	}
-
-	_, err = res.RowsAffected()
-	if err != nil {
-		log.Errorf("err=%v", err)
-		return err
-	} else {
-		log.Debugf("update playlist: id=%v, title=%v, enable=%v, idch=%v", id, playlist.Title, playlist.Enable, playlist.Idch)
-	}
-
-	return nil
-}
-
-// Видалити плей-лист з БД
-func deletePlayListDB(playlistId string) error {
-	res, err := db.Exec(DELETE_PLAYLIST, playlistId)
-	if err != nil {
-		log.Errorf("err=%v", err)
-		return err
-	}
-
-	_, err = res.RowsAffected()
-	if err != nil {
-		log.Errorf("err=%v", err)
-		return err
-	} else {
-		log.Debugf("deleted playlist: id=%v", playlistId)
-	}
-
-	return nil
-}
-
-// Отримати плейлисти
-// onlyEnable - які плейлисти вибирати
-//   true  - тільки активні, якщо плейлист не активний його треба активувати через інтерфейс адміністратора
-//   false - всі
-func getPlaylistsFromDB(onlyEnable bool) ([]PlayList, error) {
-	log.Debugf("dbstats=%v", db.Stats())
-
-	var rows *sql.Rows
-	var err error
-
-	if onlyEnable {
-		rows, err = db.Query(GET_PLAYLISTS_ENABLE)		
-	} else {
-		rows, err = db.Query(GET_PLAYLISTS)		
-	}
-	
-	if err != nil {
-		log.Errorf("Error get playlists: %v", err)
-		return nil, err
-	}
-
-	if rows == nil {
-		return nil, errors.New( NO_DATA )
-	}
-
-	defer rows.Close()
-
-	response := []PlayList{}
-
-	for rows.Next() {
-		var Id string
-		var Title string
-		var Enable bool
-		var Idch string
-		var Timeadd time.Time
-		var countvideo int
-
-		rows.Scan(&Id, &Title, &Enable, &Idch, &Timeadd, &countvideo)
-		Id = strings.TrimSpace(Id)
-		Title = strings.TrimSpace(Title)
-		Idch = strings.TrimSpace(Idch)
-
-		response = append(response, PlayList{Id, Title, Enable, Idch, Timeadd, countvideo})
-	}
-	err = rows.Err()
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-
-	log.Debugf("Success get playlists from DB")
-
-	return response, nil
-}
-
-// Отримати метрики по відео id за заданий період
-func getMetricsByIdFromDB(id string, from, to string) ([]*Metrics, error) {
-	var rows *sql.Rows
-	var err error
-
-	log.Debugf("id: %v, from: %v, to: %v", id, from, to)
-
-	// якщо період не заданий обираємо всі дані
-	if from == "" && to == "" {
-		rows, err = db.Query(GET_METRICS_BY_IDVIDEO, id)
-	} else { // обраний період
-		/* перевіряємо та форматуємо дату з якої вибираємо */
-		sFrom, err := checkDate(from)
-		if err != nil {
-			return nil, err
-		}
-
-		/* перевіряємо та форматуємо дату по яку вибираємо */
-		sTo, err := checkDate(to)
-		if err != nil {
-			return nil, err
-		}
-
-		rows, err = db.Query(GET_METRICS_BY_IDVIDEO_BETWEEN_DATE, id, sFrom, sTo)
-	}
-
-	if err != nil {
-		log.Errorf("Error get metrics: %v", err)
-		return nil, err
-	}
-
-	if rows == nil {
-		return nil, errors.New( NO_DATA )
-	}
-
-	defer rows.Close()
-	
-	response := []*Metrics{}
-
-	for rows.Next() {
-		var commentCount uint64
-		var likeCount uint64
-		var dislikeCount uint64
-		var viewCount uint64
-		var vTime time.Time
-
-		rows.Scan(&commentCount, &likeCount, &dislikeCount, &viewCount, &vTime)
-
-		response = append(response, &Metrics{commentCount, likeCount, dislikeCount, viewCount, vTime})
-	}
-	err = rows.Err()
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-
-	return response, nil
-}
-
-// Отримати опис відео по його id
-func getVideoByIdFromDB(id string) ( *YoutubeVideo, error) {
-	if id == "" {
-		return nil, errors.New("video id is null")
-	}
-
-	var idpl string
-	var title string
-	var description string
-	var chtitle string
-	var chid string
-	var publishedat time.Time
-	var count_metrics int
-	var max_timemetric time.Time
-	var min_timemetric time.Time
-
-	err := db.QueryRow(GET_VIDEO_BY_ID, id).Scan(&idpl, &title, &description, &chtitle, &chid, &publishedat, &count_metrics, &max_timemetric, &min_timemetric)
-	if err != nil {
-		log.Errorf("Error get videos by id: %v", err)
-		return nil, err
-	}
-	
-	youtubeVideo := &YoutubeVideo{strings.TrimSpace(idpl), strings.TrimSpace(title), strings.TrimSpace(description), 
-			strings.TrimSpace(chtitle), strings.TrimSpace(chid), publishedat, count_metrics, max_timemetric, min_timemetric}
-	
-	log.Debugf("id: %v, idpl: %v, title: %v, description: %v, chtitle: %v, chid: %v, publishedat: %v, count_metrics: %v, max_timemetric: %v, min_timemetric: %v", 
-			id, idpl, title, description, chtitle, chid, publishedat, count_metrics, max_timemetric, min_timemetric)
-
-	return youtubeVideo, nil
-}
-
-// Отримати список відео по id плейлиста
-func getVideosByPlayListIdFromDB(id string, offset int) ([]byte, error) {
-	log.Debugf("id: %v, offset: %v", id, offset)
-
-	var rows *sql.Rows
-	var err error
-
-	if id == "" {
-		rows, err = db.Query(GET_VIDEOS, *config.MaxViewVideosInPlayLists, offset)
-	} else {
-		rows, err = db.Query(GET_VIDEOS_BY_ID_PLAYLIST, id, *config.MaxViewVideosInPlayLists, offset)
-	}
-
-	if err != nil {
-		log.Errorf("Error get videos by plailist id: %v", err)
-		return nil, err
-	}
-
-	if rows == nil {
-		return nil, errors.New( NO_DATA )
-	}
-
-	defer rows.Close()
-
-	response := []YoutubeVideoShort{}
-
-	for rows.Next() {
-		var id string
-		var title string
-		var publishedat time.Time
-		var ptitle string
-
-		rows.Scan(&id, &title, &publishedat, &ptitle)
-
-		response = append(response, YoutubeVideoShort{id, title, publishedat, ptitle})
-	}
-	err = rows.Err()
-	if err != nil {
-		log.Error(err)
-		return nil, err
-	}
-
-	// Конвертуємо відповідь в json-формат
-	stringVideos, err := json.Marshal(response)
-
-	if err != nil {
-		log.Errorf("Error convert select to YoutubeVideoShort: response=%v, error=%v", response, err)
-		return nil, err
-	}
-
-	log.Debugf("id: %v, playlist: %v", id, string(stringVideos))
-
-	return stringVideos, nil
-}
-
-// Отримати опис відео по його id
-func getGlobalCountsFromDB(version string) ( *GlobalCounts, error) {
-	var countPlaylists int
-	var countVideos int
-
-	err := db.QueryRow(GET_GLOBAL_COUNTS).Scan(&countPlaylists, &countVideos)
-	if err != nil {
-		log.Errorf("Error get global counts: %v", err)
-		return nil, err
-	}
-	
-	globalCounts := &GlobalCounts{CountPlaylists: countPlaylists, CountVideos: countVideos, TimeUpdate: time.Now(), 
-		MaxVideoCount: *config.MaxViewVideosInPlayLists, PeriodVideoCache: *config.PeriodVideoCache / 1000000,
-		Version: version, ListenAdmin: *config.ListenAdmin}
-	
-	log.Debugf("countPlaylists: %v, countVideos: %v", countPlaylists, countVideos)
-
-	return globalCounts, nil
-}
-
-
-
-// Перевірка дати, заданої рядком мілісекунд, та її форматування
-// якщо дата не задана (пустий рядок), повертаємо пустий рядок
-// якщо задана, перевіряємо коректність та форматуємо в timestamp with time zone згідно TIME_LAYOUT
-func checkDate(sdt string) (string, error) {
-	if sdt == "" {
-		return "", nil
-	} else {
-		millis, err := strconv.ParseInt(sdt, 10, 64)
-		if err != nil {
-			log.Errorf("Error convert string date %v to timestamp", sdt)
-			return "", err
-		}
-		return time.Unix(0, millis*int64(time.Millisecond)).Format(TIME_LAYOUT), nil
-	}
-}
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+	"github.com/AleksandrKuts/youtubemeter-service/migrations"
+	"github.com/AleksandrKuts/youtubemeter-service/settings"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The layout defines the format by showing how the reference time, defined to be.
+// timestamp with time zone;
+const TIME_LAYOUT = "2006-01-02T15:04:05.999999-07:00"
+
+const INSERT_PLAYLIST = "INSERT INTO playlist ( id, title, enable, idch, " +
+	"periodcollect, periodmetric, periodsavemetricidle, maxrequestvideos ) VALUES ( $1, $2, $3, $4, $5, $6, $7, $8)"
+const UPDATE_PLAYLIST = "UPDATE playlist SET title=$2, enable=$3, idch=$4, " +
+	"periodcollect=$5, periodmetric=$6, periodsavemetricidle=$7, maxrequestvideos=$8 WHERE id = $1"
+const GET_PLAYLISTS = "SELECT id, TRIM(title), enable, idch, timeadd, countvideo, timearchive, " +
+	"periodcollect, periodmetric, periodsavemetricidle, maxrequestvideos FROM playlist ORDER BY title"
+const GET_PLAYLISTS_ENABLE = "SELECT id, TRIM(title), enable, idch, timeadd, countvideo, timearchive, " +
+	"periodcollect, periodmetric, periodsavemetricidle, maxrequestvideos FROM playlist " +
+	"WHERE enable = true AND timearchive IS NULL ORDER BY title"
+
+// Видалення плейлиста - перенесення в архів, повторне видалення не змінює час перенесення
+const ARCHIVE_PLAYLIST = "UPDATE playlist SET timearchive = COALESCE(timearchive, now()) WHERE id = $1"
+const RESTORE_PLAYLIST = "UPDATE playlist SET timearchive = NULL WHERE id = $1"
+const GET_PLAYLIST_ARCHIVED = "SELECT timearchive IS NOT NULL FROM playlist WHERE id = $1"
+
+// Очищення архівного плейлиста: кількість рядків, які будуть видалені, та видалення порціями по $2 рядків.
+// Коментарі та зведення метрик видаляються каскадно разом з відео. Кожна порція перевіряє, що плейлист досі
+// в архіві: якщо його відновили під час очищення, порції перестають видаляти рядки
+const PLAYLIST_VIDEOS = "SELECT id FROM video WHERE idpl = $1"
+const ARCHIVED_PLAYLIST_VIDEOS = "SELECT v.id FROM video v JOIN playlist p ON p.id = v.idpl " +
+	"WHERE v.idpl = $1 AND p.timearchive IS NOT NULL"
+const COUNT_PLAYLIST_DATA = "SELECT (SELECT COUNT(*) FROM video WHERE idpl = $1), " +
+	"(SELECT COUNT(*) FROM metric WHERE idvideo IN (" + PLAYLIST_VIDEOS + ")), " +
+	"(SELECT COUNT(*) FROM metric_hourly WHERE idvideo IN (" + PLAYLIST_VIDEOS + ")) + " +
+	"(SELECT COUNT(*) FROM metric_daily WHERE idvideo IN (" + PLAYLIST_VIDEOS + ")), " +
+	"(SELECT COUNT(*) FROM comment WHERE idvideo IN (" + PLAYLIST_VIDEOS + "))"
+const PURGE_PLAYLIST_METRICS = "DELETE FROM metric WHERE (idvideo, timemetric, id) IN (" +
+	"SELECT idvideo, timemetric, id FROM metric WHERE idvideo IN (" + ARCHIVED_PLAYLIST_VIDEOS + ") LIMIT $2)"
+const PURGE_PLAYLIST_VIDEOS = "DELETE FROM video WHERE id IN (" + ARCHIVED_PLAYLIST_VIDEOS + " LIMIT $2)"
+const DELETE_PLAYLIST = "DELETE FROM playlist WHERE id = $1 AND timearchive IS NOT NULL"
+
+// Відео видаляються меншими порціями, бо разом з кожним видаляються його зведення та коментарі
+const PURGE_VIDEO_BATCH_DIVIDER = 100
+
+// Роздільність метрик: сирі виміри, погодинні та щоденні зведення
+const RESOLUTION_RAW = "raw"
+const RESOLUTION_HOURLY = "hourly"
+const RESOLUTION_DAILY = "daily"
+
+// Метрики відео за період з різною роздільністю. Для відео, якого немає в БД, рядків не повертається.
+// Зі зведень (metric_hourly, metric_daily) береться останній вимір інтервалу
+const METRIC_FROM = "COALESCE(NULLIF($2, '')::timestamp with time zone, '-infinity')"
+const METRIC_TO = "COALESCE(NULLIF($3, '')::timestamp with time zone, 'infinity')"
+
+const SELECT_METRIC_RAW = "SELECT commentcount, likecount, dislikecount, viewcount, timemetric FROM metric" +
+	" WHERE idvideo = $1 AND timemetric BETWEEN " + METRIC_FROM + " AND " + METRIC_TO
+
+const SELECT_METRIC_ROLLUP = "SELECT commentlast, likelast, dislikelast, viewlast, timelast FROM %[1]v" +
+	" WHERE idvideo = $1 AND timelast BETWEEN " + METRIC_FROM + " AND " + METRIC_TO
+
+// Всі збережені метрики. Період, сирі метрики якого вже видалені, береться з погодинних зведень
+const GET_METRICS_RAW = SELECT_METRIC_RAW +
+	" UNION ALL " + "SELECT commentlast, likelast, dislikelast, viewlast, timelast FROM metric_hourly" +
+	" WHERE idvideo = $1 AND timelast BETWEEN " + METRIC_FROM + " AND " + METRIC_TO +
+	" AND timelast < (SELECT COALESCE(MIN(timemetric), 'infinity') FROM metric WHERE idvideo = $1)" +
+	" ORDER BY 5"
+
+// Зведення (%[1]v - таблиця зведень), доповнені сирими метриками після останнього зведеного інтервалу
+const GET_METRICS_ROLLUP = SELECT_METRIC_ROLLUP +
+	" UNION ALL " + SELECT_METRIC_RAW +
+	" AND timemetric > (SELECT COALESCE(MAX(timelast), '-infinity') FROM %[1]v WHERE idvideo = $1)" +
+	" ORDER BY 5"
+
+const GET_VIDEO_BY_ID = "SELECT r.* FROM video v, return_video(v.id) r WHERE v.id = $1"
+const GET_VIDEO_PUBLISHED = "SELECT publishedat FROM video WHERE id = $1"
+
+const GET_COMPARE_VIDEOS = "SELECT id, TRIM(title), publishedat FROM video WHERE id = ANY($1)"
+
+const GET_COMPARE_PLAYLIST_VIDEOS = "SELECT id, TRIM(title), publishedat FROM video" +
+	" WHERE idpl = $1 ORDER BY publishedat DESC LIMIT $2"
+
+const GET_COMPARE_METRICS = "SELECT idvideo, commentcount, likecount, dislikecount, viewcount, timemetric FROM metric" +
+	" WHERE idvideo = ANY($1)" +
+	" UNION ALL SELECT h.idvideo, h.commentlast, h.likelast, h.dislikelast, h.viewlast, h.timelast FROM metric_hourly h" +
+	" WHERE h.idvideo = ANY($1)" +
+	" AND h.timelast < (SELECT COALESCE(MIN(m.timemetric), 'infinity') FROM metric m WHERE m.idvideo = h.idvideo)" +
+	" ORDER BY 1, 6"
+
+const GET_VIDEO_EXISTS = "SELECT EXISTS (SELECT 1 FROM video WHERE id = $1)"
+const GET_PLAYLIST_EXISTS = "SELECT EXISTS (SELECT 1 FROM playlist WHERE id = $1)"
+
+// Останній вимір метрик відео: сирий або, якщо сирі виміри вже видалені, з погодинних зведень
+const VIDEO_LATEST_METRIC = "SELECT l.viewcount, l.likecount, l.commentcount, l.timemetric FROM (" +
+	"(SELECT viewcount, likecount, commentcount, timemetric FROM metric" +
+	" WHERE idvideo = v.id ORDER BY timemetric DESC LIMIT 1)" +
+	" UNION ALL (SELECT viewlast, likelast, commentlast, timelast FROM metric_hourly" +
+	" WHERE idvideo = v.id ORDER BY bucket DESC LIMIT 1)" +
+	") l ORDER BY l.timemetric DESC LIMIT 1"
+
+// Вимір, зроблений не пізніше ніж за добу до останнього, для швидкості приросту переглядів
+const VIDEO_PREVIOUS_METRIC = "SELECT l.viewcount, l.timemetric FROM (" +
+	"(SELECT viewcount, timemetric FROM metric" +
+	" WHERE idvideo = v.id AND timemetric <= lm.timemetric - interval '24 hours' ORDER BY timemetric DESC LIMIT 1)" +
+	" UNION ALL (SELECT viewlast, timelast FROM metric_hourly" +
+	" WHERE idvideo = v.id AND timelast <= lm.timemetric - interval '24 hours' ORDER BY bucket DESC LIMIT 1)" +
+	") l ORDER BY l.timemetric DESC LIMIT 1"
+
+// Швидкість приросту переглядів за годину за останню добу вимірів. Для відео, яким менше доби, - з публікації
+const VIDEO_GROWTH = "SELECT ((lm.viewcount - COALESCE(pm.viewcount, 0)) / " +
+	"GREATEST(EXTRACT(epoch FROM lm.timemetric - COALESCE(pm.timemetric, v.publishedat)) / 3600, 1))::float8 AS growth"
+
+// Список відео з останніми метриками. $1 - плейлист, пусто - відео всіх активних плейлистів (з назвою плейлиста).
+// Фільтри, пусто або NULL - без обмеження: період публікації $2 - $3, канал $4, останні перегляди $5 - $6,
+// стан збору метрик $7 (VIDEO_STATUS_*, $8 - глобальний термін збору в секундах)
+const VIDEOS_FROM = " FROM video v" +
+	" LEFT JOIN playlist p ON p.id = v.idpl" +
+	" LEFT JOIN LATERAL (" + VIDEO_LATEST_METRIC + ") lm ON true"
+const VIDEOS_WHERE = " WHERE (($1 = '' AND p.enable = true AND p.timearchive IS NULL) OR v.idpl = $1::character(24))" +
+	" AND v.publishedat >= COALESCE(NULLIF($2, '')::timestamp with time zone, '-infinity')" +
+	" AND v.publishedat <= COALESCE(NULLIF($3, '')::timestamp with time zone, 'infinity')" +
+	" AND ($4 = '' OR v.chid = $4::character(24))" +
+	" AND ($5::bigint IS NULL OR lm.viewcount >= $5)" +
+	" AND ($6::bigint IS NULL OR lm.viewcount <= $6)" +
+	" AND ($7 = '' OR (v.publishedat > now() - COALESCE(p.periodcollect, $8) * interval '1 second') = ($7 = '" +
+	VIDEO_STATUS_ACTIVE + "'))"
+
+// Сторінка списку відео: $9 - кількість (NULL - всі відео), $10 - зсув, $11, $12 - курсор (publishedat, id)
+// останнього відео попередньої сторінки, NULL - з початку. Курсор можливий тільки з сортуванням за publishedat.
+// Сортування (%v) - одне з videoSortOrder
+const GET_VIDEOS = "SELECT v.id, TRIM(v.title), v.publishedat, " +
+	"CASE WHEN $1 = '' THEN COALESCE(TRIM(p.title), '') ELSE '' END, " +
+	"COALESCE(lm.viewcount, 0), COALESCE(lm.likecount, 0), COALESCE(lm.commentcount, 0), lm.timemetric, " +
+	"COALESCE(g.growth, 0)" + VIDEOS_FROM +
+	" LEFT JOIN LATERAL (" + VIDEO_PREVIOUS_METRIC + ") pm ON true" +
+	" LEFT JOIN LATERAL (" + VIDEO_GROWTH + ") g ON true" +
+	VIDEOS_WHERE +
+	" AND ($11::timestamp with time zone IS NULL OR (v.publishedat, v.id) < ($11, $12::character(11)))" +
+	" ORDER BY %v LIMIT $9 OFFSET $10"
+
+// Кількість відео, що відповідають фільтрам
+const COUNT_VIDEOS = "SELECT COUNT(*)" + VIDEOS_FROM + VIDEOS_WHERE
+
+// Сортування списку відео (sort=)
+var videoSortOrder = map[string]string{
+	VIDEO_SORT_PUBLISHED: "v.publishedat DESC, v.id DESC",
+	VIDEO_SORT_VIEWS:     "lm.viewcount DESC NULLS LAST, v.publishedat DESC, v.id DESC",
+	VIDEO_SORT_LIKES:     "lm.likecount DESC NULLS LAST, v.publishedat DESC, v.id DESC",
+	VIDEO_SORT_COMMENTS:  "lm.commentcount DESC NULLS LAST, v.publishedat DESC, v.id DESC",
+	VIDEO_SORT_GROWTH:    "g.growth DESC NULLS LAST, v.publishedat DESC, v.id DESC",
+}
+
+const GET_GLOBAL_COUNTS = "select count(*) as count, SUM(countvideo) as countvideo FROM playlist WHERE enable = TRUE AND timearchive IS NULL"	
+
+// Повнотекстовий пошук відео активних плейлистів та відео, знайдених запитами. Запит $2 розбирається обома
+// конфігураціями індексу (english, ukrainian), фрагменти з підсвіченими словами будуються конфігурацією $1 тільки
+// для вибраної сторінки результатів. Фільтри: плейлист $3, канал $4, період публікації $5 - $6, пусто - без обмеження
+const SEARCH_VIDEOS = "SELECT s.id, COALESCE(TRIM(s.idpl), ''), TRIM(s.title), COALESCE(TRIM(s.chtitle), ''), " +
+	"COALESCE(TRIM(s.chid), ''), s.publishedat, s.rank, " +
+	"ts_headline($1::regconfig, TRIM(s.title), s.query, 'HighlightAll=true'), " +
+	"ts_headline($1::regconfig, COALESCE(s.description, ''), s.query, 'MaxFragments=2, MinWords=5, MaxWords=20') " +
+	"FROM (SELECT v.id, v.idpl, v.title, v.chtitle, v.chid, v.publishedat, v.description, q.query, " +
+	"ts_rank_cd(v.search, q.query) AS rank FROM video v" +
+	" CROSS JOIN (SELECT websearch_to_tsquery('english', $2) || websearch_to_tsquery('public.ukrainian', $2) AS query) q" +
+	" LEFT JOIN playlist p ON p.id = v.idpl" +
+	" WHERE v.search @@ q.query" +
+	" AND (v.idpl IS NULL OR (p.enable = true AND p.timearchive IS NULL))" +
+	" AND ($3 = '' OR v.idpl = $3::character(24))" +
+	" AND ($4 = '' OR v.chid = $4::character(24))" +
+	" AND v.publishedat >= COALESCE(NULLIF($5, '')::timestamp with time zone, '-infinity')" +
+	" AND v.publishedat <= COALESCE(NULLIF($6, '')::timestamp with time zone, 'infinity')" +
+	" ORDER BY rank DESC, v.publishedat DESC, v.id LIMIT $7 OFFSET $8) s" +
+	" ORDER BY s.rank DESC, s.publishedat DESC, s.id"
+
+// Рейтинг відео за приростом метрики за вікно $1 серед відео активних плейлистів або плейлиста $2 (може бути
+// вимкненим). Префікс колонок метрики підставляється (topMetricColumn)
+const GET_TOP_VIDEOS = "SELECT g.idvideo, TRIM(v.idpl), TRIM(v.title), COALESCE(TRIM(p.title), ''), v.publishedat, " +
+	"g.%[1]vgain, g.%[1]vcount, g.timefrom, g.timelast, g.timeupdate FROM video_gain g" +
+	" JOIN video v ON v.id = g.idvideo" +
+	" JOIN playlist p ON p.id = v.idpl" +
+	" WHERE g.period = $1 AND p.timearchive IS NULL" +
+	" AND (($2 = '' AND p.enable = true) OR v.idpl = $2::character(24))" +
+	" ORDER BY g.%[1]vgain DESC, g.idvideo LIMIT $3"
+
+// Час останнього перерахунку приросту метрик
+const GET_TOP_UPDATED = "SELECT MAX(timeupdate) FROM video_gain"
+
+// Виміри метрик відео $1 та відео плейлистів $2, починаючи з часу $3, для відновлення живого потоку
+const GET_STREAM_METRICS = "SELECT m.idvideo, COALESCE(TRIM(v.idpl), ''), m.commentcount, m.likecount, " +
+	"m.dislikecount, m.viewcount, m.timemetric FROM metric m" +
+	" JOIN video v ON v.id = m.idvideo" +
+	" WHERE (m.idvideo = ANY($1) OR v.idpl = ANY($2)) AND m.timemetric >= $3" +
+	" ORDER BY m.timemetric LIMIT $4"
+
+// Нові відео плейлистів $1, додані починаючи з часу $2, для відновлення живого потоку
+const GET_STREAM_VIDEOS = "SELECT v.id, TRIM(v.idpl), TRIM(v.title), v.publishedat, v.timeadd FROM video v" +
+	" WHERE v.idpl = ANY($1) AND v.timeadd >= $2" +
+	" ORDER BY v.timeadd LIMIT $3"
+
+const INSERT_QUERY = "INSERT INTO query ( kind, query, regioncode, title, enable, maxresults, periodquery ) " +
+	"VALUES ( $1, $2, $3, $4, $5, $6, $7 ) RETURNING id"
+const UPDATE_QUERY = "UPDATE query SET kind=$2, query=$3, regioncode=$4, title=$5, enable=$6, maxresults=$7, " +
+	"periodquery=$8 WHERE id = $1"
+const DELETE_QUERY = "DELETE FROM query WHERE id = $1"
+const GET_QUERIES = "SELECT id, kind, query, COALESCE(regioncode, ''), COALESCE(TRIM(title), ''), enable, " +
+	"maxresults, periodquery, timeadd FROM query ORDER BY title"
+const GET_QUERIES_ENABLE = "SELECT id, kind, query, COALESCE(regioncode, ''), COALESCE(TRIM(title), ''), enable, " +
+	"maxresults, periodquery, timeadd FROM query WHERE enable = true ORDER BY title"
+const GET_QUERY_BY_ID = "SELECT id, kind, query, COALESCE(regioncode, ''), COALESCE(TRIM(title), ''), enable, " +
+	"maxresults, periodquery, timeadd FROM query WHERE id = $1"
+
+// Позиції відео в результатах запиту за період, пустий рядок - період не обмежений
+const GET_QUERY_RANKS = "SELECT r.idvideo, COALESCE(TRIM(v.title), ''), r.rank, r.timerank FROM queryrank r" +
+	" LEFT JOIN video v ON v.id = r.idvideo" +
+	" WHERE r.idquery = $1" +
+	" AND r.timerank >= COALESCE(NULLIF($2, '')::timestamp with time zone, '-infinity')" +
+	" AND r.timerank <= COALESCE(NULLIF($3, '')::timestamp with time zone, 'infinity')" +
+	" ORDER BY r.timerank, r.rank"
+
+// Кількість коментарів відео за інтервали довжиною $2 секунд
+const GET_COMMENT_RATE = "SELECT to_timestamp(floor(extract(epoch FROM c.publishedat) / $2) * $2) AS ctime, COUNT(*)" +
+	" FROM comment c" +
+	" WHERE c.idvideo = $1" +
+	" AND c.publishedat >= COALESCE(NULLIF($3, '')::timestamp with time zone, '-infinity')" +
+	" AND c.publishedat <= COALESCE(NULLIF($4, '')::timestamp with time zone, 'infinity')" +
+	" GROUP BY ctime ORDER BY ctime"
+
+const GET_TOP_COMMENTS = "SELECT id, publishedat, likecount, replycount, text, timeupdate FROM comment" +
+	" WHERE idvideo = $1 ORDER BY likecount DESC, publishedat LIMIT $2"
+
+// Користувачі API. Пароль та токен зберігаються тільки хешами. Існуючий користувач не змінюється при
+// повторному створенні, новий токен видається окремим запитом
+const INSERT_APIUSER = "INSERT INTO apiuser ( name, role, tokenhash, passwordhash, enable ) VALUES ( $1, $2, $3, $4, true )" +
+	" ON CONFLICT (name) DO NOTHING"
+const UPDATE_APIUSER_TOKEN = "UPDATE apiuser SET tokenhash = $2 WHERE name = $1"
+const DELETE_APIUSER = "DELETE FROM apiuser WHERE name = $1"
+const GET_APIUSERS = "SELECT name, role, enable, COALESCE(passwordhash, ''), COALESCE(tokenhash, ''), timeadd" +
+	" FROM apiuser ORDER BY name"
+const GET_APIUSER_BY_NAME = "SELECT name, role, enable, COALESCE(passwordhash, ''), COALESCE(tokenhash, ''), timeadd" +
+	" FROM apiuser WHERE name = $1"
+const GET_APIUSER_BY_TOKEN = "SELECT name, role, enable, COALESCE(passwordhash, ''), COALESCE(tokenhash, ''), timeadd" +
+	" FROM apiuser WHERE tokenhash = $1"
+
+// Журнал аудиту: стан об'єкта (до та після зміни) в json, запис аудиту та відбір записів за період, користувачем
+// та плейлистом. Пустий рядок - без обмеження
+const SNAPSHOT_PLAYLIST = "SELECT to_jsonb(p) FROM playlist p WHERE id = $1 FOR UPDATE"
+const SNAPSHOT_QUERY = "SELECT to_jsonb(q) FROM query q WHERE id = $1 FOR UPDATE"
+const SNAPSHOT_APIUSER = "SELECT to_jsonb(u) - 'tokenhash' - 'passwordhash' FROM apiuser u WHERE name = $1 FOR UPDATE"
+const INSERT_AUDIT = "INSERT INTO audit ( actor, action, target, idpl, before, after, addr ) " +
+	"VALUES ( $1, $2, $3, $4, $5, $6, $7 )"
+const GET_AUDIT = "SELECT id, timeaudit, COALESCE(actor, ''), action, target, COALESCE(TRIM(idpl), ''), before, after, " +
+	"COALESCE(addr, '') FROM audit" +
+	" WHERE timeaudit >= COALESCE(NULLIF($1, '')::timestamp with time zone, '-infinity')" +
+	" AND timeaudit <= COALESCE(NULLIF($2, '')::timestamp with time zone, 'infinity')" +
+	" AND ($3 = '' OR actor = $3)" +
+	" AND ($4 = '' OR idpl = $4::character(24))" +
+	" ORDER BY timeaudit DESC, id DESC LIMIT $5"
+
+const NO_DATA = "No data"
+
+// creat connections string
+// example: host=127.0.0.100 port=5432 dbname=base1 user=user1 password=lalala sslmode=disable"
+var connStrForDatabse string
+
+var db *sql.DB
+var errDB error
+
+// формуємо підключення до Бази Даних (БД), підключення відкрита на протязі всієї роботи програми.
+// Викликається на початку кожної команди (StartService, Migrate, CreateAdmin), тести працюють без БД
+func openDB() {
+	connStrForDatabse = config.DSN()
+
+	log.Debugf("connStr=%s", settings.Redact(connStrForDatabse))
+
+	db, errDB = sql.Open("postgres", connStrForDatabse)
+	if errDB != nil {
+		log.Errorf("error open database: %v", errDB)
+	}
+
+	// перевірка доступності
+	err := db.Ping()
+	if err != nil { // далі робити не можна
+		log.Fatalf("error ping database: %v", err)
+	}
+
+}
+
+// коректне закриття з'єднання з БД
+func closeDB() {
+	log.Infof("close database with %v open connections", db.Stats().OpenConnections)
+
+	err := db.Close()
+	if err != nil {
+		log.Errorf("error close database: %v", err)
+	}
+}
+
+// Перевірка версії схеми БД, зі схемою іншої версії сервіс не працює
+func checkSchema() {
+	if err := migrations.Check(db); err != nil {
+		log.Fatalf("err=%v", err)
+	}
+	log.Infof("database schema version: %v", migrations.Latest())
+}
+
+// Виконати команду міграції схеми БД (up, down, status): backend --migrate=<команда>
+func Migrate(command string) error {
+	setup()
+	openDB()
+	defer closeDB()
+
+	log.Warnf("migrate database schema: %v", command)
+	return migrations.Run(db, command, os.Stdout)
+}
+
+// Додати плей-лист до БД
+func addPlayListDB(playlist *PlayList, audit *auditEntry) error {
+	settings, err := settingsToDB(&playlist.PlayListSettings)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+
+	return auditChange(audit, SNAPSHOT_PLAYLIST, func(tx *sql.Tx) error {
+		_, err := tx.Exec(INSERT_PLAYLIST, append([]interface{}{playlist.Id, playlist.Title, playlist.Enable,
+			playlist.Idch}, settings...)...)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+
+		log.Debugf("insert playlist: id=%v, title=%v, enable=%v, idch=%v, settings=%v", playlist.Id, playlist.Title,
+			playlist.Enable, playlist.Idch, playlist.PlayListSettings)
+		return nil
+	})
+}
+
+// Оновити плей-лист в БД
+func updatePlayListDB(id string, playlist *PlayList, audit *auditEntry) error {
+	settings, err := settingsToDB(&playlist.PlayListSettings)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+
+	return auditChange(audit, SNAPSHOT_PLAYLIST, func(tx *sql.Tx) error {
+		res, err := tx.Exec(UPDATE_PLAYLIST, append([]interface{}{id, playlist.Title, playlist.Enable, playlist.Idch},
+			settings...)...)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		} else if affected == 0 {
+			return notFound("playlist not found")
+		}
+
+		log.Debugf("update playlist: id=%v, title=%v, enable=%v, idch=%v, settings=%v", id, playlist.Title,
+			playlist.Enable, playlist.Idch, playlist.PlayListSettings)
+		return nil
+	})
+}
+
+// Перенести плей-лист в архів (archive = true) або повернути з архіву
+func archivePlayListDB(playlistId string, archive bool, audit *auditEntry) error {
+	query := RESTORE_PLAYLIST
+	if archive {
+		query = ARCHIVE_PLAYLIST
+	}
+
+	return auditChange(audit, SNAPSHOT_PLAYLIST, func(tx *sql.Tx) error {
+		res, err := tx.Exec(query, playlistId)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		} else if affected == 0 {
+			return notFound("playlist not found")
+		}
+
+		log.Debugf("playlist: id=%v, archive=%v", playlistId, archive)
+		return nil
+	})
+}
+
+// Кількість рядків архівного плейлиста, які будуть видалені при очищенні
+func countPlayListDataDB(playlistId string) (*ResponcePurge, error) {
+	var archived bool
+	err := db.QueryRow(GET_PLAYLIST_ARCHIVED, playlistId).Scan(&archived)
+	if err == sql.ErrNoRows {
+		return nil, notFound("playlist not found")
+	} else if err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+	if !archived {
+		return nil, conflict("playlist is not archived, delete it first")
+	}
+
+	response := &ResponcePurge{DryRun: true}
+	err = db.QueryRow(COUNT_PLAYLIST_DATA, playlistId).Scan(&response.Videos, &response.Metrics,
+		&response.Rollups, &response.Comments)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+	return response, nil
+}
+
+// Видаляти порціями по batch рядків, поки запит щось видаляє. Кожна порція - окрема транзакція,
+// щоб не тримати блокування на весь час очищення. Порція, яка нічого не видалила, означає кінець очищення
+// тільки якщо плейлист досі в архіві, інакше очищення зупиняється з конфліктом. Повертає кількість видалених рядків
+func deleteInBatches(query string, playlistId string, batch int) (int64, error) {
+	var total int64
+	for {
+		res, err := db.Exec(query, playlistId, batch)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return total, err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return total, err
+		}
+		if affected == 0 {
+			var archived bool
+			err := db.QueryRow(GET_PLAYLIST_ARCHIVED, playlistId).Scan(&archived)
+			if err != nil && err != sql.ErrNoRows {
+				log.Errorf("err=%v", err)
+				return total, err
+			}
+			if !archived {
+				log.Warnf("playlist: id=%v, restored during purge, deleted=%v", playlistId, total)
+				return total, conflict("playlist is restored, purge is stopped")
+			}
+			return total, nil
+		}
+		total += affected
+		log.Debugf("playlist: id=%v, deleted=%v", playlistId, total)
+	}
+}
+
+// Остаточно видалити архівний плей-лист: метрики порціями по batch, потім відео разом з їх зведеннями та
+// коментарями, потім сам плей-лист (в журнал аудиту записується видалення плей-листа). Після помилки очищення
+// можна повторити
+func purgePlayListDB(playlistId string, batch int, audit *auditEntry) error {
+	deleted, err := deleteInBatches(PURGE_PLAYLIST_METRICS, playlistId, batch)
+	if err != nil {
+		return err
+	}
+	log.Infof("playlist: id=%v, purged metrics=%v", playlistId, deleted)
+
+	videoBatch := batch / PURGE_VIDEO_BATCH_DIVIDER
+	if videoBatch < 1 {
+		videoBatch = 1
+	}
+	deleted, err = deleteInBatches(PURGE_PLAYLIST_VIDEOS, playlistId, videoBatch)
+	if err != nil {
+		return err
+	}
+	log.Infof("playlist: id=%v, purged videos=%v", playlistId, deleted)
+
+	return auditChange(audit, SNAPSHOT_PLAYLIST, func(tx *sql.Tx) error {
+		res, err := tx.Exec(DELETE_PLAYLIST, playlistId)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		} else if affected == 0 {
+			return conflict("playlist is not archived")
+		}
+
+		log.Debugf("deleted playlist: id=%v", playlistId)
+		return nil
+	})
+}
+
+// Отримати плейлисти
+// onlyEnable - які плейлисти вибирати
+//   true  - тільки активні, якщо плейлист не активний його треба активувати через інтерфейс адміністратора
+//   false - всі
+func getPlaylistsFromDB(onlyEnable bool) ([]PlayList, error) {
+	log.Debugf("dbstats=%v", db.Stats())
+
+	var rows *sql.Rows
+	var err error
+
+	if onlyEnable {
+		rows, err = db.Query(GET_PLAYLISTS_ENABLE)		
+	} else {
+		rows, err = db.Query(GET_PLAYLISTS)		
+	}
+	
+	if err != nil {
+		log.Errorf("Error get playlists: %v", err)
+		return nil, err
+	}
+
+	if rows == nil {
+		return nil, errors.New( NO_DATA )
+	}
+
+	defer rows.Close()
+
+	response := []PlayList{}
+
+	for rows.Next() {
+		var Id string
+		var Title string
+		var Enable bool
+		var Idch string
+		var Timeadd time.Time
+		var countvideo int
+		var archived sql.NullTime
+		var periodCollect, periodMetric, periodSaveMetricIdle, maxRequestVideos sql.NullInt64
+
+		rows.Scan(&Id, &Title, &Enable, &Idch, &Timeadd, &countvideo, &archived,
+			&periodCollect, &periodMetric, &periodSaveMetricIdle, &maxRequestVideos)
+		Id = strings.TrimSpace(Id)
+		Title = strings.TrimSpace(Title)
+		Idch = strings.TrimSpace(Idch)
+
+		var timeArchive *time.Time
+		if archived.Valid {
+			timeArchive = &archived.Time
+		}
+
+		response = append(response, PlayList{Id, Title, Enable, Idch, Timeadd, countvideo, timeArchive,
+			settingsFromDB(periodCollect, periodMetric, periodSaveMetricIdle, maxRequestVideos)})
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	log.Debugf("Success get playlists from DB")
+
+	return response, nil
+}
+
+// Отримати метрики по відео id за заданий період, проріджені до points точок алгоритмом algo.
+// Роздільність (сирі метрики або зведення) вибирається за довжиною періоду
+func getMetricsByIdFromDB(id string, from, to string, points int, algo string) ([]*Metrics, error) {
+	log.Debugf("id: %v, from: %v, to: %v, points: %v, algo: %v", id, from, to, points, algo)
+
+	resolution, err := metricResolution(id, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	response := []*Metrics{}
+	err = streamMetricsFromDB(id, from, to, resolution, func(m *Metrics) error {
+		response = append(response, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return downsample(response, points, algo), nil
+}
+
+// Отримати опис відео по його id
+func getVideoByIdFromDB(id string) ( *YoutubeVideo, error) {
+	if id == "" {
+		return nil, badRequest("video id is null")
+	}
+
+	var idpl sql.NullString // відео, знайдене запитом, може не належати плейлисту
+	var title string
+	var description string
+	var chtitle string
+	var chid string
+	var publishedat time.Time
+	var count_metrics int
+	var max_timemetric time.Time
+	var min_timemetric time.Time
+
+	err := db.QueryRow(GET_VIDEO_BY_ID, id).Scan(&idpl, &title, &description, &chtitle, &chid, &publishedat, &count_metrics, &max_timemetric, &min_timemetric)
+	if err == sql.ErrNoRows {
+		return nil, notFound("video not found")
+	}
+	if err != nil {
+		log.Errorf("Error get videos by id: %v", err)
+		return nil, err
+	}
+	
+	youtubeVideo := &YoutubeVideo{strings.TrimSpace(idpl.String), strings.TrimSpace(title), strings.TrimSpace(description), 
+			strings.TrimSpace(chtitle), strings.TrimSpace(chid), publishedat, count_metrics, max_timemetric, min_timemetric, nil}
+	
+	log.Debugf("id: %v, idpl: %v, title: %v, description: %v, chtitle: %v, chid: %v, publishedat: %v, count_metrics: %v, max_timemetric: %v, min_timemetric: %v", 
+			id, idpl.String, title, description, chtitle, chid, publishedat, count_metrics, max_timemetric, min_timemetric)
+
+	return youtubeVideo, nil
+}
+
+// Отримати список відео плейлиста в json-форматі, id = "" - відео всіх активних плейлистів.
+// Для filter.Paged - сторінка ResponceVideos з курсором наступної сторінки та загальною кількістю відео
+func getVideosByPlayListIdFromDB(id string, filter *videoFilter, offset int) ([]byte, error) {
+	limit := filter.Limit
+	if filter.Paged {
+		// зайве відео показує, що є наступна сторінка
+		limit++
+	}
+
+	items := []*YoutubeVideoShort{}
+	err := streamVideosFromDB(id, filter, offset, sql.NullInt64{Int64: int64(limit), Valid: true},
+		func(v *YoutubeVideoShort) error {
+			items = append(items, v)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var response interface{} = items
+	if filter.Paged {
+		page := &ResponceVideos{Items: items}
+		if len(items) > filter.Limit {
+			page.Items = items[:filter.Limit]
+			last := page.Items[filter.Limit-1]
+			if filter.Sort == VIDEO_SORT_PUBLISHED {
+				page.NextCursor = (&videoCursor{last.PublishedAt, last.Id}).String()
+			}
+		}
+
+		page.Total, err = countVideosFromDB(id, filter)
+		if err != nil {
+			return nil, err
+		}
+		response = page
+	}
+
+	// Конвертуємо відповідь в json-формат
+	stringVideos, err := json.Marshal(response)
+
+	if err != nil {
+		log.Errorf("Error convert select to YoutubeVideoShort: response=%v, error=%v", response, err)
+		return nil, err
+	}
+
+	log.Debugf("id: %v, playlist: %v", id, string(stringVideos))
+
+	return stringVideos, nil
+}
+
+// Параметри фільтрів списку відео для запитів GET_VIDEOS та COUNT_VIDEOS ($1 - $8)
+func videoFilterArgs(id string, filter *videoFilter) ([]interface{}, error) {
+	sFrom, err := checkDate(filter.From)
+	if err != nil {
+		return nil, err
+	}
+	sTo, err := checkDate(filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{id, sFrom, sTo, filter.ChannelId, filter.MinViews, filter.MaxViews, filter.Status,
+		int64(config.PeriodCollectionCache.Get() / time.Second)}, nil
+}
+
+// Кількість відео плейлиста (id = "" - всіх активних плейлистів), що відповідають фільтрам
+func countVideosFromDB(id string, filter *videoFilter) (int64, error) {
+	args, err := videoFilterArgs(id, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err = db.QueryRow(COUNT_VIDEOS, args...).Scan(&count); err != nil {
+		log.Errorf("Error count videos: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// Отримати опис відео по його id
+func getGlobalCountsFromDB(version string) ( *GlobalCounts, error) {
+	var countPlaylists int
+	var countVideos int
+
+	err := db.QueryRow(GET_GLOBAL_COUNTS).Scan(&countPlaylists, &countVideos)
+	if err != nil {
+		log.Errorf("Error get global counts: %v", err)
+		return nil, err
+	}
+	
+	globalCounts := &GlobalCounts{CountPlaylists: countPlaylists, CountVideos: countVideos, TimeUpdate: time.Now(), 
+		MaxVideoCount: config.MaxViewVideosInPlayLists.Get(), PeriodVideoCache: config.PeriodVideoCache.Get() / 1000000,
+		Version: version, ListenAdmin: *config.ListenAdmin}
+	
+	log.Debugf("countPlaylists: %v, countVideos: %v", countPlaylists, countVideos)
+
+	return globalCounts, nil
+}
+
+
+
+// Перевірка, чи є об'єкт з таким id в БД. Якщо немає - помилка "не знайдено" з повідомленням message
+func checkExists(query, id, message string) error {
+	var exists bool
+
+	err := db.QueryRow(query, id).Scan(&exists)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+	if !exists {
+		return notFound(message)
+	}
+
+	return nil
+}
+
+// Перевірка дати, заданої рядком мілісекунд, та її форматування
+// якщо дата не задана (пустий рядок), повертаємо пустий рядок
+// якщо задана, перевіряємо коректність та форматуємо в timestamp with time zone згідно TIME_LAYOUT
+func checkDate(sdt string) (string, error) {
+	if sdt == "" {
+		return "", nil
+	} else {
+		millis, err := strconv.ParseInt(sdt, 10, 64)
+		if err != nil {
+			log.Errorf("Error convert string date %v to timestamp", sdt)
+			return "", badRequest("invalid date %v, expected milliseconds since epoch", sdt)
+		}
+		return time.Unix(0, millis*int64(time.Millisecond)).Format(TIME_LAYOUT), nil
+	}
+}
+
+// Перетворення налаштувань плейлиста у значення для БД: періоди зберігаються в секундах,
+// не задані налаштування зберігаються як NULL (колектор використовує глобальні налаштування)
+func settingsToDB(settings *PlayListSettings) ([]interface{}, error) {
+	values := []interface{}{}
+
+	for _, period := range []string{settings.PeriodCollect, settings.PeriodMetric, settings.PeriodSaveMetricIdle} {
+		if period == "" {
+			values = append(values, sql.NullInt64{})
+			continue
+		}
+
+		d, err := time.ParseDuration(period)
+		if err != nil {
+			return nil, badRequest("invalid period: %v", period)
+		}
+		if d < time.Second {
+			return nil, badRequest("period must be at least 1s: %v", period)
+		}
+		values = append(values, sql.NullInt64{Int64: int64(d / time.Second), Valid: true})
+	}
+
+	if settings.MaxRequestVideos < 0 || settings.MaxRequestVideos > 50 {
+		return nil, badRequest("maxrequestvideos must be between 1 and 50")
+	}
+	values = append(values, sql.NullInt64{Int64: int64(settings.MaxRequestVideos), Valid: settings.MaxRequestVideos > 0})
+
+	return values, nil
+}
+
+// Перетворення налаштувань плейлиста з БД, періоди в БД зберігаються в секундах
+func settingsFromDB(periodCollect, periodMetric, periodSaveMetricIdle, maxRequestVideos sql.NullInt64) PlayListSettings {
+	formatPeriod := func(seconds sql.NullInt64) string {
+		if !seconds.Valid {
+			return ""
+		}
+		return (time.Duration(seconds.Int64) * time.Second).String()
+	}
+
+	return PlayListSettings{formatPeriod(periodCollect), formatPeriod(periodMetric), formatPeriod(periodSaveMetricIdle),
+		int(maxRequestVideos.Int64)}
+}
+
+// Перетворення параметрів запиту у значення для БД: періодичність зберігається в секундах,
+// не задані параметри зберігаються як NULL (колектор використовує глобальні налаштування)
+func queryToDB(query *Query) ([]interface{}, error) {
+	switch query.Kind {
+	case "search":
+		if strings.TrimSpace(query.Query) == "" {
+			return nil, badRequest("query must not be empty for search")
+		}
+	case "trending":
+		if len(query.RegionCode) != 2 {
+			return nil, badRequest("regioncode must be a two-letter country code for trending")
+		}
+	default:
+		return nil, badRequest("kind must be search or trending")
+	}
+
+	regionCode := sql.NullString{String: strings.ToUpper(query.RegionCode), Valid: query.RegionCode != ""}
+	if regionCode.Valid && len(regionCode.String) != 2 {
+		return nil, badRequest("regioncode must be a two-letter country code")
+	}
+
+	if query.MaxResults < 0 || query.MaxResults > 50 {
+		return nil, badRequest("maxresults must be between 1 and 50")
+	}
+	maxResults := sql.NullInt64{Int64: int64(query.MaxResults), Valid: query.MaxResults > 0}
+
+	periodQuery := sql.NullInt64{}
+	if query.PeriodQuery != "" {
+		d, err := time.ParseDuration(query.PeriodQuery)
+		if err != nil {
+			return nil, badRequest("invalid period: %v", query.PeriodQuery)
+		}
+		if d < time.Second {
+			return nil, badRequest("period must be at least 1s: %v", query.PeriodQuery)
+		}
+		periodQuery = sql.NullInt64{Int64: int64(d / time.Second), Valid: true}
+	}
+
+	return []interface{}{query.Kind, strings.TrimSpace(query.Query), regionCode, query.Title, query.Enable,
+		maxResults, periodQuery}, nil
+}
+
+// Додати запит до БД
+func addQueryDB(query *Query, audit *auditEntry) error {
+	values, err := queryToDB(query)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+
+	return auditChange(audit, SNAPSHOT_QUERY, func(tx *sql.Tx) error {
+		err := tx.QueryRow(INSERT_QUERY, values...).Scan(&query.Id)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+		audit.Target = strconv.FormatInt(query.Id, 10)
+
+		log.Debugf("insert query: %+v", *query)
+		return nil
+	})
+}
+
+// Оновити запит в БД
+func updateQueryDB(id int64, query *Query, audit *auditEntry) error {
+	values, err := queryToDB(query)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+
+	return auditChange(audit, SNAPSHOT_QUERY, func(tx *sql.Tx) error {
+		res, err := tx.Exec(UPDATE_QUERY, append([]interface{}{id}, values...)...)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			return notFound("query not found")
+		}
+
+		log.Debugf("update query: id=%v, %+v", id, *query)
+		return nil
+	})
+}
+
+// Видалити запит з БД разом з історією позицій. Відео, знайдені запитом, залишаються
+func deleteQueryDB(id int64, audit *auditEntry) error {
+	return auditChange(audit, SNAPSHOT_QUERY, func(tx *sql.Tx) error {
+		res, err := tx.Exec(DELETE_QUERY, id)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			return notFound("query not found")
+		}
+
+		log.Debugf("deleted query: id=%v", id)
+		return nil
+	})
+}
+
+// Прочитати запит з рядка результату
+func scanQuery(scan func(dest ...interface{}) error) (Query, error) {
+	var query Query
+	var maxResults, periodQuery sql.NullInt64
+
+	err := scan(&query.Id, &query.Kind, &query.Query, &query.RegionCode, &query.Title, &query.Enable,
+		&maxResults, &periodQuery, &query.Timeadd)
+	if err != nil {
+		return query, err
+	}
+
+	query.RegionCode = strings.TrimSpace(query.RegionCode)
+	query.MaxResults = int(maxResults.Int64)
+	if periodQuery.Valid {
+		query.PeriodQuery = (time.Duration(periodQuery.Int64) * time.Second).String()
+	}
+
+	return query, nil
+}
+
+// Отримати запити
+// onlyEnable - які запити вибирати: true - тільки активні, false - всі
+func getQueriesFromDB(onlyEnable bool) ([]Query, error) {
+	var rows *sql.Rows
+	var err error
+
+	if onlyEnable {
+		rows, err = db.Query(GET_QUERIES_ENABLE)
+	} else {
+		rows, err = db.Query(GET_QUERIES)
+	}
+
+	if err != nil {
+		log.Errorf("Error get queries: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := []Query{}
+
+	for rows.Next() {
+		query, err := scanQuery(rows.Scan)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		response = append(response, query)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Отримати історію позицій відео в результатах запиту за заданий період
+func getQueryRanksFromDB(id int64, from, to string) (*ResponceQueryRanks, error) {
+	log.Debugf("id: %v, from: %v, to: %v", id, from, to)
+
+	query, err := scanQuery(db.QueryRow(GET_QUERY_BY_ID, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, notFound("query not found")
+	}
+	if err != nil {
+		log.Errorf("Error get query: %v", err)
+		return nil, err
+	}
+
+	/* перевіряємо та форматуємо дату з якої вибираємо */
+	sFrom, err := checkDate(from)
+	if err != nil {
+		return nil, err
+	}
+
+	/* перевіряємо та форматуємо дату по яку вибираємо */
+	sTo, err := checkDate(to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(GET_QUERY_RANKS, id, sFrom, sTo)
+	if err != nil {
+		log.Errorf("Error get query ranks: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := &ResponceQueryRanks{Query: query, Videos: []*QueryVideoRanks{}}
+	videos := make(map[string]*QueryVideoRanks)
+
+	for rows.Next() {
+		var videoId string
+		var title string
+		var rank QueryRank
+
+		err = rows.Scan(&videoId, &title, &rank.Rank, &rank.Time)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+
+		video, ok := videos[videoId]
+		if !ok {
+			video = &QueryVideoRanks{Id: strings.TrimSpace(videoId), Title: title, Ranks: []QueryRank{}}
+			videos[videoId] = video
+			response.Videos = append(response.Videos, video)
+		}
+		video.Ranks = append(video.Ranks, rank)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Отримати кількість коментарів відео за інтервали заданої довжини за заданий період
+func getCommentRateFromDB(id string, interval time.Duration, from, to string) (*ResponceCommentRate, error) {
+	log.Debugf("id: %v, interval: %v, from: %v, to: %v", id, interval, from, to)
+
+	/* перевіряємо та форматуємо дату з якої вибираємо */
+	sFrom, err := checkDate(from)
+	if err != nil {
+		return nil, err
+	}
+
+	/* перевіряємо та форматуємо дату по яку вибираємо */
+	sTo, err := checkDate(to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(GET_COMMENT_RATE, id, int64(interval/time.Second), sFrom, sTo)
+	if err != nil {
+		log.Errorf("Error get comment rate: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := &ResponceCommentRate{Interval: interval.String(), Rates: []CommentRate{}}
+
+	for rows.Next() {
+		var rate CommentRate
+
+		err = rows.Scan(&rate.Time, &rate.Count)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		response.Rates = append(response.Rates, rate)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Отримати найпопулярніші за кількістю лайків коментарі відео
+func getTopCommentsFromDB(id string, limit int) ([]Comment, error) {
+	log.Debugf("id: %v, limit: %v", id, limit)
+
+	rows, err := db.Query(GET_TOP_COMMENTS, id, limit)
+	if err != nil {
+		log.Errorf("Error get top comments: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := []Comment{}
+
+	for rows.Next() {
+		var comment Comment
+
+		err = rows.Scan(&comment.Id, &comment.PublishedAt, &comment.LikeCount, &comment.ReplyCount, &comment.Text,
+			&comment.TimeUpdate)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		response = append(response, comment)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Додати користувача API. Пустий хеш - не задано. Якщо користувач з таким іменем вже є - conflict
+func addApiUserDB(user *ApiUser, tokenHash, passwordHash string, audit *auditEntry) error {
+	return auditChange(audit, SNAPSHOT_APIUSER, func(tx *sql.Tx) error {
+		res, err := tx.Exec(INSERT_APIUSER, user.Name, user.Role, sql.NullString{String: tokenHash, Valid: tokenHash != ""},
+			sql.NullString{String: passwordHash, Valid: passwordHash != ""})
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			return conflict("user already exists")
+		}
+
+		log.Debugf("insert api user: name=%v, role=%v", user.Name, user.Role)
+		return nil
+	})
+}
+
+// Замінити токен користувача API, попередній токен перестає діяти
+func updateApiUserTokenDB(name, tokenHash string, audit *auditEntry) error {
+	return auditChange(audit, SNAPSHOT_APIUSER, func(tx *sql.Tx) error {
+		res, err := tx.Exec(UPDATE_APIUSER_TOKEN, name, tokenHash)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			return notFound("user not found")
+		}
+
+		log.Debugf("updated api user token: name=%v", name)
+		return nil
+	})
+}
+
+// Видалити користувача API
+func deleteApiUserDB(name string, audit *auditEntry) error {
+	return auditChange(audit, SNAPSHOT_APIUSER, func(tx *sql.Tx) error {
+		res, err := tx.Exec(DELETE_APIUSER, name)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+		if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+			return notFound("user not found")
+		}
+
+		log.Debugf("deleted api user: name=%v", name)
+		return nil
+	})
+}
+
+// Виконати зміну в транзакції разом із записом в журнал аудиту. snapshot - запит стану об'єкта по audit.Target,
+// стан читається до та після зміни. Якщо id об'єкта стає відомим тільки після зміни (новий запит), change
+// задає audit.Target сам
+func auditChange(audit *auditEntry, snapshot string, change func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var before sql.NullString
+	if audit.Target != "" {
+		if before, err = snapshotDB(tx, snapshot, audit.Target); err != nil {
+			return err
+		}
+	}
+
+	if err = change(tx); err != nil {
+		return err
+	}
+
+	after, err := snapshotDB(tx, snapshot, audit.Target)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(INSERT_AUDIT, sql.NullString{String: audit.Actor, Valid: audit.Actor != ""}, audit.Action,
+		audit.Target, sql.NullString{String: audit.PlaylistId, Valid: audit.PlaylistId != ""}, before, after,
+		sql.NullString{String: audit.Addr, Valid: audit.Addr != ""})
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+	log.Debugf("audit: actor=%v, action=%v, target=%v, addr=%v", audit.Actor, audit.Action, audit.Target, audit.Addr)
+
+	return nil
+}
+
+// Стан об'єкта в json, NULL - об'єкта немає
+func snapshotDB(tx *sql.Tx, snapshot string, target string) (sql.NullString, error) {
+	var state sql.NullString
+	err := tx.QueryRow(snapshot, target).Scan(&state)
+	if err != nil && err != sql.ErrNoRows {
+		log.Errorf("err=%v", err)
+		return state, err
+	}
+	return state, nil
+}
+
+// Знайти відео за текстом, config - конфігурація для підсвічування знайдених слів
+func searchVideosFromDB(text, config, playlistId, channelId, from, to string, limit, offset int) (
+	[]*SearchResult, error) {
+	sFrom, err := checkDate(from)
+	if err != nil {
+		return nil, err
+	}
+	sTo, err := checkDate(to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(SEARCH_VIDEOS, config, text, playlistId, channelId, sFrom, sTo, limit, offset)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := []*SearchResult{}
+	for rows.Next() {
+		result := &SearchResult{}
+		err = rows.Scan(&result.Id, &result.PlaylistId, &result.Title, &result.ChannelTitle, &result.ChannelId,
+			&result.PublishedAt, &result.Rank, &result.TitleHighlight, &result.DescriptionHighlight)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return nil, err
+		}
+		response = append(response, result)
+	}
+	if err = rows.Err(); err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Отримати рейтинг відео за приростом метрики (column - префікс колонок в video_gain) за вікно period
+func getTopVideosFromDB(period, column, playlistId string, limit int) (*ResponceTop, error) {
+	response := &ResponceTop{Period: period, Items: []*TopVideo{}}
+
+	var updated sql.NullTime
+	if err := db.QueryRow(GET_TOP_UPDATED).Scan(&updated); err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+	if updated.Valid {
+		response.Updated = &updated.Time
+	}
+
+	rows, err := db.Query(fmt.Sprintf(GET_TOP_VIDEOS, column), period, playlistId, limit)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		video := &TopVideo{}
+		err = rows.Scan(&video.Id, &video.PlaylistId, &video.Title, &video.Ptitle, &video.PublishedAt, &video.Gain,
+			&video.Count, &video.TimeFrom, &video.TimeLast, &video.TimeUpdate)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return nil, err
+		}
+		response.Items = append(response.Items, video)
+	}
+	if err = rows.Err(); err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Отримати виміри метрик відео videos та відео плейлистів playlists з часу from, не більше limit
+func getStreamMetricsFromDB(videos, playlists []string, from time.Time, limit int) ([]*StreamMetric, error) {
+	rows, err := db.Query(GET_STREAM_METRICS, pq.Array(videos), pq.Array(playlists), from, limit)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := []*StreamMetric{}
+	for rows.Next() {
+		metric := &StreamMetric{}
+		err = rows.Scan(&metric.Id, &metric.PlaylistId, &metric.CommentCount, &metric.LikeCount,
+			&metric.DislikeCount, &metric.ViewCount, &metric.Time)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	if err = rows.Err(); err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+// Отримати нові відео плейлистів playlists, додані з часу from, не більше limit
+func getStreamVideosFromDB(playlists []string, from time.Time, limit int) ([]*StreamVideo, error) {
+	rows, err := db.Query(GET_STREAM_VIDEOS, pq.Array(playlists), from, limit)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	videos := []*StreamVideo{}
+	for rows.Next() {
+		video := &StreamVideo{}
+		err = rows.Scan(&video.Id, &video.PlaylistId, &video.Title, &video.PublishedAt, &video.TimeAdd)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return nil, err
+		}
+		videos = append(videos, video)
+	}
+	if err = rows.Err(); err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+
+	return videos, nil
+}
+
+// Отримати записи журналу аудиту, від новіших до старіших
+func getAuditFromDB(from, to, actor, playlistId string, limit int) ([]*AuditRecord, error) {
+	sFrom, err := checkDate(from)
+	if err != nil {
+		return nil, err
+	}
+	sTo, err := checkDate(to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(GET_AUDIT, sFrom, sTo, actor, playlistId, limit)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := []*AuditRecord{}
+	for rows.Next() {
+		record := &AuditRecord{}
+		var before, after []byte
+		err = rows.Scan(&record.Id, &record.Time, &record.Actor, &record.Action, &record.Target, &record.PlaylistId,
+			&before, &after, &record.Addr)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return nil, err
+		}
+		record.Before = json.RawMessage(before)
+		record.After = json.RawMessage(after)
+
+		response = append(response, record)
+	}
+	if err = rows.Err(); err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Прочитати користувача API з рядка результату
+func scanApiUser(scan func(dest ...interface{}) error) (*ApiUser, error) {
+	user := &ApiUser{}
+
+	err := scan(&user.Name, &user.Role, &user.Enable, &user.passwordHash, &user.tokenHash, &user.Timeadd)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Role = strings.TrimSpace(user.Role)
+	user.HasPassword = user.passwordHash != ""
+	user.HasToken = user.tokenHash != ""
+
+	return user, nil
+}
+
+// Отримати користувача API по імені, nil - користувача не знайдено
+func getApiUserByNameFromDB(name string) (*ApiUser, error) {
+	user, err := scanApiUser(db.QueryRow(GET_APIUSER_BY_NAME, name).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Errorf("Error get api user: %v", err)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Отримати користувача API по хешу токена, nil - користувача не знайдено
+func getApiUserByTokenFromDB(tokenHash string) (*ApiUser, error) {
+	user, err := scanApiUser(db.QueryRow(GET_APIUSER_BY_TOKEN, tokenHash).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.Errorf("Error get api user: %v", err)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Отримати всіх користувачів API
+func getApiUsersFromDB() ([]*ApiUser, error) {
+	rows, err := db.Query(GET_APIUSERS)
+	if err != nil {
+		log.Errorf("Error get api users: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := []*ApiUser{}
+
+	for rows.Next() {
+		user, err := scanApiUser(rows.Scan)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		response = append(response, user)
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Роздільність метрик для періоду: чим довший період, тим грубіші зведення.
+// Не заданий початок періоду - з часу публікації відео, не заданий кінець - до поточного часу
+func metricResolution(id, from, to string) (string, error) {
+	start, end := time.Time{}, time.Now()
+	if from != "" {
+		millis, err := strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			return "", badRequest("invalid date %v, expected milliseconds since epoch", from)
+		}
+		start = time.Unix(0, millis*int64(time.Millisecond))
+	} else {
+		publishedAt, err := getVideoPublishedAtFromDB(id)
+		if err != nil {
+			return "", err
+		}
+		start = publishedAt
+	}
+	if to != "" {
+		millis, err := strconv.ParseInt(to, 10, 64)
+		if err != nil {
+			return "", badRequest("invalid date %v, expected milliseconds since epoch", to)
+		}
+		end = time.Unix(0, millis*int64(time.Millisecond))
+	}
+
+	span := end.Sub(start)
+	switch {
+	case span > config.RollupDailySpan.Get():
+		return RESOLUTION_DAILY, nil
+	case span > config.RollupHourlySpan.Get():
+		return RESOLUTION_HOURLY, nil
+	default:
+		return RESOLUTION_RAW, nil
+	}
+}
+
+// Прочитати метрики відео за період з роздільністю resolution (RESOLUTION_*), для кожного рядка
+// викликається row. Рядки не накопичуються в пам'яті
+func streamMetricsFromDB(id, from, to, resolution string, row func(m *Metrics) error) error {
+	log.Debugf("id: %v, from: %v, to: %v, resolution: %v", id, from, to, resolution)
+
+	sFrom, err := checkDate(from)
+	if err != nil {
+		return err
+	}
+	sTo, err := checkDate(to)
+	if err != nil {
+		return err
+	}
+
+	query := GET_METRICS_RAW
+	switch resolution {
+	case RESOLUTION_HOURLY:
+		query = fmt.Sprintf(GET_METRICS_ROLLUP, "metric_hourly")
+	case RESOLUTION_DAILY:
+		query = fmt.Sprintf(GET_METRICS_ROLLUP, "metric_daily")
+	}
+
+	rows, err := db.Query(query, id, sFrom, sTo)
+	if err != nil {
+		log.Errorf("Error get metrics: %v", err)
+		return err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var m Metrics
+		err = rows.Scan(&m.CommentCount, &m.LikeCount, &m.DislikeCount, &m.ViewCount, &m.Time)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		if err = row(&m); err != nil {
+			return err
+		}
+		count++
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	if count == 0 {
+		return checkExists(GET_VIDEO_EXISTS, id, "video not found")
+	}
+	return nil
+}
+
+// Час публікації відео
+func getVideoPublishedAtFromDB(id string) (time.Time, error) {
+	var publishedAt time.Time
+	err := db.QueryRow(GET_VIDEO_PUBLISHED, id).Scan(&publishedAt)
+	if err == sql.ErrNoRows {
+		return publishedAt, notFound("video not found")
+	}
+	if err != nil {
+		log.Errorf("Error get video: %v", err)
+	}
+	return publishedAt, err
+}
+
+// Відео для порівняння разом з усіма їх метриками: за списком id (в порядку списку) або count останніх
+// відео плейлиста playlistId
+func getCompareVideosFromDB(ids []string, playlistId string, count int) ([]*compareVideo, error) {
+	log.Debugf("ids: %v, playlistId: %v, count: %v", ids, playlistId, count)
+
+	var rows *sql.Rows
+	var err error
+	if playlistId != "" {
+		rows, err = db.Query(GET_COMPARE_PLAYLIST_VIDEOS, playlistId, count)
+	} else {
+		rows, err = db.Query(GET_COMPARE_VIDEOS, pq.Array(ids))
+	}
+	if err != nil {
+		log.Errorf("Error get videos: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	videos := []*compareVideo{}
+	byId := make(map[string]*compareVideo)
+	for rows.Next() {
+		v := &compareVideo{metrics: []*Metrics{}}
+		if err = rows.Scan(&v.id, &v.title, &v.publishedAt); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		videos = append(videos, v)
+		byId[v.id] = v
+	}
+	if err = rows.Err(); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	if playlistId != "" {
+		if len(videos) == 0 {
+			if err = checkExists(GET_PLAYLIST_EXISTS, playlistId, "playlist not found"); err != nil {
+				return nil, err
+			}
+			return videos, nil
+		}
+		ids = make([]string, len(videos))
+		for i, v := range videos {
+			ids[i] = v.id
+		}
+	} else {
+		// порядок відео як в запиті
+		videos = videos[:0]
+		for _, id := range ids {
+			v, ok := byId[id]
+			if !ok {
+				return nil, notFound("video %v not found", id)
+			}
+			videos = append(videos, v)
+		}
+	}
+
+	metricRows, err := db.Query(GET_COMPARE_METRICS, pq.Array(ids))
+	if err != nil {
+		log.Errorf("Error get metrics: %v", err)
+		return nil, err
+	}
+	defer metricRows.Close()
+
+	for metricRows.Next() {
+		var id string
+		var m Metrics
+		err = metricRows.Scan(&id, &m.CommentCount, &m.LikeCount, &m.DislikeCount, &m.ViewCount, &m.Time)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		byId[id].metrics = append(byId[id].metrics, &m)
+	}
+	if err = metricRows.Err(); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return videos, nil
+}
+
+// Вибрати відео плейлиста (id = "" - всіх активних плейлистів) з фільтрами, сортуванням та курсором filter
+// та передати кожне в row. limit - кількість відео, NULL - всі відео
+func streamVideosFromDB(id string, filter *videoFilter, offset int, limit sql.NullInt64,
+	row func(v *YoutubeVideoShort) error) error {
+	log.Debugf("id: %v, filter: %+v, offset: %v, limit: %v", id, *filter, offset, limit)
+
+	args, err := videoFilterArgs(id, filter)
+	if err != nil {
+		return err
+	}
+
+	// з курсором зсув не використовується
+	cursorTime, cursorId := sql.NullTime{}, sql.NullString{}
+	if filter.Cursor != nil {
+		cursorTime = sql.NullTime{Time: filter.Cursor.PublishedAt, Valid: true}
+		cursorId = sql.NullString{String: filter.Cursor.Id, Valid: true}
+		offset = 0
+	}
+
+	rows, err := db.Query(fmt.Sprintf(GET_VIDEOS, videoSortOrder[filter.Sort]),
+		append(args, limit, offset, cursorTime, cursorId)...)
+	if err != nil {
+		log.Errorf("Error get videos: %v", err)
+		return err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		v := &YoutubeVideoShort{}
+		var timeMetric sql.NullTime
+		err = rows.Scan(&v.Id, &v.Title, &v.PublishedAt, &v.Ptitle, &v.ViewCount, &v.LikeCount, &v.CommentCount,
+			&timeMetric, &v.Growth)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		v.Id = strings.TrimSpace(v.Id)
+		if timeMetric.Valid {
+			v.TimeMetric = &timeMetric.Time
+		}
+		if err = row(v); err != nil {
+			return err
+		}
+		count++
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	// пустий результат: плейлиста немає, чи в ньому немає відео
+	if count == 0 && id != "" {
+		return checkExists(GET_PLAYLIST_EXISTS, id, "playlist not found")
+	}
+	return nil
+}
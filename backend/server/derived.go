@@ -0,0 +1,88 @@
+package server
+
+import (
+	"time"
+)
+
+// Похідні метрики: прирости лічильників, швидкості за годину та показники залученості. Виміри метрик
+// робляться з різними проміжками, тому прирости нормуються на фактичну довжину інтервалу
+
+// Розрахувати похідні метрики за вимірами, впорядкованими за часом. Виміри, ближчі за interval до
+// попереднього врахованого, пропускаються, щоб короткі інтервали не давали викидів швидкості
+func deriveMetrics(id string, publishedAt time.Time, interval time.Duration, metrics []*Metrics) *ResponceDerivedMetrics {
+	response := &ResponceDerivedMetrics{
+		Id:          id,
+		PublishedAt: publishedAt,
+		Interval:    interval.String(),
+		Deltas:      []MetricsDelta{},
+	}
+	if len(metrics) == 0 {
+		return response
+	}
+
+	first := metrics[0]
+	last := metrics[len(metrics)-1]
+	response.From = &first.Time
+	response.To = &last.Time
+	response.Total = metricsDelta(first, last)
+	response.Engagement = engagement(float64(last.ViewCount), float64(last.LikeCount),
+		float64(last.DislikeCount), float64(last.CommentCount))
+	response.RangeEngagement = engagement(float64(response.Total.View), float64(response.Total.Like),
+		float64(response.Total.Dislike), float64(response.Total.Comment))
+
+	prev := first
+	for _, m := range metrics[1:] {
+		if m.Time.Sub(prev.Time) < interval {
+			continue
+		}
+
+		delta := metricsDelta(prev, m)
+		response.Deltas = append(response.Deltas, delta)
+		prev = m
+
+		if response.PeakTime == nil || delta.ViewRate > response.PeakViewRate {
+			peakTime := delta.Time
+			response.PeakViewRate = delta.ViewRate
+			response.PeakTime = &peakTime
+			response.TimeToPeak = int64(peakTime.Sub(publishedAt) / time.Millisecond)
+		}
+	}
+
+	return response
+}
+
+// Приріст метрик між двома вимірами
+func metricsDelta(from, to *Metrics) MetricsDelta {
+	delta := MetricsDelta{
+		Time:    to.Time,
+		Period:  int64(to.Time.Sub(from.Time) / time.Millisecond),
+		View:    int64(to.ViewCount) - int64(from.ViewCount),
+		Like:    int64(to.LikeCount) - int64(from.LikeCount),
+		Dislike: int64(to.DislikeCount) - int64(from.DislikeCount),
+		Comment: int64(to.CommentCount) - int64(from.CommentCount),
+	}
+
+	hours := to.Time.Sub(from.Time).Hours()
+	if hours > 0 {
+		delta.ViewRate = float64(delta.View) / hours
+		delta.LikeRate = float64(delta.Like) / hours
+		delta.DislikeRate = float64(delta.Dislike) / hours
+		delta.CommentRate = float64(delta.Comment) / hours
+	}
+
+	return delta
+}
+
+// Відношення до переглядів та частка лайків, при нульовому знаменнику - 0
+func engagement(view, like, dislike, comment float64) Engagement {
+	var e Engagement
+	if view > 0 {
+		e.LikeView = like / view
+		e.DislikeView = dislike / view
+		e.CommentView = comment / view
+	}
+	if like+dislike > 0 {
+		e.LikeRatio = like / (like + dislike)
+	}
+	return e
+}
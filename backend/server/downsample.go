@@ -0,0 +1,203 @@
+package server
+
+import (
+	"math"
+	"time"
+)
+
+// Проріджування метрик для графіків: з усіх збережених вимірів за період залишається не більше points точок.
+// Алгоритми:
+//   lttb   - largest-triangle-three-buckets за переглядами: зберігає форму кривої та викиди
+//   minmax - виміри з найменшою та найбільшою кількістю переглядів в кожному інтервалі
+//   avg    - середні значення за рівні інтервали часу
+// Перший та останній виміри lttb та minmax залишають завжди
+
+const ALGO_LTTB = "lttb"
+const ALGO_MINMAX = "minmax"
+const ALGO_AVG = "avg"
+
+// Алгоритм за замовчуванням
+const DEFAULT_ALGO = ALGO_LTTB
+
+// Мінімальна кількість точок: перший та останній виміри
+const MIN_POINTS = 2
+
+func validAlgo(algo string) bool {
+	return algo == ALGO_LTTB || algo == ALGO_MINMAX || algo == ALGO_AVG
+}
+
+// Проріджити виміри, впорядковані за часом. Якщо вимірів не більше points, вони повертаються без змін
+func downsample(metrics []*Metrics, points int, algo string) []*Metrics {
+	if points < MIN_POINTS {
+		points = MIN_POINTS
+	}
+	if len(metrics) <= points {
+		return metrics
+	}
+
+	switch algo {
+	case ALGO_MINMAX:
+		return downsampleMinMax(metrics, points)
+	case ALGO_AVG:
+		return downsampleAvg(metrics, points)
+	default:
+		return downsampleLTTB(metrics, points)
+	}
+}
+
+// Координати виміру на графіку: час в секундах від першого виміру та перегляди
+func metricPoint(m *Metrics, start time.Time) (float64, float64) {
+	return m.Time.Sub(start).Seconds(), float64(m.ViewCount)
+}
+
+// Межі інтервалу i з count рівних за кількістю вимірів інтервалів, на які ділиться [from, to)
+func bucketBounds(i, count, from, to int) (int, int) {
+	size := float64(to-from) / float64(count)
+	return from + int(math.Floor(float64(i)*size)), from + int(math.Floor(float64(i+1)*size))
+}
+
+// Largest-triangle-three-buckets (Sveinn Steinarsson, 2013): виміри між першим та останнім діляться на
+// points-2 інтервалів, з кожного обирається вимір, що утворює найбільший трикутник з попереднім обраним
+// виміром та середньою точкою наступного інтервалу. Якщо всі виміри мають однаковий час, площі трикутників
+// нульові, тоді віссю x слугує номер виміру
+func downsampleLTTB(metrics []*Metrics, points int) []*Metrics {
+	n := len(metrics)
+	start := metrics[0].Time
+	buckets := points - 2
+
+	point := func(j int) (float64, float64) {
+		return metricPoint(metrics[j], start)
+	}
+	if metrics[n-1].Time.Equal(start) {
+		point = func(j int) (float64, float64) {
+			return float64(j), float64(metrics[j].ViewCount)
+		}
+	}
+
+	result := make([]*Metrics, 0, points)
+	result = append(result, metrics[0])
+
+	selected := 0
+	for i := 0; i < buckets; i++ {
+		from, to := bucketBounds(i, buckets, 1, n-1)
+
+		// середня точка наступного інтервалу, для останнього - останній вимір
+		nextFrom, nextTo := n-1, n
+		if i+1 < buckets {
+			nextFrom, nextTo = bucketBounds(i+1, buckets, 1, n-1)
+		}
+		var avgX, avgY float64
+		for j := nextFrom; j < nextTo; j++ {
+			x, y := point(j)
+			avgX += x
+			avgY += y
+		}
+		avgX /= float64(nextTo - nextFrom)
+		avgY /= float64(nextTo - nextFrom)
+
+		ax, ay := point(selected)
+		maxArea := -1.0
+		for j := from; j < to; j++ {
+			x, y := point(j)
+			area := math.Abs((ax-avgX)*(y-ay) - (ax-x)*(avgY-ay))
+			if area > maxArea {
+				maxArea = area
+				selected = j
+			}
+		}
+		result = append(result, metrics[selected])
+	}
+
+	return append(result, metrics[n-1])
+}
+
+// Виміри з найменшою та найбільшою кількістю переглядів в кожному з (points-2)/2 інтервалів
+// в порядку часу, плюс перший та останній виміри
+func downsampleMinMax(metrics []*Metrics, points int) []*Metrics {
+	n := len(metrics)
+	buckets := (points - 2) / 2
+
+	result := make([]*Metrics, 0, points)
+	result = append(result, metrics[0])
+
+	for i := 0; i < buckets; i++ {
+		from, to := bucketBounds(i, buckets, 1, n-1)
+		if from == to {
+			continue
+		}
+
+		min, max := from, from
+		for j := from + 1; j < to; j++ {
+			if metrics[j].ViewCount < metrics[min].ViewCount {
+				min = j
+			}
+			if metrics[j].ViewCount > metrics[max].ViewCount {
+				max = j
+			}
+		}
+
+		switch {
+		case min == max:
+			result = append(result, metrics[min])
+		case min < max:
+			result = append(result, metrics[min], metrics[max])
+		default:
+			result = append(result, metrics[max], metrics[min])
+		}
+	}
+
+	return append(result, metrics[n-1])
+}
+
+// Середні значення вимірів за points рівних інтервалів часу. Час точки - середній час вимірів інтервалу,
+// інтервали без вимірів пропускаються
+func downsampleAvg(metrics []*Metrics, points int) []*Metrics {
+	start := metrics[0].Time
+	width := float64(metrics[len(metrics)-1].Time.Sub(start)) / float64(points)
+
+	result := make([]*Metrics, 0, points)
+
+	var sumComment, sumLike, sumDislike, sumView, sumTime float64
+	count := 0
+	bucket := 0
+
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		c := float64(count)
+		result = append(result, &Metrics{
+			CommentCount: uint64(math.Round(sumComment / c)),
+			LikeCount:    uint64(math.Round(sumLike / c)),
+			DislikeCount: uint64(math.Round(sumDislike / c)),
+			ViewCount:    uint64(math.Round(sumView / c)),
+			Time:         start.Add(time.Duration(sumTime / c)),
+		})
+		sumComment, sumLike, sumDislike, sumView, sumTime = 0, 0, 0, 0, 0
+		count = 0
+	}
+
+	for _, m := range metrics {
+		offset := m.Time.Sub(start)
+		b := points - 1
+		if width > 0 {
+			if i := int(float64(offset) / width); i < b {
+				b = i
+			}
+		}
+		if b != bucket {
+			flush()
+			bucket = b
+		}
+
+		sumComment += float64(m.CommentCount)
+		sumLike += float64(m.LikeCount)
+		sumDislike += float64(m.DislikeCount)
+		sumView += float64(m.ViewCount)
+		sumTime += float64(offset)
+		count++
+	}
+	flush()
+
+	return result
+}
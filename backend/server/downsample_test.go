@@ -0,0 +1,186 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Виміри з переглядами views через step один від одного, лайки та коментарі - частки переглядів
+func testSeries(step time.Duration, views ...uint64) []*Metrics {
+	metrics := make([]*Metrics, len(views))
+	for i, v := range views {
+		metrics[i] = &Metrics{CommentCount: v / 100, LikeCount: v / 10, ViewCount: v,
+			Time: testStart.Add(step * time.Duration(i))}
+	}
+	return metrics
+}
+
+// Лінійно зростаючі перегляди зі сплеском spike в позиції at
+func testSpike(n, at int, spike uint64) []*Metrics {
+	views := make([]uint64, n)
+	for i := range views {
+		views[i] = uint64(i) * 10
+	}
+	views[at] = spike
+	return testSeries(time.Minute, views...)
+}
+
+func containsMetric(metrics []*Metrics, m *Metrics) bool {
+	for _, r := range metrics {
+		if r == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Результат lttb та minmax: перший та останній виміри на місці, не більше points точок, порядок часу
+func checkSelected(t *testing.T, metrics, result []*Metrics, points int) {
+	t.Helper()
+	if len(result) > points {
+		t.Errorf("len = %v, want at most %v", len(result), points)
+	}
+	if result[0] != metrics[0] || result[len(result)-1] != metrics[len(metrics)-1] {
+		t.Errorf("first and last samples are not kept")
+	}
+	for i := 1; i < len(result); i++ {
+		if result[i].Time.Before(result[i-1].Time) {
+			t.Errorf("samples are not ordered by time at %v", i)
+		}
+	}
+}
+
+func TestDownsampleUnchanged(t *testing.T) {
+	metrics := testSeries(time.Minute, 1, 2, 3, 4, 5)
+	for _, algo := range []string{ALGO_LTTB, ALGO_MINMAX, ALGO_AVG} {
+		for _, points := range []int{5, 6, 100} {
+			result := downsample(metrics, points, algo)
+			if len(result) != len(metrics) {
+				t.Errorf("%v, points %v: len = %v, want %v", algo, points, len(result), len(metrics))
+				continue
+			}
+			for i := range metrics {
+				if result[i] != metrics[i] {
+					t.Errorf("%v, points %v: sample %v is changed", algo, points, i)
+				}
+			}
+		}
+	}
+}
+
+func TestDownsampleLTTB(t *testing.T) {
+	equalTime := testSeries(0, 0, 10, 20, 30, 1000, 50, 60, 70, 80, 90)
+
+	tests := []struct {
+		name    string
+		metrics []*Metrics
+		points  int
+		len     int
+		keep    []int
+	}{
+		{"two points", testSeries(time.Minute, 1, 2, 3, 4, 5, 6), 2, 2, []int{0, 5}},
+		{"spike", testSpike(20, 10, 10000), 5, 5, []int{10}},
+		{"spike at bucket edge", testSpike(20, 1, 10000), 4, 4, []int{1}},
+		{"equal timestamps", equalTime, 4, 4, []int{4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := downsampleLTTB(tt.metrics, tt.points)
+			if len(result) != tt.len {
+				t.Errorf("len = %v, want %v", len(result), tt.len)
+			}
+			checkSelected(t, tt.metrics, result, tt.points)
+			for _, i := range tt.keep {
+				if !containsMetric(result, tt.metrics[i]) {
+					t.Errorf("sample %v (views %v) is lost", i, tt.metrics[i].ViewCount)
+				}
+			}
+		})
+	}
+}
+
+func TestDownsampleMinMax(t *testing.T) {
+	dip := testSpike(20, 10, 10000)
+	dip[5].ViewCount = 0
+
+	tests := []struct {
+		name    string
+		metrics []*Metrics
+		points  int
+		len     int
+		keep    []int
+	}{
+		{"two points", testSeries(time.Minute, 1, 2, 3, 4, 5, 6), 2, 2, []int{0, 5}},
+		{"spike", testSpike(20, 10, 10000), 6, 6, []int{10}},
+		{"spike and dip in one bucket", dip, 4, 4, []int{5, 10}},
+		{"equal timestamps", testSeries(0, 5, 1, 7, 3, 9, 2, 8, 4), 4, 4, []int{1, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := downsampleMinMax(tt.metrics, tt.points)
+			if len(result) != tt.len {
+				t.Errorf("len = %v, want %v", len(result), tt.len)
+			}
+			checkSelected(t, tt.metrics, result, tt.points)
+			for _, i := range tt.keep {
+				if !containsMetric(result, tt.metrics[i]) {
+					t.Errorf("sample %v (views %v) is lost", i, tt.metrics[i].ViewCount)
+				}
+			}
+		})
+	}
+}
+
+func TestDownsampleAvg(t *testing.T) {
+	type point struct {
+		view, like, comment uint64
+		offset              time.Duration
+	}
+
+	tests := []struct {
+		name    string
+		metrics []*Metrics
+		points  int
+		want    []point
+	}{
+		{"two points", testSeries(time.Minute, 0, 100, 200, 300, 400, 500), 2, []point{
+			{100, 10, 1, time.Minute},
+			{400, 40, 4, time.Minute * 4},
+		}},
+		{"three points", testSeries(time.Minute, 0, 10, 20, 30, 40, 50), 3, []point{
+			{5, 1, 0, time.Second * 30},
+			{25, 3, 0, time.Second * 150},
+			{45, 5, 0, time.Second * 270},
+		}},
+		{"empty buckets are skipped", append(testSeries(time.Minute, 0, 100, 200),
+			&Metrics{ViewCount: 1000, LikeCount: 100, CommentCount: 10, Time: testStart.Add(time.Minute * 10)}), 5,
+			[]point{
+				{50, 5, 1, time.Second * 30},
+				{200, 20, 2, time.Minute * 2},
+				{1000, 100, 10, time.Minute * 10},
+			}},
+		{"equal timestamps", testSeries(0, 100, 200, 300, 400), 2, []point{
+			{250, 25, 3, 0},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := downsampleAvg(tt.metrics, tt.points)
+			if len(result) != len(tt.want) {
+				t.Fatalf("len = %v, want %v", len(result), len(tt.want))
+			}
+			for i, w := range tt.want {
+				got := point{result[i].ViewCount, result[i].LikeCount, result[i].CommentCount,
+					result[i].Time.Sub(testStart)}
+				if got != w {
+					t.Errorf("point %v = %+v, want %+v", i, got, w)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+
+	"github.com/lib/pq"
+)
+
+// Помилки API. Кожна відповідь з помилкою має json-тіло з машинним кодом, повідомленням та id запиту:
+// {"code": "not_found", "message": "video not found", "request_id": "5f1d2c..."}.
+// Помилки БД та інші внутрішні помилки пишуться в лог з id запиту, клієнту віддається тільки загальне повідомлення
+
+// Коди помилок
+const ERR_BAD_REQUEST = "bad_request"
+const ERR_UNAUTHORIZED = "unauthorized"
+const ERR_FORBIDDEN = "forbidden"
+const ERR_ORIGIN_NOT_ALLOWED = "origin_not_allowed"
+const ERR_NOT_FOUND = "not_found"
+const ERR_CONFLICT = "conflict"
+const ERR_INTERNAL = "internal"
+const ERR_UNAVAILABLE = "unavailable"
+
+// Заголовок з id запиту. Якщо клієнт передав коректний id, він використовується, інакше генерується новий
+const REQUEST_ID_HEADER = "X-Request-ID"
+const REQUEST_ID_CONTEXT_KEY = contextKey("requestId")
+
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Помилка з HTTP-статусом та кодом, повідомлення віддається клієнту
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func newApiError(status int, code, message string) *apiError {
+	return &apiError{status, code, message}
+}
+
+// Некоректні параметри запиту, 400
+func badRequest(format string, args ...interface{}) error {
+	return newApiError(http.StatusBadRequest, ERR_BAD_REQUEST, fmt.Sprintf(format, args...))
+}
+
+// Об'єкт не знайдено, 404
+func notFound(format string, args ...interface{}) error {
+	return newApiError(http.StatusNotFound, ERR_NOT_FOUND, fmt.Sprintf(format, args...))
+}
+
+// Стан об'єкта не дозволяє виконати запит, 409
+func conflict(format string, args ...interface{}) error {
+	return newApiError(http.StatusConflict, ERR_CONFLICT, fmt.Sprintf(format, args...))
+}
+
+// Сервіс тимчасово не може виконати запит, 503
+func unavailable(format string, args ...interface{}) error {
+	return newApiError(http.StatusServiceUnavailable, ERR_UNAVAILABLE, fmt.Sprintf(format, args...))
+}
+
+// Визначити статус та код помилки. Помилки БД перетворюються за класом помилки Postgres
+func toApiError(err error) *apiError {
+	var e *apiError
+	if errors.As(err, &e) {
+		return e
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return newApiError(http.StatusNotFound, ERR_NOT_FOUND, "not found")
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23505":
+			return newApiError(http.StatusConflict, ERR_CONFLICT, "already exists")
+		case pqErr.Code == "23503":
+			return newApiError(http.StatusConflict, ERR_CONFLICT, "referenced object does not exist or is in use")
+		case pqErr.Code == "P0001":
+			// raise_exception у функціях БД, коли за період немає даних
+			return newApiError(http.StatusNotFound, ERR_NOT_FOUND, "no data for the period")
+		case pqErr.Code.Class() == "22" || pqErr.Code.Class() == "23":
+			// data_exception, integrity_constraint_violation: некоректні дані запиту
+			return newApiError(http.StatusBadRequest, ERR_BAD_REQUEST, "invalid value")
+		case pqErr.Code.Class() == "08" || pqErr.Code.Class() == "53" || pqErr.Code.Class() == "57":
+			// connection_exception, insufficient_resources, operator_intervention
+			return newApiError(http.StatusServiceUnavailable, ERR_UNAVAILABLE, "database is unavailable")
+		}
+		return newApiError(http.StatusInternalServerError, ERR_INTERNAL, "internal error")
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return newApiError(http.StatusServiceUnavailable, ERR_UNAVAILABLE, "database is unavailable")
+	}
+
+	return newApiError(http.StatusInternalServerError, ERR_INTERNAL, "internal error")
+}
+
+// Записати у відповідь помилку в json-форматі
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	e := toApiError(err)
+	requestId := getRequestId(r)
+
+	if e.status >= http.StatusInternalServerError {
+		log.Errorf("request_id: %v, uri: %v, err=%v", requestId, r.RequestURI, err)
+	} else {
+		log.Infof("request_id: %v, uri: %v, status: %v, err=%v", requestId, r.RequestURI, e.status, err)
+	}
+
+	errorJson, jsonErr := json.Marshal(&ResponceError{e.code, e.message, requestId})
+	if jsonErr != nil {
+		http.Error(w, e.message, e.status)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(e.status)
+	w.Write(errorJson)
+}
+
+// Обгортка, яка присвоює кожному запиту id: додає його в контекст запиту та в заголовок відповіді
+func requestIdHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(REQUEST_ID_HEADER)
+		if !validRequestId.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+
+		w.Header().Set(REQUEST_ID_HEADER, requestId)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), REQUEST_ID_CONTEXT_KEY, requestId)))
+	})
+}
+
+// id запиту з контексту
+func getRequestId(r *http.Request) string {
+	requestId, _ := r.Context().Value(REQUEST_ID_CONTEXT_KEY).(string)
+	return requestId
+}
+
+func newRequestId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestToApiError(t *testing.T) {
+	pqError := func(code string) error {
+		return fmt.Errorf("query: %w", &pq.Error{Code: pq.ErrorCode(code), Message: "password=secret"})
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"api error", fmt.Errorf("wrapped: %w", badRequest("invalid id")), http.StatusBadRequest, ERR_BAD_REQUEST},
+		{"no rows", fmt.Errorf("get video: %w", sql.ErrNoRows), http.StatusNotFound, ERR_NOT_FOUND},
+		{"unique violation", pqError("23505"), http.StatusConflict, ERR_CONFLICT},
+		{"foreign key violation", pqError("23503"), http.StatusConflict, ERR_CONFLICT},
+		{"check violation", pqError("23514"), http.StatusBadRequest, ERR_BAD_REQUEST},
+		{"invalid text representation", pqError("22P02"), http.StatusBadRequest, ERR_BAD_REQUEST},
+		{"datetime overflow", pqError("22008"), http.StatusBadRequest, ERR_BAD_REQUEST},
+		{"raise exception", pqError("P0001"), http.StatusNotFound, ERR_NOT_FOUND},
+		{"connection failure", pqError("08006"), http.StatusServiceUnavailable, ERR_UNAVAILABLE},
+		{"too many connections", pqError("53300"), http.StatusServiceUnavailable, ERR_UNAVAILABLE},
+		{"admin shutdown", pqError("57P01"), http.StatusServiceUnavailable, ERR_UNAVAILABLE},
+		{"undefined table", pqError("42P01"), http.StatusInternalServerError, ERR_INTERNAL},
+		{"bad connection", driver.ErrBadConn, http.StatusServiceUnavailable, ERR_UNAVAILABLE},
+		{"deadline exceeded", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusServiceUnavailable,
+			ERR_UNAVAILABLE},
+		{"net error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			http.StatusServiceUnavailable, ERR_UNAVAILABLE},
+		{"dns error", fmt.Errorf("open: %w", &net.DNSError{Err: "no such host", Name: "db"}),
+			http.StatusServiceUnavailable, ERR_UNAVAILABLE},
+		{"other error", errors.New("password=secret"), http.StatusInternalServerError, ERR_INTERNAL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := toApiError(tt.err)
+			if e.status != tt.status || e.code != tt.code {
+				t.Errorf("toApiError(%v) = %v, %v, want %v, %v", tt.err, e.status, e.code, tt.status, tt.code)
+			}
+			// текст помилок БД не потрапляє до клієнта
+			if e.status != http.StatusBadRequest && e.message == tt.err.Error() {
+				t.Errorf("message = %q", e.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,418 @@
+package server
+
+import (
+	"archive/zip"
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+)
+
+// Експорт метрик та списків відео в CSV, NDJSON та XLSX. Формат задається параметром format= або заголовком Accept,
+// за замовчуванням - json. Рядки пишуться у відповідь по мірі читання з БД, не накопичуючись в пам'яті
+
+const FORMAT_JSON = "json"
+const FORMAT_CSV = "csv"
+const FORMAT_NDJSON = "ndjson"
+const FORMAT_XLSX = "xlsx"
+
+// Типи вмісту форматів експорту
+var formatContentTypes = map[string]string{
+	FORMAT_JSON:   "application/json",
+	FORMAT_CSV:    "text/csv; charset=utf-8",
+	FORMAT_NDJSON: "application/x-ndjson",
+	FORMAT_XLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// Кількість рядків, після якої відповідь відправляється клієнту
+const EXPORT_FLUSH_ROWS = 1000
+
+// Формат відповіді: параметр format, інакше перший відомий тип з заголовка Accept, інакше json
+func exportFormat(r *http.Request) (string, error) {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		if _, ok := formatContentTypes[format]; !ok {
+			return "", badRequest("unknown format: %v, expected: json, csv, ndjson or xlsx", format)
+		}
+		return format, nil
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(accept, ";", 2)[0])
+		for format, contentType := range formatContentTypes {
+			if mediaType == strings.SplitN(contentType, ";", 2)[0] {
+				return format, nil
+			}
+		}
+	}
+
+	return FORMAT_JSON, nil
+}
+
+// Запис рядків таблиці у відповідь в заданому форматі
+type exportWriter interface {
+	// Записати рядок значень, порядок значень відповідає колонкам
+	row(values ...interface{}) error
+
+	// Завершити запис
+	close() error
+
+	// Перервати запис: записані рядки відправляються, але документ не завершується (немає кінця масиву json,
+	// zip-архів XLSX без змісту), щоб клієнт бачив, що вивантаження неповне
+	abort() error
+}
+
+// Почати експорт: заголовки відповіді та запис у форматі format. name - ім'я файлу без розширення
+func newExport(w http.ResponseWriter, format, name string, columns []string) (exportWriter, error) {
+	// вивантаження всіх рядків може тривати довше за WriteTimeout сервера
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(config.ExportTimeout.Get())); err != nil {
+		log.Debugf("cannot set write deadline: %v", err)
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, formatContentTypes[format])
+	if format != FORMAT_JSON {
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"."+format+"\"")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	flush := func() {
+		if err := rc.Flush(); err != nil {
+			log.Debugf("cannot flush: %v", err)
+		}
+	}
+
+	switch format {
+	case FORMAT_CSV:
+		e := &csvExport{w: csv.NewWriter(w), flush: flush}
+		return e, e.w.Write(columns)
+	case FORMAT_NDJSON:
+		return &jsonExport{w: bufio.NewWriter(w), columns: columns, flush: flush, lines: true}, nil
+	case FORMAT_XLSX:
+		e, err := newXlsxExport(w, columns)
+		if err != nil {
+			return nil, err
+		}
+		return e, nil
+	default:
+		return &jsonExport{w: bufio.NewWriter(w), columns: columns, flush: flush}, nil
+	}
+}
+
+// Значення комірки у текстовому вигляді
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type csvExport struct {
+	w     *csv.Writer
+	flush func()
+	count int
+}
+
+func (e *csvExport) row(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = formatValue(value)
+	}
+	if err := e.w.Write(record); err != nil {
+		return err
+	}
+
+	e.count++
+	if e.count%EXPORT_FLUSH_ROWS == 0 {
+		e.w.Flush()
+		e.flush()
+	}
+	return e.w.Error()
+}
+
+func (e *csvExport) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExport) abort() error {
+	return e.close()
+}
+
+// Експорт в json: масив об'єктів, або по об'єкту в рядку (NDJSON, lines = true).
+// Ключі об'єктів - назви колонок в заданому порядку
+type jsonExport struct {
+	w       *bufio.Writer
+	columns []string
+	flush   func()
+	lines   bool
+	count   int
+}
+
+func (e *jsonExport) row(values ...interface{}) error {
+	if !e.lines {
+		if e.count == 0 {
+			e.w.WriteByte('[')
+		} else {
+			e.w.WriteByte(',')
+		}
+	}
+
+	e.w.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(e.columns[i])
+		b, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		e.w.Write(key)
+		e.w.WriteByte(':')
+		e.w.Write(b)
+	}
+	e.w.WriteByte('}')
+	if e.lines {
+		e.w.WriteByte('\n')
+	}
+
+	e.count++
+	if e.count%EXPORT_FLUSH_ROWS == 0 {
+		if err := e.w.Flush(); err != nil {
+			return err
+		}
+		e.flush()
+	}
+	return nil
+}
+
+func (e *jsonExport) close() error {
+	if !e.lines {
+		if e.count == 0 {
+			e.w.WriteByte('[')
+		}
+		e.w.WriteByte(']')
+	}
+	return e.w.Flush()
+}
+
+func (e *jsonExport) abort() error {
+	return e.w.Flush()
+}
+
+// Експорт в XLSX: zip-архів з мінімальним набором частин (один аркуш, рядки з вбудованими рядками та числами).
+// Аркуш пишеться потоком, zip не потребує знати розмір файлу заздалегідь
+type xlsxExport struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	count int
+}
+
+const XLSX_CONTENT_TYPES = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const XLSX_RELS = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const XLSX_WORKBOOK = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="data" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const XLSX_WORKBOOK_RELS = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
+
+const XLSX_SHEET_START = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const XLSX_SHEET_END = `</sheetData></worksheet>`
+
+func newXlsxExport(w io.Writer, columns []string) (*xlsxExport, error) {
+	zw := zip.NewWriter(w)
+
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", XLSX_CONTENT_TYPES},
+		{"_rels/.rels", XLSX_RELS},
+		{"xl/workbook.xml", XLSX_WORKBOOK},
+		{"xl/_rels/workbook.xml.rels", XLSX_WORKBOOK_RELS},
+	} {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	e := &xlsxExport{zw: zw, sheet: bufio.NewWriter(f)}
+	e.sheet.WriteString(XLSX_SHEET_START)
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	return e, e.row(header...)
+}
+
+func (e *xlsxExport) row(values ...interface{}) error {
+	e.count++
+	e.sheet.WriteString(`<row r="` + strconv.Itoa(e.count) + `">`)
+	for _, value := range values {
+		switch v := value.(type) {
+		case int, int64, uint64, float64:
+			e.sheet.WriteString(`<c t="n"><v>` + formatValue(v) + `</v></c>`)
+		default:
+			e.sheet.WriteString(`<c t="inlineStr"><is><t>`)
+			if err := xml.EscapeText(e.sheet, []byte(formatValue(v))); err != nil {
+				return err
+			}
+			e.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := e.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (e *xlsxExport) close() error {
+	e.sheet.WriteString(XLSX_SHEET_END)
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
+
+func (e *xlsxExport) abort() error {
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zw.Flush()
+}
+
+// Експорт рядків, які читає stream. Відповідь починається з першим рядком: якщо stream завершився помилкою
+// до першого рядка, клієнт отримує звичайну відповідь з помилкою, інакше вивантаження обривається незавершеним
+// документом (див. exportWriter.abort)
+func exportRows(w http.ResponseWriter, r *http.Request, format, name string, columns []string,
+	stream func(row func(values ...interface{}) error) error) {
+	var e exportWriter
+
+	err := stream(func(values ...interface{}) error {
+		if e == nil {
+			var err error
+			if e, err = newExport(w, format, name, columns); err != nil {
+				return err
+			}
+		}
+		return e.row(values...)
+	})
+
+	if e == nil {
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		// рядків немає: порожній файл з заголовком
+		if e, err = newExport(w, format, name, columns); err != nil {
+			log.Errorf("request_id: %v, export error: %v", getRequestId(r), err)
+			return
+		}
+	} else if err != nil {
+		log.Errorf("request_id: %v, export is interrupted: %v", getRequestId(r), err)
+		if err = e.abort(); err != nil {
+			log.Errorf("request_id: %v, export error: %v", getRequestId(r), err)
+		}
+		return
+	}
+
+	if err = e.close(); err != nil {
+		log.Errorf("request_id: %v, export error: %v", getRequestId(r), err)
+	}
+}
+
+// Експорт метрик відео: raw = true - всі збережені метрики, інакше проріджені до points точок алгоритмом algo
+func exportMetrics(w http.ResponseWriter, r *http.Request, format, id, from, to string, raw bool, points int, algo string) {
+	columns := []string{"mtime", "view", "like", "dislike", "comment"}
+
+	exportRows(w, r, format, "metrics-"+id, columns, func(row func(values ...interface{}) error) error {
+		writeMetric := func(m *Metrics) error {
+			return row(m.Time, m.ViewCount, m.LikeCount, m.DislikeCount, m.CommentCount)
+		}
+		if raw {
+			return streamMetricsFromDB(id, from, to, RESOLUTION_RAW, writeMetric)
+		}
+
+		metrics, err := getMetricsByIdFromDB(id, from, to, points, algo)
+		if err != nil {
+			return err
+		}
+		for _, m := range metrics {
+			if err = writeMetric(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Експорт списку відео, id = "" - відео всіх активних плейлистів
+func exportVideos(w http.ResponseWriter, r *http.Request, format, id string, filter *videoFilter, offset int, raw bool) {
+	columns := []string{"id", "title", "publishedat", "ptitle", "view", "like", "comment", "mtime", "growth"}
+	name := "videos"
+	if id != "" {
+		name += "-" + id
+	}
+
+	exportRows(w, r, format, name, columns, func(row func(values ...interface{}) error) error {
+		// raw - всі відео, інакше - сторінка
+		limit := sql.NullInt64{Int64: int64(filter.Limit), Valid: !raw}
+		if raw {
+			offset = 0
+		}
+		return streamVideosFromDB(id, filter, offset, limit, func(v *YoutubeVideoShort) error {
+			// відео без метрик - пустий час виміру
+			var timeMetric interface{} = ""
+			if v.TimeMetric != nil {
+				timeMetric = *v.TimeMetric
+			}
+			return row(v.Id, v.Title, v.PublishedAt, v.Ptitle, v.ViewCount, v.LikeCount, v.CommentCount, timeMetric,
+				v.Growth)
+		})
+	})
+}
+
+// Чи потрібен експорт замість звичайної json-відповіді: заданий не json формат або raw=true
+func exportParams(r *http.Request) (format string, raw bool, export bool, err error) {
+	format, err = exportFormat(r)
+	if err != nil {
+		return "", false, false, err
+	}
+	raw, _ = strconv.ParseBool(r.URL.Query().Get("raw"))
+
+	return format, raw, format != FORMAT_JSON || raw, nil
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testExportColumns = []string{"id", "title", "view", "mtime"}
+
+// Рядки з символами, які треба екранувати в кожному з форматів
+var testExportRows = [][]interface{}{
+	{"v1", `comma, "quotes"`, uint64(10), testStart},
+	{"v2", "line\nbreak <tag> & амперсанд", uint64(20), testStart.Add(time.Hour)},
+	{"v3", "", uint64(0), ""},
+}
+
+// Експорт rows у форматі format. Якщо err != nil, stream завершується помилкою після rows
+func testExport(t *testing.T, format string, rows [][]interface{}, err error) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/view/videos?format="+format, nil)
+
+	exportRows(w, r, format, "videos", testExportColumns, func(row func(values ...interface{}) error) error {
+		for _, values := range rows {
+			if err := row(values...); err != nil {
+				return err
+			}
+		}
+		return err
+	})
+	return w
+}
+
+// Очікувані значення рядків у текстовому вигляді (CSV, XLSX)
+func testExportRecords(rows [][]interface{}) [][]string {
+	records := [][]string{testExportColumns}
+	for _, values := range rows {
+		record := make([]string, len(values))
+		for i, value := range values {
+			record[i] = formatValue(value)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func equalRecords(a, b [][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equalStrings(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExportCSV(t *testing.T) {
+	w := testExport(t, FORMAT_CSV, testExportRows, nil)
+
+	if w.Code != http.StatusOK || w.Header().Get(CONTENT_TYPE_KEY) != formatContentTypes[FORMAT_CSV] {
+		t.Errorf("status = %v, content type = %v", w.Code, w.Header().Get(CONTENT_TYPE_KEY))
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="videos.csv"` {
+		t.Errorf("Content-Disposition = %v", got)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testExportRecords(testExportRows); !equalRecords(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	w := testExport(t, FORMAT_JSON, testExportRows, nil)
+
+	if w.Header().Get("Content-Disposition") != "" {
+		t.Errorf("json export is an attachment")
+	}
+	// ключі об'єктів йдуть в порядку колонок
+	if body := w.Body.String(); !strings.HasPrefix(body, `[{"id":"v1","title":"comma, \"quotes\"","view":10,"mtime":`) {
+		t.Errorf("body = %v", body)
+	}
+
+	var objects []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &objects); err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != len(testExportRows) {
+		t.Fatalf("objects = %v", objects)
+	}
+	if objects[1]["title"] != testExportRows[1][1] || objects[1]["mtime"] != testStart.Add(time.Hour).Format(time.RFC3339) {
+		t.Errorf("object = %v", objects[1])
+	}
+
+	if body := testExport(t, FORMAT_JSON, nil, nil).Body.String(); body != "[]" {
+		t.Errorf("empty export = %v", body)
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	w := testExport(t, FORMAT_NDJSON, testExportRows, nil)
+
+	lines := 0
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var object map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &object); err != nil {
+			t.Fatalf("line %v: %v", lines, err)
+		}
+		if object["id"] != testExportRows[lines][0] || object["title"] != testExportRows[lines][1] {
+			t.Errorf("line %v = %v", lines, object)
+		}
+		lines++
+	}
+	if lines != len(testExportRows) {
+		t.Errorf("lines = %v, want %v", lines, len(testExportRows))
+	}
+
+	if body := testExport(t, FORMAT_NDJSON, nil, nil).Body.String(); body != "" {
+		t.Errorf("empty export = %q", body)
+	}
+}
+
+// Аркуш XLSX: рядки з комірками, числа - <v>, рядки - <is><t>
+type testSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			T      string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXlsxSheet(t *testing.T, body []byte) (string, *testSheet) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(b)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels",
+		"xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("part %v is missing", name)
+		}
+	}
+
+	sheet := &testSheet{}
+	if err := xml.Unmarshal([]byte(parts["xl/worksheets/sheet1.xml"]), sheet); err != nil {
+		t.Fatal(err)
+	}
+	return parts["xl/worksheets/sheet1.xml"], sheet
+}
+
+func TestExportXLSX(t *testing.T) {
+	w := testExport(t, FORMAT_XLSX, testExportRows, nil)
+
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="videos.xlsx"` {
+		t.Errorf("Content-Disposition = %v", got)
+	}
+
+	raw, sheet := readXlsxSheet(t, w.Body.Bytes())
+	if !strings.Contains(raw, "line&#xA;break &lt;tag&gt; &amp; амперсанд") ||
+		!strings.Contains(raw, "comma, &#34;quotes&#34;") {
+		t.Errorf("text is not escaped: %v", raw)
+	}
+
+	want := testExportRecords(testExportRows)
+	if len(sheet.Rows) != len(want) {
+		t.Fatalf("rows = %v, want %v", len(sheet.Rows), len(want))
+	}
+	for i, row := range sheet.Rows {
+		if row.R != i+1 {
+			t.Errorf("row %v number = %v", i, row.R)
+		}
+		got := make([]string, len(row.Cells))
+		for j, cell := range row.Cells {
+			// числові значення - числа, інші - вбудовані рядки
+			number := i > 0 && j == 2
+			if number && (cell.T != "n" || cell.Inline != "") || !number && (cell.T != "inlineStr" || cell.Value != "") {
+				t.Errorf("row %v cell %v = %+v", i, j, cell)
+			}
+			got[j] = cell.Value + cell.Inline
+		}
+		if !equalStrings(got, want[i]) {
+			t.Errorf("row %v = %q, want %q", i, got, want[i])
+		}
+	}
+
+	// без рядків - тільки заголовок
+	if _, sheet = readXlsxSheet(t, testExport(t, FORMAT_XLSX, nil, nil).Body.Bytes()); len(sheet.Rows) != 1 {
+		t.Errorf("empty export rows = %v", len(sheet.Rows))
+	}
+}
+
+func TestExportErrorBeforeFirstRow(t *testing.T) {
+	for _, format := range []string{FORMAT_JSON, FORMAT_CSV, FORMAT_NDJSON, FORMAT_XLSX} {
+		t.Run(format, func(t *testing.T) {
+			w := testExport(t, format, nil, badRequest("invalid period"))
+
+			if w.Code != http.StatusBadRequest || w.Header().Get(CONTENT_TYPE_KEY) != CONTENT_TYPE_VALUE {
+				t.Errorf("status = %v, content type = %v", w.Code, w.Header().Get(CONTENT_TYPE_KEY))
+			}
+			if w.Header().Get("Content-Disposition") != "" {
+				t.Error("error is an attachment")
+			}
+			e := &ResponceError{}
+			if err := json.Unmarshal(w.Body.Bytes(), e); err != nil || e.Message != "invalid period" {
+				t.Errorf("error = %+v, %v", e, err)
+			}
+		})
+	}
+}
+
+func TestExportErrorAfterFirstRow(t *testing.T) {
+	rows, failure := testExportRows[:2], errors.New("connection reset")
+
+	t.Run(FORMAT_CSV, func(t *testing.T) {
+		w := testExport(t, FORMAT_CSV, rows, failure)
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := testExportRecords(rows); w.Code != http.StatusOK || !equalRecords(records, want) {
+			t.Errorf("status = %v, records = %q, want %q", w.Code, records, want)
+		}
+	})
+
+	t.Run(FORMAT_JSON, func(t *testing.T) {
+		w := testExport(t, FORMAT_JSON, rows, failure)
+		body := w.Body.String()
+		if !strings.HasPrefix(body, `[{"id":"v1"`) || !strings.Contains(body, `"id":"v2"`) || json.Valid(w.Body.Bytes()) {
+			t.Errorf("body = %v", body)
+		}
+	})
+
+	t.Run(FORMAT_NDJSON, func(t *testing.T) {
+		w := testExport(t, FORMAT_NDJSON, rows, failure)
+		if lines := strings.Count(w.Body.String(), "\n"); lines != len(rows) {
+			t.Errorf("lines = %v, want %v", lines, len(rows))
+		}
+	})
+
+	t.Run(FORMAT_XLSX, func(t *testing.T) {
+		body := testExport(t, FORMAT_XLSX, rows, failure).Body.Bytes()
+		if len(body) == 0 {
+			t.Fatal("nothing is written")
+		}
+		if _, err := zip.NewReader(bytes.NewReader(body), int64(len(body))); err == nil {
+			t.Error("interrupted xlsx is a complete zip archive")
+		}
+	})
+}
+
+func TestExportFormat(t *testing.T) {
+	tests := []struct {
+		query  string
+		accept string
+		want   string
+		err    bool
+	}{
+		{"", "", FORMAT_JSON, false},
+		{"format=CSV", "", FORMAT_CSV, false},
+		{"format=xlsx", "text/csv", FORMAT_XLSX, false},
+		{"format=xml", "", "", true},
+		{"", "text/html, application/x-ndjson;q=0.9", FORMAT_NDJSON, false},
+		{"", "text/csv; charset=utf-8", FORMAT_CSV, false},
+		{"", "text/html", FORMAT_JSON, false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/view/videos?"+tt.query, nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		got, err := exportFormat(r)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("exportFormat(%q, %q) = %v, %v, want %v", tt.query, tt.accept, got, err, tt.want)
+		}
+	}
+}
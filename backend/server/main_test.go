@@ -0,0 +1,20 @@
+package server
+
+import (
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// Тести не завантажують налаштування (config.Load): налаштування мають значення за замовчуванням, тести
+// змінюють потрібні їм самі, лог пишеться в stderr
+func TestMain(m *testing.M) {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+	log = logger.Sugar()
+
+	os.Exit(m.Run())
+}
@@ -1,181 +1,622 @@
-package server
-
-import (
-	"time"
-)
-
-// Channel: A channel resource contains information about a YouTube
-type PlayList struct {
-	// Id: The ID that YouTube uses to uniquely identify the playlist.
-	Id string `json:"id"`
-
-	// Title: The playlist's title.
-	Title string `json:"title"`
-
-	// Enable: if playlist is enabled
-	Enable bool `json:"enable"`
-
-	// Id: The ID that YouTube uses to uniquely identify the channel.
-	Idch string `json:"idch"`
-	
-	// The date and time that the item was added to the list PlayLists
-	Timeadd time.Time `json:"timeadd"`
-	
-	Countvideo int `json:"countvideo"`
-}
-
-type ResponcePlayList struct {
-	MaxVideoCount  int `json:"maxvideocount"`
-	PlayLists []PlayList `json:"playlists"`  
-}
-
-// Video: A video resource represents a YouTube video.
-type YoutubeVideo struct {
-	// PlaylistId: The ID that YouTube uses to uniquely identify the
-	// playlist that the playlist item is in.
-	PlaylistId string `json:"idpl"`
-
-	// Title: The item's title.
-	Title string `json:"title"`
-
-	// Description: The item's description.
-	Description string `json:"description"`
-
-
-	// ChannelTitle: Channel title for the channel that the playlist item
-	// belongs to.
-	ChannelTitle string `json:"chtitle"`
-
-	// ChannelId: The ID that YouTube uses to uniquely identify the user
-	// that added the item to the playlist.
-	ChannelId string `json:"chid"`
-
-	// PublishedAt: The date and time that the item was added to the
-	// playlist. The value is specified in ISO 8601 (YYYY-MM-DDThh:mm:ss.sZ)
-	// format.
-	PublishedAt time.Time `json:"publishedat"`
-
-	CountMetrics int `json:"count"`
-
-	MinTimeMetric time.Time `json:"mintime"`
-
-	MaxTimeMetric time.Time `json:"maxtime"`	
-}
-
-
-// Video: A video resource represents a YouTube video.
-type YoutubeVideoShort struct {
-	//  VideoId: The ID that YouTube uses to uniquely identify the video
-	Id string `json:"id"`
-
-	// Title: The item's title.
-	Title string `json:"title"`
-
-	// PublishedAt: The date and time that the item was added to the
-	// playlist. The value is specified in ISO 8601 (YYYY-MM-DDThh:mm:ss.sZ)
-	// format.
-	PublishedAt time.Time `json:"publishedat"`
-	
-	// Title: The playlist's title.
-	Ptitle string `json:"ptitle"`
-}
-
-// Metric: A video resource represents a metric YouTube video.
-type Metrics struct {
-	// CommentCount: The number of comments for the video.
-	CommentCount uint64 `json:"comment"`
-
-	// LikeCount: The number of users who have indicated that they liked the
-	// video by giving it a positive rating.
-	LikeCount uint64 `json:"like"`
-
-	// DislikeCount: The number of users who have indicated that they
-	// disliked the video by giving it a negative rating.
-	DislikeCount uint64 `json:"dislike"`
-
-	// ViewCount: The number of times the video has been viewed.
-	ViewCount uint64 `json:"view"`
-
-	// Last poll time to get metrics
-	Time time.Time `json:"mtime"`
-}
-
-// Структура для кешу списка плейлистів 
-type ListPlayListInCache struct {
-	// Час останнього запиту списку плейлистів
-	timeUpdate time.Time  
-	
-	// Дані по відео 
-	responce []byte
-}
-
-func (l *ListPlayListInCache) update( responce []byte) {
-	l.timeUpdate = time.Now()
-	l.responce = responce
-}
-
-func (l *ListPlayListInCache) reset() {
-	l.timeUpdate = MIN_TIME
-}
-
-// Структура для кешу списку відео без плейлиста
-type YoutubeVideoShortInCache struct {
-	// Час останнього запиту списку відео
-	timeUpdate time.Time  
-	
-	// Дані по відео 
-	responce []byte
-}
-
-
-// Структура для кешу метрик та опису відео
-type VideoInCache struct {
-	// Час останнього запиту метрик. Данні за цей період не змінюються (максимум додасться одна метрика)
-	updateMetrics time.Time  
-
-	// Час останнього запиту статистичних даних по відео (включає дані по метрикам див. YoutubeVideo struct). 
-	// Данні за цей період не змінюються (максимум додасться одна метрика)
-	updateVideo time.Time
-	
-	// Час додавання відео в плейлист. Поза періодом збору метрик, який рахується з даного часу дані вже не міняються
-	// тому беруться тільки з кешу
-	publishedAt time.Time
-	
-	// Дані по відео 
-	videoResponce []byte
-	
-	// Метрики
-	metricsResponce []byte
-}
-
-func (v *VideoInCache) updateCacheVideo(publishedAt time.Time, videoResponce []byte) {
-	v.updateVideo = time.Now()
-	v.publishedAt = publishedAt
-	v.videoResponce = videoResponce
-}
-
-func (v *VideoInCache) updateCacheMetrics(metricsResponce []byte) {
-	v.updateMetrics = time.Now()
-	v.metricsResponce = metricsResponce
-}
-
-// Структура для кешу списку відео без плейлиста
-type GlobalCounts struct {
-	// Час останнього запиту списку відео
-	TimeUpdate time.Time `json:"timeupdate"`
-	
-	// Кількість плейлистів 
-	CountPlaylists int `json:"countpl"`
-	
-	// Кількість відео 
-	CountVideos int `json:"countvideo"`
-	
-	MaxVideoCount  int `json:"maxcountvideo"`
-	
-	PeriodVideoCache time.Duration `json:"periodvideocache"`	
-	
-	Version string `json:"version"`
-	
-	ListenAdmin  bool `json:"listenadmin"`
-}
-
+package server
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Channel: A channel resource contains information about a YouTube
+type PlayList struct {
+	// Id: The ID that YouTube uses to uniquely identify the playlist.
+	Id string `json:"id"`
+
+	// Title: The playlist's title.
+	Title string `json:"title"`
+
+	// Enable: if playlist is enabled
+	Enable bool `json:"enable"`
+
+	// Id: The ID that YouTube uses to uniquely identify the channel.
+	Idch string `json:"idch"`
+	
+	// The date and time that the item was added to the list PlayLists
+	Timeadd time.Time `json:"timeadd"`
+	
+	Countvideo int `json:"countvideo"`
+
+	// Час перенесення в архів (видалення), для активних плейлистів відсутній
+	Archived *time.Time `json:"archived,omitempty"`
+
+	// Індивідуальні налаштування збору метрик. Періоди задаються у форматі "72h", "30m", якщо не задані -
+	// колектор використовує глобальні налаштування
+	PlayListSettings
+}
+
+// Налаштування збору метрик для окремого плейлиста
+type PlayListSettings struct {
+	// Термін збору метрик для відео (periodCollect)
+	PeriodCollect string `json:"periodcollect,omitempty"`
+
+	// Періодичність отримання метрик відео (periodMetric)
+	PeriodMetric string `json:"periodmetric,omitempty"`
+
+	// Періодичність збереження метрик навіть якщо вони не змінились (periodSaveMetricIdle)
+	PeriodSaveMetricIdle string `json:"periodsavemetricidle,omitempty"`
+
+	// Максимальна кількість відео в запиті до плейлиста (maxRequestVideos)
+	MaxRequestVideos int `json:"maxrequestvideos,omitempty"`
+}
+
+type ResponcePlayList struct {
+	MaxVideoCount  int `json:"maxvideocount"`
+	PlayLists []PlayList `json:"playlists"`  
+}
+
+// Video: A video resource represents a YouTube video.
+type YoutubeVideo struct {
+	// PlaylistId: The ID that YouTube uses to uniquely identify the
+	// playlist that the playlist item is in.
+	PlaylistId string `json:"idpl"`
+
+	// Title: The item's title.
+	Title string `json:"title"`
+
+	// Description: The item's description.
+	Description string `json:"description"`
+
+
+	// ChannelTitle: Channel title for the channel that the playlist item
+	// belongs to.
+	ChannelTitle string `json:"chtitle"`
+
+	// ChannelId: The ID that YouTube uses to uniquely identify the user
+	// that added the item to the playlist.
+	ChannelId string `json:"chid"`
+
+	// PublishedAt: The date and time that the item was added to the
+	// playlist. The value is specified in ISO 8601 (YYYY-MM-DDThh:mm:ss.sZ)
+	// format.
+	PublishedAt time.Time `json:"publishedat"`
+
+	CountMetrics int `json:"count"`
+
+	MinTimeMetric time.Time `json:"mintime"`
+
+	MaxTimeMetric time.Time `json:"maxtime"`	
+
+	// Позиція відео відносно типових показників плейлиста, не задана, якщо порівняти немає з чим
+	Baseline *BaselinePosition `json:"baseline,omitempty"`
+}
+
+
+// Video: A video resource represents a YouTube video.
+type YoutubeVideoShort struct {
+	//  VideoId: The ID that YouTube uses to uniquely identify the video
+	Id string `json:"id"`
+
+	// Title: The item's title.
+	Title string `json:"title"`
+
+	// PublishedAt: The date and time that the item was added to the
+	// playlist. The value is specified in ISO 8601 (YYYY-MM-DDThh:mm:ss.sZ)
+	// format.
+	PublishedAt time.Time `json:"publishedat"`
+	
+	// Title: The playlist's title.
+	Ptitle string `json:"ptitle"`
+
+	// Останній вимір метрик, для відео без метрик - нулі та відсутній час виміру
+	ViewCount    uint64     `json:"view"`
+	LikeCount    uint64     `json:"like"`
+	CommentCount uint64     `json:"comment"`
+	TimeMetric   *time.Time `json:"mtime,omitempty"`
+
+	// Приріст переглядів за годину за останню добу вимірів
+	Growth float64 `json:"growth"`
+}
+
+// Запит, результати якого відстежуються: пошук відео за ключовими словами або чарт популярних відео регіону
+type Query struct {
+	Id int64 `json:"id"`
+
+	// Вид запиту: search - пошук за ключовими словами, trending - чарт популярних відео (chart=mostPopular)
+	Kind string `json:"kind"`
+
+	// Ключові слова для пошуку
+	Query string `json:"query"`
+
+	// Код регіону (ISO 3166-1 alpha-2), для чарту обов'язковий
+	RegionCode string `json:"regioncode"`
+
+	Title string `json:"title"`
+
+	Enable bool `json:"enable"`
+
+	// Кількість результатів запиту, якщо не задана - колектор використовує maxQueryResults
+	MaxResults int `json:"maxresults,omitempty"`
+
+	// Періодичність виконання запиту у форматі "1h", якщо не задана - колектор використовує periodQuery
+	PeriodQuery string `json:"periodquery,omitempty"`
+
+	// The date and time that the query was added
+	Timeadd time.Time `json:"timeadd"`
+}
+
+// Позиція відео в результатах запиту
+type QueryRank struct {
+	Rank int `json:"rank"`
+
+	// Час виконання запиту
+	Time time.Time `json:"rtime"`
+}
+
+// Історія позицій відео в результатах запиту
+type QueryVideoRanks struct {
+	//  VideoId: The ID that YouTube uses to uniquely identify the video
+	Id string `json:"id"`
+
+	// Title: The video's title, пустий, якщо відео не додане до збору метрик
+	Title string `json:"title"`
+
+	Ranks []QueryRank `json:"ranks"`
+}
+
+type ResponceQueryRanks struct {
+	Query Query `json:"query"`
+
+	// Відео в порядку першої появи в результатах запиту
+	Videos []*QueryVideoRanks `json:"videos"`
+}
+
+// Коментар верхнього рівня з вибірки коментарів відео
+type Comment struct {
+	// Id гілки коментарів
+	Id string `json:"id"`
+
+	PublishedAt time.Time `json:"publishedat"`
+
+	LikeCount int64 `json:"like"`
+
+	// Кількість відповідей на коментар
+	ReplyCount int64 `json:"reply"`
+
+	Text string `json:"text"`
+
+	// Час останнього оновлення кількості лайків та відповідей
+	TimeUpdate time.Time `json:"timeupdate"`
+}
+
+// Кількість коментарів, опублікованих за інтервал, що починається з Time
+type CommentRate struct {
+	Time time.Time `json:"ctime"`
+
+	Count int `json:"count"`
+}
+
+type ResponceCommentRate struct {
+	// Довжина інтервалу, наприклад "1h0m0s"
+	Interval string `json:"interval"`
+
+	Rates []CommentRate `json:"rates"`
+}
+
+// Metric: A video resource represents a metric YouTube video.
+type Metrics struct {
+	// CommentCount: The number of comments for the video.
+	CommentCount uint64 `json:"comment"`
+
+	// LikeCount: The number of users who have indicated that they liked the
+	// video by giving it a positive rating.
+	LikeCount uint64 `json:"like"`
+
+	// DislikeCount: The number of users who have indicated that they
+	// disliked the video by giving it a negative rating.
+	DislikeCount uint64 `json:"dislike"`
+
+	// ViewCount: The number of times the video has been viewed.
+	ViewCount uint64 `json:"view"`
+
+	// Last poll time to get metrics
+	Time time.Time `json:"mtime"`
+}
+
+// Приріст метрик за інтервал між двома вимірами та швидкість приросту за годину.
+// Лічильники YouTube можуть зменшуватись (видалені лайки, коментарі), тому прирости бувають від'ємні
+type MetricsDelta struct {
+	// Час кінця інтервалу (час вимірювання)
+	Time time.Time `json:"mtime"`
+
+	// Довжина інтервалу в мілісекундах
+	Period int64 `json:"period"`
+
+	View    int64 `json:"view"`
+	Like    int64 `json:"like"`
+	Dislike int64 `json:"dislike"`
+	Comment int64 `json:"comment"`
+
+	// Приріст за годину
+	ViewRate    float64 `json:"viewrate"`
+	LikeRate    float64 `json:"likerate"`
+	DislikeRate float64 `json:"dislikerate"`
+	CommentRate float64 `json:"commentrate"`
+}
+
+// Показники залученості: відношення лайків, дизлайків та коментарів до переглядів
+type Engagement struct {
+	LikeView    float64 `json:"likeview"`
+	DislikeView float64 `json:"dislikeview"`
+	CommentView float64 `json:"commentview"`
+
+	// Частка лайків серед усіх оцінок
+	LikeRatio float64 `json:"likeratio"`
+}
+
+// Похідні метрики відео за період
+type ResponceDerivedMetrics struct {
+	Id string `json:"id"`
+
+	PublishedAt time.Time `json:"publishedat"`
+
+	// Мінімальна довжина інтервалу, наприклад "1h0m0s". Виміри, ближчі за інтервал до попереднього, пропускаються
+	Interval string `json:"interval"`
+
+	// Час першого та останнього вимірювання за період, не задані, якщо метрик немає
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+
+	// Приріст та середня швидкість за весь період
+	Total MetricsDelta `json:"total"`
+
+	// Залученість за лічильниками на кінець періоду
+	Engagement Engagement `json:"engagement"`
+
+	// Залученість за приростами за період
+	RangeEngagement Engagement `json:"rangeengagement"`
+
+	// Найбільша швидкість переглядів за годину, час кінця інтервалу з цією швидкістю та скільки мілісекунд
+	// пройшло до нього з публікації відео
+	PeakViewRate float64    `json:"peakviewrate"`
+	PeakTime     *time.Time `json:"peaktime"`
+	TimeToPeak   int64      `json:"timetopeak"`
+
+	Deltas []MetricsDelta `json:"deltas"`
+}
+
+// Метрики відео на спільній сітці віку (часу з публікації). Значення інтерпольовані між вимірами,
+// null - вік поза періодом, за який є виміри
+type CompareSeries struct {
+	Id string `json:"id"`
+
+	Title string `json:"title"`
+
+	PublishedAt time.Time `json:"publishedat"`
+
+	View    []*float64 `json:"view"`
+	Like    []*float64 `json:"like"`
+	Dislike []*float64 `json:"dislike"`
+	Comment []*float64 `json:"comment"`
+}
+
+// Порівняння відео за віком
+type ResponceCompare struct {
+	// Крок сітки, наприклад "1h0m0s"
+	Step string `json:"step"`
+
+	// Вузли сітки: вік в мілісекундах
+	Ages []int64 `json:"ages"`
+
+	Series []*CompareSeries `json:"series"`
+}
+
+// Типові показники плейлиста: перцентилі переглядів останніх відео плейлиста для кожного віку.
+// Значення null - у вузлі менше MIN_BASELINE_VIDEOS відео з вимірами
+type ResponceBaseline struct {
+	PlaylistId string `json:"idpl"`
+
+	// Крок сітки віку, наприклад "1h0m0s"
+	Step string `json:"step"`
+
+	// Кількість відео, за якими рахуються показники
+	Videos int `json:"videos"`
+
+	// Час розрахунку
+	TimeUpdate time.Time `json:"timeupdate"`
+
+	// Вузли сітки: вік в мілісекундах
+	Ages []int64 `json:"ages"`
+
+	// Кількість відео з вимірами в кожному вузлі
+	Count []int `json:"count"`
+
+	P10    []*float64 `json:"p10"`
+	P25    []*float64 `json:"p25"`
+	Median []*float64 `json:"median"`
+	P75    []*float64 `json:"p75"`
+	P90    []*float64 `json:"p90"`
+}
+
+// Позиція відео відносно типових показників плейлиста в останньому вузлі сітки, за який є виміри відео
+type BaselinePosition struct {
+	// Вік в мілісекундах
+	Age int64 `json:"age"`
+
+	View float64 `json:"view"`
+
+	// Частка інших відео плейлиста з меншою кількістю переглядів в цьому віці, %
+	Percentile float64 `json:"percentile"`
+
+	// Смуга: "<p10", "p10-p25", "p25-p50", "p50-p75", "p75-p90", ">p90"
+	Band string `json:"band"`
+
+	// Кількість відео, з якими порівнюється відео
+	Videos int `json:"videos"`
+}
+
+// Структура для кешу списка плейлистів 
+type ListPlayListInCache struct {
+	// Час останнього запиту списку плейлистів
+	timeUpdate time.Time  
+	
+	// Дані по відео 
+	responce []byte
+}
+
+func (l *ListPlayListInCache) update( responce []byte) {
+	l.timeUpdate = time.Now()
+	l.responce = responce
+}
+
+func (l *ListPlayListInCache) reset() {
+	l.timeUpdate = MIN_TIME
+}
+
+// Сторінка списку відео
+type ResponceVideos struct {
+	Items []*YoutubeVideoShort `json:"items"`
+
+	// Курсор наступної сторінки, відсутній - це остання сторінка
+	NextCursor string `json:"next_cursor,omitempty"`
+
+	// Кількість всіх відео, що відповідають фільтрам
+	Total int64 `json:"total"`
+}
+
+// Структура для кешу списку відео без плейлиста
+type YoutubeVideoShortInCache struct {
+	// Час останнього запиту списку відео
+	timeUpdate time.Time  
+	
+	// Дані по відео 
+	responce []byte
+}
+
+
+// Структура для кешу метрик та опису відео
+type VideoInCache struct {
+	// Час останнього запиту метрик. Данні за цей період не змінюються (максимум додасться одна метрика)
+	updateMetrics time.Time  
+
+	// Час останнього запиту статистичних даних по відео (включає дані по метрикам див. YoutubeVideo struct). 
+	// Данні за цей період не змінюються (максимум додасться одна метрика)
+	updateVideo time.Time
+	
+	// Час додавання відео в плейлист. Поза періодом збору метрик, який рахується з даного часу дані вже не міняються
+	// тому беруться тільки з кешу
+	publishedAt time.Time
+	
+	// Дані по відео 
+	videoResponce []byte
+	
+	// Метрики
+	metricsResponce []byte
+}
+
+func (v *VideoInCache) updateCacheVideo(publishedAt time.Time, videoResponce []byte) {
+	v.updateVideo = time.Now()
+	v.publishedAt = publishedAt
+	v.videoResponce = videoResponce
+}
+
+func (v *VideoInCache) updateCacheMetrics(metricsResponce []byte) {
+	v.updateMetrics = time.Now()
+	v.metricsResponce = metricsResponce
+}
+
+// Структура для кешу списку відео без плейлиста
+type GlobalCounts struct {
+	// Час останнього запиту списку відео
+	TimeUpdate time.Time `json:"timeupdate"`
+	
+	// Кількість плейлистів 
+	CountPlaylists int `json:"countpl"`
+	
+	// Кількість відео 
+	CountVideos int `json:"countvideo"`
+	
+	MaxVideoCount  int `json:"maxcountvideo"`
+	
+	PeriodVideoCache time.Duration `json:"periodvideocache"`	
+	
+	Version string `json:"version"`
+	
+	ListenAdmin  bool `json:"listenadmin"`
+}
+
+// Відповідь на запит перечитування налаштувань
+// Результат очищення архівного плейлиста: кількість видалених рядків або, для dryrun, тих, що будуть видалені
+type ResponcePurge struct {
+	DryRun bool `json:"dryrun"`
+
+	Videos int64 `json:"videos"`
+
+	Metrics int64 `json:"metrics"`
+
+	// Погодинні та щоденні зведення метрик
+	Rollups int64 `json:"rollups"`
+
+	Comments int64 `json:"comments"`
+}
+
+// Відео, знайдене повнотекстовим пошуком
+type SearchResult struct {
+	Id string `json:"id"`
+
+	// Плейлист відео, пусто - відео знайдене тільки запитом
+	PlaylistId string `json:"idpl,omitempty"`
+
+	Title string `json:"title"`
+
+	ChannelTitle string `json:"chtitle"`
+
+	ChannelId string `json:"chid"`
+
+	PublishedAt time.Time `json:"publishedat"`
+
+	// Релевантність, результати впорядковані за її спаданням
+	Rank float64 `json:"rank"`
+
+	// Назва та фрагменти опису, в яких знайдені слова виділені тегами <b></b>. Текст не екранується
+	TitleHighlight string `json:"titlehighlight"`
+
+	DescriptionHighlight string `json:"descriptionhighlight"`
+}
+
+// Рейтинг відео за приростом метрики за вікно
+type ResponceTop struct {
+	// Вікно: 1h, 24h, 7d
+	Period string `json:"period"`
+
+	// Метрика: views, likes, comments
+	Metric string `json:"metric"`
+
+	// Час останнього перерахунку приросту, відсутній - приріст ще не рахувався
+	Updated *time.Time `json:"updated,omitempty"`
+
+	Items []*TopVideo `json:"items"`
+}
+
+type TopVideo struct {
+	Id string `json:"id"`
+
+	PlaylistId string `json:"idpl"`
+
+	Title string `json:"title"`
+
+	// Title: The playlist's title.
+	Ptitle string `json:"ptitle"`
+
+	PublishedAt time.Time `json:"publishedat"`
+
+	// Приріст метрики за вікно та її останнє значення
+	Gain  int64 `json:"gain"`
+	Count int64 `json:"count"`
+
+	// Приріст рахується з TimeFrom (початок вікна, час публікації або перший вимір відео) до TimeLast (останній вимір)
+	TimeFrom time.Time `json:"timefrom"`
+	TimeLast time.Time `json:"timelast"`
+
+	// Час перерахунку приросту відео
+	TimeUpdate time.Time `json:"timeupdate"`
+}
+
+// Подія живого потоку: новий вимір метрик відео
+type StreamMetric struct {
+	Id string `json:"id"`
+
+	// Плейлист відео, пусто - відео знайдене тільки запитом
+	PlaylistId string `json:"idpl,omitempty"`
+
+	CommentCount uint64    `json:"comment"`
+	LikeCount    uint64    `json:"like"`
+	DislikeCount uint64    `json:"dislike"`
+	ViewCount    uint64    `json:"view"`
+	Time         time.Time `json:"mtime"`
+}
+
+// Подія живого потоку: нове відео плейлиста
+type StreamVideo struct {
+	Id string `json:"id"`
+
+	PlaylistId string `json:"idpl"`
+
+	Title string `json:"title"`
+
+	PublishedAt time.Time `json:"publishedat"`
+
+	// Час, коли колектор знайшов відео
+	TimeAdd time.Time `json:"timeadd"`
+}
+
+// Запис журналу аудиту
+type AuditRecord struct {
+	Id int64 `json:"id"`
+
+	Time time.Time `json:"time"`
+
+	// Користувач API, пусто - зміна з командного рядка
+	Actor string `json:"actor,omitempty"`
+
+	// Дія, наприклад "playlist.update"
+	Action string `json:"action"`
+
+	// Id плейлиста, запиту або ім'я користувача
+	Target string `json:"target"`
+
+	// Плейлист, якого стосується зміна
+	PlaylistId string `json:"idpl,omitempty"`
+
+	// Стан об'єкта до та після зміни, відсутній - об'єкта не було або його видалено
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+
+	// Адреса клієнта
+	Addr string `json:"addr,omitempty"`
+}
+
+type ResponceReload struct {
+	// Перелік змінених налаштувань у форматі "name: old -> new"
+	Changed []string `json:"changed"`
+}
+
+// Користувач API бекенда
+type ApiUser struct {
+	Name string `json:"name"`
+
+	// Роль: viewer, editor або admin
+	Role string `json:"role"`
+
+	Enable bool `json:"enable"`
+
+	// Пароль для авторизації Basic, тільки при створенні користувача, в БД зберігається хеш
+	Password string `json:"password,omitempty"`
+
+	// Чи задано пароль (авторизація Basic) та токен (авторизація Bearer)
+	HasPassword bool `json:"haspassword"`
+	HasToken    bool `json:"hastoken"`
+
+	Timeadd time.Time `json:"timeadd"`
+
+	// Хеші пароля та токена з БД, у відповідь не виводяться
+	passwordHash string
+	tokenHash    string
+}
+
+// Відповідь на створення користувача. Токен виводиться тільки один раз, в БД зберігається його хеш
+type ResponceApiUser struct {
+	Name string `json:"name"`
+
+	Role string `json:"role"`
+
+	Token string `json:"token"`
+}
+
+// Відповідь з помилкою, наприклад {"code": "not_found", "message": "video not found", "request_id": "5f1d2c..."}
+type ResponceError struct {
+	// Машинний код помилки, див. ERR_*
+	Code string `json:"code"`
+
+	Message string `json:"message"`
+
+	// id запиту, за ним помилку можна знайти в лозі
+	RequestId string `json:"request_id"`
+}
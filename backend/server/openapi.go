@@ -0,0 +1,80 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+	"github.com/gorilla/mux"
+)
+
+// Специфікація API (OpenAPI 3) вбудовується в програму та віддається за запитом /openapi.json.
+// При зміні маршрутів в newRouter треба оновити openapi.json та клієнт (пакет backend/client):
+// тест TestRoutesMatchOpenApi звіряє маршрути зі специфікацією, при запуску розбіжності тільки пишуться в лог
+
+//go:embed openapi.json
+var openApiJson []byte
+
+// Регулярні вирази в шаблонах маршрутів: /queries/admin/{id:[0-9]+} -> /queries/admin/{id}
+var routeVarRegexp = regexp.MustCompile(`\{([^:}]+):[^}]*\}`)
+
+type openApiDocument struct {
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// Оброблювач запиту специфікації API
+func getOpenApiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(openApiJson)
+}
+
+// Звірити зареєстровані маршрути зі специфікацією. Кожен маршрут повинен бути описаний в специфікації,
+// а при ListenAdmin = true (зареєстровані всі маршрути) кожна операція специфікації повинна мати маршрут
+func checkOpenApi(r *mux.Router) []string {
+	var doc openApiDocument
+	if err := json.Unmarshal(openApiJson, &doc); err != nil {
+		return []string{"cannot parse openapi.json: " + err.Error()}
+	}
+
+	errs := []string{}
+	routes := make(map[string]bool)
+
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		pathTemplate, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		path := routeVarRegexp.ReplaceAllString(pathTemplate, "{$1}")
+		for _, method := range methods {
+			method = strings.ToLower(method)
+			routes[method+" "+path] = true
+			if _, ok := doc.Paths[path][method]; !ok {
+				errs = append(errs, "route is not described in openapi.json: "+strings.ToUpper(method)+" "+path)
+			}
+		}
+		return nil
+	})
+
+	if *config.ListenAdmin {
+		for path, operations := range doc.Paths {
+			for method := range operations {
+				if !routes[method+" "+path] {
+					errs = append(errs, "openapi.json describes missing route: "+strings.ToUpper(method)+" "+path)
+				}
+			}
+		}
+	}
+	sort.Strings(errs)
+
+	return errs
+}
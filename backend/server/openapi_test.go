@@ -0,0 +1,18 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+)
+
+// Всі маршрути (з адмініструванням) описані в openapi.json, і кожна операція специфікації має маршрут
+func TestRoutesMatchOpenApi(t *testing.T) {
+	listenAdmin := *config.ListenAdmin
+	*config.ListenAdmin = true
+	defer func() { *config.ListenAdmin = listenAdmin }()
+
+	for _, err := range checkOpenApi(newRouter()) {
+		t.Error(err)
+	}
+}
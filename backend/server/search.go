@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Повнотекстовий пошук по назві та опису відео. Текст запиту задається у форматі websearch: слова, "фраза",
+// or, -слово. Індекс будується конфігураціями english та ukrainian (див. міграцію 0013_video_search)
+
+// Конфігурації повнотекстового пошуку
+const SEARCH_CONFIG_ENGLISH = "english"
+const SEARCH_CONFIG_UKRAINIAN = "public.ukrainian"
+
+// Максимальна довжина тексту запиту
+const MAX_SEARCH_TEXT = 200
+
+// Кількість результатів: за замовчуванням та максимальна (limit=)
+const DEFAULT_SEARCH_RESULTS = 20
+const MAX_SEARCH_RESULTS = 100
+
+// Конфігурація, якою підсвічуються знайдені слова: ukrainian, якщо в запиті є кирилиця, інакше english
+func searchConfig(text string) string {
+	for _, r := range text {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return SEARCH_CONFIG_UKRAINIAN
+		}
+	}
+	return SEARCH_CONFIG_ENGLISH
+}
+
+// Знайти відео за текстом з фільтрами по плейлисту, каналу та періоду публікації (мілісекунди), пусто - без обмеження
+func searchVideos(text, playlistId, channelId, from, to string, limit, offset int) ([]byte, error) {
+	log.Debugf("searchVideos(text: %v, idpl: %v, chid: %v, from: %v, to: %v, limit: %v, offset: %v)", text,
+		playlistId, channelId, from, to, limit, offset)
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, badRequest("search text is empty")
+	}
+	if len([]rune(text)) > MAX_SEARCH_TEXT {
+		return nil, badRequest("search text is longer than %v characters", MAX_SEARCH_TEXT)
+	}
+
+	response, err := searchVideosFromDB(text, searchConfig(text), playlistId, channelId, from, to, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	searchJson, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("Error convert select to SearchResult: error=%v", err)
+		return nil, err
+	}
+
+	return searchJson, nil
+}
+
+// Оброблювач запиту пошуку відео (q - текст, idpl, chid, from, to, limit - за замовчуванням 20, skip)
+func searchVideosHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := q.Get("req")
+
+	limit := DEFAULT_SEARCH_RESULTS
+	if s := q.Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > MAX_SEARCH_RESULTS {
+			writeError(w, r, badRequest("limit must be between 1 and %v", MAX_SEARCH_RESULTS))
+			return
+		}
+	}
+
+	offset, err := strconv.Atoi(q.Get("skip"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	log.Debugf("req=%v(%v), limit=%v, offset=%v", req, formatStringDate(req), limit, offset)
+
+	searchJson, err := searchVideos(q.Get("q"), q.Get("idpl"), q.Get("chid"), q.Get("from"), q.Get("to"), limit, offset)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(searchJson)
+}
@@ -3,7 +3,6 @@ package server
 import (
 	"encoding/json"
 	"strconv"
-	"errors"
 	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
 	"github.com/hashicorp/golang-lru"
 	"time"
@@ -26,7 +25,8 @@ var listCachePlayLists *ListPlayListInCache
 // Глобальні метрики
 var globalCounts *GlobalCounts
 
-func init() {
+// Створити кеші за налаштуваннями розмірів
+func initCache() {
 	var err error
 
 	if *config.EnableCache {
@@ -46,31 +46,61 @@ func init() {
 		}
 
 		listCachePlayLists = &ListPlayListInCache{MIN_TIME, nil}
+
+		// Метрики в кеші проріджені за налаштуванням metricPoints, яке може змінитись
+		config.OnReload(cacheVideoDescription.Purge)
 	}
 }
 
 // Додати плей-лист
-func addPlayList(playlist *PlayList) error {
+func addPlayList(playlist *PlayList, audit *auditEntry) error {
 	// Список плейлистів в кеші треба буде оновити
 	listCachePlayLists.reset()
 
-	return addPlayListDB(playlist)
+	return addPlayListDB(playlist, audit)
 }
 
 // Оновити плей-лист
-func updatePlayList(id string, playlist *PlayList) error {
+func updatePlayList(id string, playlist *PlayList, audit *auditEntry) error {
 	// Список плейлистів в кеші треба буде оновити
 	listCachePlayLists.reset()
 
-	return updatePlayListDB(id, playlist)
+	return updatePlayListDB(id, playlist, audit)
 }
 
-// Видалити плей-лист
-func deletePlayList(playlistId string) error {
+// Видалити плей-лист: плей-лист переноситься в архів, дані залишаються до очищення (purgePlayList)
+func deletePlayList(playlistId string, audit *auditEntry) error {
 	// Список плейлистів в кеші треба буде оновити
 	listCachePlayLists.reset()
 
-	return deletePlayListDB(playlistId)
+	return archivePlayListDB(playlistId, true, audit)
+}
+
+// Повернути плей-лист з архіву
+func restorePlayList(playlistId string, audit *auditEntry) error {
+	// Список плейлистів в кеші треба буде оновити
+	listCachePlayLists.reset()
+
+	return archivePlayListDB(playlistId, false, audit)
+}
+
+// Очистити архівний плей-лист. Спочатку завжди рахуються рядки, які будуть видалені, dryRun - тільки порахувати
+func purgePlayList(playlistId string, dryRun bool, audit *auditEntry) (*ResponcePurge, error) {
+	response, err := countPlayListDataDB(playlistId)
+	if err != nil || dryRun {
+		return response, err
+	}
+	log.Warnf("purge playlist: id=%v, videos=%v, metrics=%v, rollups=%v, comments=%v", playlistId,
+		response.Videos, response.Metrics, response.Rollups, response.Comments)
+
+	if err = purgePlayListDB(playlistId, config.PurgeBatch.Get(), audit); err != nil {
+		return nil, err
+	}
+
+	listCachePlayLists.reset()
+
+	response.DryRun = false
+	return response, nil
 }
 
 // Отримати опис відео по його id
@@ -78,7 +108,7 @@ func getVideoById(id string) ([]byte, error) {
 	log.Debugf("getVideoById(id: %v)", id)
 
 	if id == "" {
-		return nil, errors.New("video id is null")
+		return nil, badRequest("video id is null")
 	}
 
 	var ok bool = false
@@ -97,8 +127,8 @@ func getVideoById(id string) ([]byte, error) {
 			// Дані з кешу беремо тільки якщо з останнього запиту пройшло часу менш
 			// ніж період збору метрик, або якщо збір метрик вже припинився.
 			if video.videoResponce != nil && 
-				( time.Since(video.publishedAt) > *config.PeriodCollectionCache ||
-				time.Since(video.updateVideo) < *config.PeriodMeterCache) {
+				( time.Since(video.publishedAt) > config.PeriodCollectionCache.Get() ||
+				time.Since(video.updateVideo) < config.PeriodMeterCache.Get()) {
 
 				log.Infof("id: %v, get video from cache", id)
 				log.Debugf("id: %v, cache, video %v", id, string(video.videoResponce))
@@ -115,6 +145,12 @@ func getVideoById(id string) ([]byte, error) {
 		return nil, err
 	}
 
+	// Позиція відносно типових показників плейлиста не обов'язкова, помилка тільки пишеться в лог
+	youtubeVideo.Baseline, err = getBaselinePosition(id, youtubeVideo.PlaylistId)
+	if err != nil {
+		log.Errorf("id: %v, baseline position err=%v", id, err)
+	}
+
 	// Конвертуємо відповідь в json-формат
 	stringJsonVideo, err := json.Marshal(*youtubeVideo)
 	if err != nil {
@@ -141,13 +177,13 @@ func getVideoById(id string) ([]byte, error) {
 	return stringJsonVideo, nil
 }
 
-// Отримати метрики по відео id за заданий період
+// Отримати метрики по відео id за заданий період або з незвичайним проріджуванням
 // Такий запит не використовуэ кеш
-func getMetricsByIdFromTo(id string, from, to string) ([]byte, error) {
-	log.Debugf("getMetricsByIdFromTo(id: %v, from %v, to %v) ", id, from, to)
+func getMetricsByIdFromTo(id string, from, to string, points int, algo string) ([]byte, error) {
+	log.Debugf("getMetricsByIdFromTo(id: %v, from %v, to %v, points: %v, algo: %v) ", id, from, to, points, algo)
 
 	// В кеші актуальної інформации не знайдено, запрошуемо в БД
-	response, err := getMetricsByIdFromDB(id, from, to)
+	response, err := getMetricsByIdFromDB(id, from, to, points, algo)
 	if err != nil {
 		return nil, err
 	}
@@ -163,17 +199,17 @@ func getMetricsByIdFromTo(id string, from, to string) ([]byte, error) {
 	return metricsVideoJson, nil
 }
 
-// Отримати метрики по відео id або за весь період, або за заданий період
-// Якщо період не заданий то використовується кеш, якщо період заданий кеш не використовується
-func getMetricsById(id string, from, to string) ([]byte, error) {
-	log.Debugf("getMetricsById(id: %v, from: %v, to: %v)", id, from, to)
+// Отримати метрики по відео id або за весь період, або за заданий період, проріджені до points точок
+// алгоритмом algo. Кеш використовується тільки якщо період не заданий, а проріджування за замовчуванням
+func getMetricsById(id string, from, to string, points int, algo string) ([]byte, error) {
+	log.Debugf("getMetricsById(id: %v, from: %v, to: %v, points: %v, algo: %v)", id, from, to, points, algo)
 	if id == "" {
-		return nil, errors.New("video id is null")
+		return nil, badRequest("video id is null")
 	}
 
-	// Период заданий, такий запит обробляємо окремо
-	if from != "" || to != "" {
-		return getMetricsByIdFromTo(id, from, to)
+	// Период заданий або інше проріджування, такий запит обробляємо окремо
+	if from != "" || to != "" || points != config.MetricPoints.Get() || algo != DEFAULT_ALGO {
+		return getMetricsByIdFromTo(id, from, to, points, algo)
 	}
 
 	var ok bool = false
@@ -192,8 +228,8 @@ func getMetricsById(id string, from, to string) ([]byte, error) {
 			// Дані з кешу беремо тільки якщо з останнього запиту пройшло часу менш
 			// ніж період збору метрик, або якщо збір метрик вже припинився.
 			if video.metricsResponce != nil && 
-				( time.Since(video.updateMetrics) < *config.PeriodMeterCache ||
-				time.Since(video.publishedAt) > *config.PeriodCollectionCache) {
+				( time.Since(video.updateMetrics) < config.PeriodMeterCache.Get() ||
+				time.Since(video.publishedAt) > config.PeriodCollectionCache.Get()) {
 
 				log.Infof("id: %v, get metrics from cache", id)
 				log.Debugf("id: %v, cache, metrics: %v", id, string(video.metricsResponce))
@@ -206,7 +242,7 @@ func getMetricsById(id string, from, to string) ([]byte, error) {
 	}
 
 	// В кеші актуальної інформации не знайдено, запрошуемо в БД
-	response, err := getMetricsByIdFromDB(id, from, to)
+	response, err := getMetricsByIdFromDB(id, from, to, points, algo)
 	if err != nil {
 		return nil, err
 	}
@@ -238,9 +274,9 @@ func getMetricsById(id string, from, to string) ([]byte, error) {
 }
 
 // Отримати список відео
-func getVideos(offset int) ([]byte, error) {
-	log.Debugf("getVideos(offset: %v)", offset)
-	cacheId := offset
+func getVideos(filter *videoFilter, offset int) ([]byte, error) {
+	log.Debugf("getVideos(filter: %+v, offset: %v)", *filter, offset)
+	cacheId := filter.key() + "_" + strconv.Itoa(offset)
 
 	// з кешем робимо тільки якщо він включений
 	if *config.EnableCache {
@@ -254,7 +290,7 @@ func getVideos(offset int) ([]byte, error) {
 
 			// Дані з кешу беремо тільки якщо з останнього запиту пройшло часу менш
 			// ніж період перевірки списку відео в плейлисті
-			if time.Since(videos.timeUpdate) < *config.PeriodVideoCache {
+			if time.Since(videos.timeUpdate) < config.PeriodVideoCache.Get() {
 				log.Debugf("offset: %v, cache, videos: %v", cacheId, string(videos.responce))
 
 				log.Infof("offset: %v, get videos from cache", cacheId)
@@ -266,7 +302,7 @@ func getVideos(offset int) ([]byte, error) {
 	}
 
 	// В кеші актуальної інформации не знайдено, запрошуемо в БД
-	stringVideos, err := getVideosByPlayListIdFromDB("", offset)
+	stringVideos, err := getVideosByPlayListIdFromDB("", filter, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -284,14 +320,14 @@ func getVideos(offset int) ([]byte, error) {
 
 
 // Отримати список відео по id плейлиста
-func getVideosByPlayListId(id string, offset int) ([]byte, error) {
-	log.Debugf("getVideosByPlayListId(id: %v, offset: %v)", id, offset)
+func getVideosByPlayListId(id string, filter *videoFilter, offset int) ([]byte, error) {
+	log.Debugf("getVideosByPlayListId(id: %v, filter: %+v, offset: %v)", id, *filter, offset)
 
 	if id == "" {
-		return nil, errors.New("video id is null")
+		return nil, badRequest("video id is null")
 	}
 
-	cacheId := id + "_" + strconv.Itoa(offset)
+	cacheId := id + "_" + filter.key() + "_" + strconv.Itoa(offset)
 
 	// з кешем робимо тільки якщо він включений
 	if *config.EnableCache {
@@ -305,7 +341,7 @@ func getVideosByPlayListId(id string, offset int) ([]byte, error) {
 
 			// Дані з кешу беремо тільки якщо з останнього запиту пройшло часу менш
 			// ніж період перевірки списку відео в плейлисті
-			if time.Since(playlist.timeUpdate) < *config.PeriodVideoCache {
+			if time.Since(playlist.timeUpdate) < config.PeriodVideoCache.Get() {
 				log.Infof("id: %v, get videos for playlist from cache", cacheId)
 				log.Debugf("id: %v, cache, playlist: %v", cacheId, string(playlist.responce))
 
@@ -317,7 +353,7 @@ func getVideosByPlayListId(id string, offset int) ([]byte, error) {
 	}
 
 	// В кеші актуальної інформации не знайдено, запрошуемо в БД
-	stringVideos, err := getVideosByPlayListIdFromDB(id, offset)
+	stringVideos, err := getVideosByPlayListIdFromDB(id, filter, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -342,7 +378,7 @@ func getPlaylists(onlyEnable bool) ([]byte, error) {
 		
 		// Дані з кешу беремо тільки якщо з останнього запиту пройшло часу менш
 		// ніж період перевірки списку плейлистів
-		if time.Since(listCachePlayLists.timeUpdate) < *config.PeriodPlayListCache {
+		if time.Since(listCachePlayLists.timeUpdate) < config.PeriodPlayListCache.Get() {
 			log.Debugf("playlists, cache, list playlists: %v", string(listCachePlayLists.responce))
 			log.Info("get playlist from cache")
 
@@ -357,7 +393,7 @@ func getPlaylists(onlyEnable bool) ([]byte, error) {
 		return nil, err
 	}
 
-	responcePlayList := ResponcePlayList{*config.MaxViewVideosInPlayLists, response}
+	responcePlayList := ResponcePlayList{config.MaxViewVideosInPlayLists.Get(), response}
 	
 	// Конвертуємо відповідь в json-формат
 	stringJsonPlaylists, err := json.Marshal(&responcePlayList)
@@ -382,7 +418,7 @@ func getPlaylists(onlyEnable bool) ([]byte, error) {
 
 func getGlobalCounts(version string) ([]byte, error) {
 	log.Debug("get globalCounts")
-	if globalCounts == nil || time.Since(globalCounts.TimeUpdate) > *config.PeriodVideoCache {
+	if globalCounts == nil || time.Since(globalCounts.TimeUpdate) > config.PeriodVideoCache.Get() {
 		g, err := getGlobalCountsFromDB(version)
 		if err == nil {
 			globalCounts = g;
@@ -401,3 +437,167 @@ func getGlobalCounts(version string) ([]byte, error) {
 	
 	return stringGlobalCounts, nil
 }
+
+// Додати запит
+func addQuery(query *Query, audit *auditEntry) error {
+	return addQueryDB(query, audit)
+}
+
+// Оновити запит
+func updateQuery(id int64, query *Query, audit *auditEntry) error {
+	return updateQueryDB(id, query, audit)
+}
+
+// Видалити запит
+func deleteQuery(id int64, audit *auditEntry) error {
+	return deleteQueryDB(id, audit)
+}
+
+// Отримати список запитів
+func getQueries(onlyEnable bool) ([]byte, error) {
+	log.Debugf("getQueries(onlyEnable: %v)", onlyEnable)
+
+	response, err := getQueriesFromDB(onlyEnable)
+	if err != nil {
+		return nil, err
+	}
+
+	// Конвертуємо відповідь в json-формат
+	stringJsonQueries, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("Error convert select to Queries: response=%v, error=%v", response, err)
+		return nil, err
+	}
+	log.Debugf("queries: %v", string(stringJsonQueries))
+
+	return stringJsonQueries, nil
+}
+
+// Отримати історію позицій відео в результатах запиту за заданий період
+// Позиції додаються кожне виконання запиту, тому такий запит не використовує кеш
+func getQueryRanks(id int64, from, to string) ([]byte, error) {
+	log.Debugf("getQueryRanks(id: %v, from: %v, to: %v)", id, from, to)
+
+	response, err := getQueryRanksFromDB(id, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Конвертуємо відповідь в json-формат
+	stringJsonRanks, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("Error convert select to QueryRanks: response=%v, error=%v", response, err)
+		return nil, err
+	}
+	log.Debugf("id: %v, ranks=%v", id, string(stringJsonRanks))
+
+	return stringJsonRanks, nil
+}
+
+// Отримати похідні метрики відео за період: прирости, швидкості за годину, залученість та пік переглядів.
+// Розраховуються за всіма збереженими вимірами, тому кеш метрик не використовується
+func getDerivedMetrics(id string, interval time.Duration, from, to string) ([]byte, error) {
+	log.Debugf("getDerivedMetrics(id: %v, interval: %v, from: %v, to: %v)", id, interval, from, to)
+	if id == "" {
+		return nil, badRequest("video id is null")
+	}
+
+	publishedAt, err := getVideoPublishedAtFromDB(id)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := []*Metrics{}
+	err = streamMetricsFromDB(id, from, to, RESOLUTION_RAW, func(m *Metrics) error {
+		metrics = append(metrics, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := deriveMetrics(id, publishedAt, interval, metrics)
+
+	// Конвертуємо відповідь в json-формат
+	stringJsonDerived, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("Error convert select to DerivedMetrics: response=%v, error=%v", response, err)
+		return nil, err
+	}
+	log.Debugf("id: %v, derived metrics=%v", id, string(stringJsonDerived))
+
+	return stringJsonDerived, nil
+}
+
+// Порівняти метрики відео за віком (часом з публікації) на спільній сітці з кроком step до віку age.
+// Відео задаються списком ids або плейлистом playlistId, тоді беруться count останніх відео плейлиста
+func getCompare(ids []string, playlistId string, count int, age, step time.Duration) ([]byte, error) {
+	log.Debugf("getCompare(ids: %v, playlistId: %v, count: %v, age: %v, step: %v)", ids, playlistId, count, age, step)
+
+	videos, err := getCompareVideosFromDB(ids, playlistId, count)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := compareVideos(videos, age, step)
+	if err != nil {
+		return nil, err
+	}
+
+	// Конвертуємо відповідь в json-формат
+	stringJsonCompare, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("Error convert select to Compare: response=%v, error=%v", response, err)
+		return nil, err
+	}
+	log.Debugf("compare=%v", string(stringJsonCompare))
+
+	return stringJsonCompare, nil
+}
+
+// Отримати кількість коментарів відео за інтервали заданої довжини. Коментарі збираються вибірково,
+// тому дані показують динаміку появи коментарів, а не їх точну кількість
+func getCommentRate(id string, interval time.Duration, from, to string) ([]byte, error) {
+	log.Debugf("getCommentRate(id: %v, interval: %v, from: %v, to: %v)", id, interval, from, to)
+	if id == "" {
+		return nil, badRequest("video id is null")
+	}
+
+	response, err := getCommentRateFromDB(id, interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Конвертуємо відповідь в json-формат
+	stringJsonRate, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("Error convert select to CommentRate: response=%v, error=%v", response, err)
+		return nil, err
+	}
+	log.Debugf("id: %v, comment rate=%v", id, string(stringJsonRate))
+
+	return stringJsonRate, nil
+}
+
+// Отримати найпопулярніші за кількістю лайків коментарі відео
+func getTopComments(id string, limit int) ([]byte, error) {
+	log.Debugf("getTopComments(id: %v, limit: %v)", id, limit)
+	if id == "" {
+		return nil, badRequest("video id is null")
+	}
+
+	response, err := getTopCommentsFromDB(id, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Конвертуємо відповідь в json-формат
+	stringJsonComments, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("Error convert select to Comments: response=%v, error=%v", response, err)
+		return nil, err
+	}
+	log.Debugf("id: %v, top comments=%v", id, string(stringJsonComments))
+
+	return stringJsonComments, nil
+}
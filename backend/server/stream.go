@@ -0,0 +1,529 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+	"github.com/lib/pq"
+)
+
+// Живий потік подій (Server-Sent Events): нові виміри метрик відео та нові відео плейлистів, як тільки колектор
+// збереже їх в БД. Тригери БД надсилають сповіщення (NOTIFY, див. міграцію 0015_stream_notify), бекенд слухає їх
+// одним з'єднанням і розсилає підписникам. Id події - її час в мілісекундах: після розриву клієнт передає його
+// в since або заголовку Last-Event-ID і отримує пропущені події з таблиць. Події з часом, рівним since,
+// повторюються, дублікати клієнт відкидає за id відео та часом події. В межах одного з'єднання повтори
+// відкидає сервер (див. streamReplay)
+
+// Канали сповіщень БД
+const STREAM_CHANNEL_METRIC = "new_metric"
+const STREAM_CHANNEL_VIDEO = "new_video"
+
+// Типи подій потоку
+const STREAM_EVENT_METRIC = "metric"
+const STREAM_EVENT_VIDEO = "video"
+
+const CONTENT_TYPE_EVENT_STREAM = "text/event-stream"
+
+// Кількість подій, які чекають відправки клієнту. Клієнт, який не встигає їх читати, відключається
+const STREAM_BUFFER = 256
+
+// Максимальна кількість подій кожного типу, які повторюються при відновленні потоку
+const MAX_STREAM_REPLAY = 10000
+
+// Інтервали перепідключення слухача сповіщень до БД
+const STREAM_MIN_RECONNECT = time.Second * 10
+const STREAM_MAX_RECONNECT = time.Minute
+
+// Якщо сповіщень довго немає, з'єднання слухача перевіряється
+const STREAM_PING = time.Second * 90
+
+// Подія потоку, id - час події в мілісекундах
+type streamEvent struct {
+	name       string
+	id         int64
+	videoId    string
+	playlistId string
+	data       []byte
+}
+
+// Підписка одного з'єднання. Канал events закривається, коли підписку скасовано
+type streamSubscriber struct {
+	videos    map[string]bool
+	playlists map[string]bool
+	events    chan *streamEvent
+}
+
+var streamSubscribers = make(map[*streamSubscriber]bool)
+var streamMux sync.Mutex
+
+// Чи потрібна подія підписнику: подія відео з підписки або відео плейлиста з підписки
+func (s *streamSubscriber) match(e *streamEvent) bool {
+	return s.videos[e.videoId] || (e.playlistId != "" && s.playlists[e.playlistId])
+}
+
+func newMetricEvent(metric *StreamMetric) (*streamEvent, error) {
+	data, err := json.Marshal(metric)
+	if err != nil {
+		return nil, err
+	}
+	return &streamEvent{STREAM_EVENT_METRIC, metric.Time.UnixMilli(), metric.Id, metric.PlaylistId, data}, nil
+}
+
+func newVideoEvent(video *StreamVideo) (*streamEvent, error) {
+	data, err := json.Marshal(video)
+	if err != nil {
+		return nil, err
+	}
+	return &streamEvent{STREAM_EVENT_VIDEO, video.TimeAdd.UnixMilli(), video.Id, video.PlaylistId, data}, nil
+}
+
+// Події з сповіщення БД. Сповіщення про виміри містить масив вимірів одної інструкції INSERT чи COPY, про відео -
+// одне відео. Сповіщення розбирається в модель, тому події з сповіщень та з таблиць однакові
+func newNotificationEvents(n *pq.Notification) ([]*streamEvent, error) {
+	switch n.Channel {
+	case STREAM_CHANNEL_METRIC:
+		metrics := []*StreamMetric{}
+		if err := json.Unmarshal([]byte(n.Extra), &metrics); err != nil {
+			return nil, err
+		}
+		events := make([]*streamEvent, 0, len(metrics))
+		for _, metric := range metrics {
+			event, err := newMetricEvent(metric)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		}
+		return events, nil
+	case STREAM_CHANNEL_VIDEO:
+		video := &StreamVideo{}
+		if err := json.Unmarshal([]byte(n.Extra), video); err != nil {
+			return nil, err
+		}
+		event, err := newVideoEvent(video)
+		if err != nil {
+			return nil, err
+		}
+		return []*streamEvent{event}, nil
+	}
+	return nil, fmt.Errorf("unknown channel %v", n.Channel)
+}
+
+// Запустити слухача сповіщень БД. Сповіщення, надіслані під час розриву з'єднання слухача, втрачаються, тому
+// після перепідключення всі підписки скасовуються: клієнти перепідключаються з Last-Event-ID і отримують
+// пропущені події з таблиць
+func startStream() {
+	listener := pq.NewListener(connStrForDatabse, STREAM_MIN_RECONNECT, STREAM_MAX_RECONNECT,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Errorf("stream listener, event: %v, err=%v", event, err)
+			}
+		})
+
+	for _, channel := range []string{STREAM_CHANNEL_METRIC, STREAM_CHANNEL_VIDEO} {
+		// Якщо з'єднання ще немає, канал буде прослуховуватись після підключення
+		if err := listener.Listen(channel); err != nil {
+			log.Errorf("stream listener, channel: %v, err=%v", channel, err)
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case n := <-listener.Notify:
+				if n == nil {
+					log.Warn("stream listener reconnected, all stream subscriptions are closed")
+					closeStreamSubscribers()
+					continue
+				}
+				publishStreamEvents(n)
+			case <-time.After(STREAM_PING):
+				go listener.Ping()
+			}
+		}
+	}()
+}
+
+// Розіслати події сповіщення БД підписникам в порядку сповіщення
+func publishStreamEvents(n *pq.Notification) {
+	events, err := newNotificationEvents(n)
+	if err != nil {
+		log.Errorf("channel: %v, payload: %v, err=%v", n.Channel, n.Extra, err)
+		return
+	}
+
+	streamMux.Lock()
+	defer streamMux.Unlock()
+
+	for _, event := range events {
+		for s := range streamSubscribers {
+			if !s.match(event) {
+				continue
+			}
+			select {
+			case s.events <- event:
+			default:
+				log.Warnf("stream subscriber does not read events, subscription is closed")
+				delete(streamSubscribers, s)
+				close(s.events)
+			}
+		}
+	}
+}
+
+// Помилка, якщо кількість з'єднань досягла максимуму. Викликається під streamMux
+func streamConnectionsError() error {
+	if len(streamSubscribers) >= config.MaxStreamConnections.Get() {
+		return unavailable("too many stream connections")
+	}
+	return nil
+}
+
+// Чи можна відкрити ще одне з'єднання
+func checkStreamConnections() error {
+	streamMux.Lock()
+	defer streamMux.Unlock()
+
+	return streamConnectionsError()
+}
+
+// Підписатися на події відео videos та відео плейлистів playlists
+func subscribeStream(videos, playlists []string) (*streamSubscriber, error) {
+	streamMux.Lock()
+	defer streamMux.Unlock()
+
+	if err := streamConnectionsError(); err != nil {
+		return nil, err
+	}
+
+	s := &streamSubscriber{make(map[string]bool), make(map[string]bool), make(chan *streamEvent, STREAM_BUFFER)}
+	for _, id := range videos {
+		s.videos[id] = true
+	}
+	for _, id := range playlists {
+		s.playlists[id] = true
+	}
+	streamSubscribers[s] = true
+
+	log.Debugf("stream subscribed, videos: %v, playlists: %v, connections: %v", videos, playlists,
+		len(streamSubscribers))
+	return s, nil
+}
+
+// Скасувати підписку, якщо вона ще не скасована
+func unsubscribeStream(s *streamSubscriber) {
+	streamMux.Lock()
+	defer streamMux.Unlock()
+
+	if streamSubscribers[s] {
+		delete(streamSubscribers, s)
+		close(s.events)
+	}
+}
+
+// Скасувати всі підписки, з'єднання потоків завершуються
+func closeStreamSubscribers() {
+	streamMux.Lock()
+	defer streamMux.Unlock()
+
+	for s := range streamSubscribers {
+		delete(streamSubscribers, s)
+		close(s.events)
+	}
+}
+
+// Події з часу from з таблиць для відновлення потоку, впорядковані за часом. truncated - прочитано не все,
+// наступні події треба читати з часу останньої події
+func getStreamEvents(videos, playlists []string, from time.Time) (events []*streamEvent, truncated bool, err error) {
+	metrics, err := getStreamMetricsFromDB(videos, playlists, from, MAX_STREAM_REPLAY)
+	if err != nil {
+		return nil, false, err
+	}
+	newVideos, err := getStreamVideosFromDB(playlists, from, MAX_STREAM_REPLAY)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return limitStreamEvents(metrics, newVideos, MAX_STREAM_REPLAY)
+}
+
+// Події вимірів та нових відео, прочитаних з обмеженням limit кожного типу. Якщо події одного типу обрізані,
+// події іншого типу після останньої прочитаної події обрізаного типу відкидаються, щоб не пропустити
+// непрочитані події між ними
+func limitStreamEvents(metrics []*StreamMetric, videos []*StreamVideo, limit int) ([]*streamEvent, bool, error) {
+	events, err := mergeStreamEvents(metrics, videos)
+	if err != nil {
+		return nil, false, err
+	}
+
+	truncated := false
+	last := int64(math.MaxInt64)
+	if len(metrics) >= limit {
+		truncated, last = true, metrics[len(metrics)-1].Time.UnixMilli()
+	}
+	if len(videos) >= limit {
+		truncated = true
+		if t := videos[len(videos)-1].TimeAdd.UnixMilli(); t < last {
+			last = t
+		}
+	}
+	if truncated {
+		n := sort.Search(len(events), func(i int) bool { return events[i].id > last })
+		events = events[:n]
+	}
+
+	return events, truncated, nil
+}
+
+// Події вимірів та нових відео, впорядковані за часом. При однаковому часі нове відео йде перед вимірами
+func mergeStreamEvents(metrics []*StreamMetric, videos []*StreamVideo) ([]*streamEvent, error) {
+	events := make([]*streamEvent, 0, len(metrics)+len(videos))
+	for _, video := range videos {
+		event, err := newVideoEvent(video)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	for _, metric := range metrics {
+		event, err := newMetricEvent(metric)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].id < events[j].id })
+
+	return events, nil
+}
+
+// Записати в потік текст та відправити його клієнту. Потік не обмежений WriteTimeout сервера, тому термін
+// запису встановлюється для кожного повідомлення
+func writeStream(w http.ResponseWriter, rc *http.ResponseController, text string) error {
+	if err := rc.SetWriteDeadline(time.Now().Add(*config.Timeout)); err != nil {
+		log.Debugf("cannot set write deadline: %v", err)
+	}
+	if _, err := fmt.Fprint(w, text); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+func writeStreamEvent(w http.ResponseWriter, rc *http.ResponseController, e *streamEvent) error {
+	return writeStream(w, rc, fmt.Sprintf("id: %v\nevent: %v\ndata: %s\n\n", e.id, e.name, e.data))
+}
+
+// Відновлення потоку: пропущені події читаються з таблиць частинами, кожна з часу останньої відправленої
+// події. Події з цим часом читаються повторно, тому ключі відправлених подій запам'ятовуються і повтори
+// (в тому числі живі події, вже прочитані з таблиць) не відправляються
+type streamReplay struct {
+	// Події з часу from, див. getStreamEvents
+	source func(from time.Time) ([]*streamEvent, bool, error)
+
+	// id останньої відправленої події
+	last int64
+
+	// Ключі відправлених подій останнього читання та їх id
+	sent map[string]int64
+}
+
+func newStreamReplay(videos, playlists []string, from time.Time) *streamReplay {
+	return &streamReplay{
+		source: func(from time.Time) ([]*streamEvent, bool, error) {
+			return getStreamEvents(videos, playlists, from)
+		},
+		last: from.UnixMilli(),
+		sent: make(map[string]int64),
+	}
+}
+
+// Ключ події: тип, відео та час
+func (e *streamEvent) key() string {
+	return e.name + ":" + e.videoId + ":" + strconv.FormatInt(e.id, 10)
+}
+
+// Прочитати події з часу останньої відправленої події. more - подій багато або прочитано не все, читання треба
+// повторити після відправки прочитаних
+func (p *streamReplay) read() (events []*streamEvent, more bool, err error) {
+	// повторно можуть бути прочитані тільки події з часом last
+	for key, id := range p.sent {
+		if id < p.last {
+			delete(p.sent, key)
+		}
+	}
+
+	events, truncated, err := p.source(time.UnixMilli(p.last))
+	if err != nil || len(events) == 0 {
+		return events, false, err
+	}
+
+	more = truncated || len(events) >= STREAM_BUFFER
+	if more && events[len(events)-1].id == p.last {
+		// всі прочитані події мають час last, наступне читання поверне ті самі події
+		log.Warnf("stream replay from %v is truncated to %v events", p.last, len(events))
+		more = false
+	}
+	return events, more, nil
+}
+
+// Відправити події, які ще не відправлені
+func (p *streamReplay) write(w http.ResponseWriter, rc *http.ResponseController, events []*streamEvent) error {
+	for _, e := range events {
+		if p.isSent(e) {
+			continue
+		}
+		if err := writeStreamEvent(w, rc, e); err != nil {
+			return err
+		}
+		p.sent[e.key()] = e.id
+		if e.id > p.last {
+			p.last = e.id
+		}
+	}
+	return nil
+}
+
+// Чи відправлена подія при відновленні потоку
+func (p *streamReplay) isSent(e *streamEvent) bool {
+	_, ok := p.sent[e.key()]
+	return ok
+}
+
+// Список id через кому, пусті елементи пропускаються
+func splitIds(s string) []string {
+	ids := []string{}
+	for _, id := range strings.Split(s, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Оброблювач живого потоку подій (videos, playlists - id через кому, since - час в мілісекундах, з якого
+// повторити пропущені події; заголовок Last-Event-ID має пріоритет над since)
+func getStreamHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	videos, playlists := splitIds(q.Get("videos")), splitIds(q.Get("playlists"))
+	if len(videos)+len(playlists) == 0 {
+		writeError(w, r, badRequest("videos or playlists are required"))
+		return
+	}
+	if len(videos)+len(playlists) > config.MaxStreamSubscriptions.Get() {
+		writeError(w, r, badRequest("at most %v videos and playlists in one stream", config.MaxStreamSubscriptions.Get()))
+		return
+	}
+
+	since := r.Header.Get("Last-Event-ID")
+	if since == "" {
+		since = q.Get("since")
+	}
+	var from time.Time
+	if since != "" {
+		ms, err := strconv.ParseInt(since, 10, 64)
+		if err != nil || ms < 0 {
+			writeError(w, r, badRequest("since must be milliseconds since epoch"))
+			return
+		}
+		from = time.UnixMilli(ms)
+		if time.Since(from) > config.StreamResume.Get() {
+			writeError(w, r, badRequest("since must not be older than %v", config.StreamResume.Get()))
+			return
+		}
+	}
+	log.Debugf("videos=%v, playlists=%v, since=%v", videos, playlists, since)
+
+	// Пропущені події відправляються до підписки, щоб живі події не накопичувались в буфері підписника, поки
+	// клієнт читає повтор. Тому кількість з'єднань перевіряється заздалегідь
+	if err := checkStreamConnections(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	replay := newStreamReplay(videos, playlists, from)
+	var events []*streamEvent
+	var err error
+	more := false
+	if !from.IsZero() {
+		if events, more, err = replay.read(); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_EVENT_STREAM)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if err = writeStream(w, rc, ": connected\n\n"); err != nil {
+		return
+	}
+	for {
+		if err = replay.write(w, rc, events); err != nil {
+			return
+		}
+		if !more {
+			break
+		}
+		if events, more, err = replay.read(); err != nil {
+			log.Errorf("stream replay, err=%v", err)
+			return
+		}
+	}
+
+	// Якщо з'єднань вже забагато, клієнт перепідключиться з Last-Event-ID
+	subscriber, err := subscribeStream(videos, playlists)
+	if err != nil {
+		log.Debugf("stream is closed: %v", err)
+		return
+	}
+	defer unsubscribeStream(subscriber)
+
+	// Події, збережені між останнім читанням та підпискою. Живі події, що надійдуть під час читання, чекають
+	// в буфері. Якщо подій знов багато, клієнт не встигає за ними: з'єднання закривається, клієнт перепідключиться
+	if !from.IsZero() {
+		if events, more, err = replay.read(); err != nil {
+			log.Errorf("stream replay, err=%v", err)
+			return
+		}
+		if err = replay.write(w, rc, events); err != nil || more {
+			log.Debugf("stream is closed after replay, err=%v, more events: %v", err, more)
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(config.StreamHeartbeat.Get())
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscriber.events:
+			// Підписку скасовано: клієнт не встигав читати події, слухач перепідключився або сервер зупиняється
+			if !ok {
+				return
+			}
+			if replay.isSent(event) {
+				continue
+			}
+			err = writeStreamEvent(w, rc, event)
+		case <-heartbeat.C:
+			err = writeStream(w, rc, ": heartbeat\n\n")
+		}
+		if err != nil {
+			log.Debugf("stream is closed: %v", err)
+			return
+		}
+	}
+}
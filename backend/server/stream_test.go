@@ -0,0 +1,402 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+	"github.com/lib/pq"
+)
+
+// Сповіщення БД з json-описом v, як його надсилають тригери міграції 0015_stream_notify
+func testNotification(t *testing.T, channel string, v any) *pq.Notification {
+	t.Helper()
+	payload, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pq.Notification{Channel: channel, Extra: string(payload)}
+}
+
+func testStreamMetric(id, playlistId string, view uint64, offset time.Duration) *StreamMetric {
+	return &StreamMetric{Id: id, PlaylistId: playlistId, ViewCount: view, Time: testStart.Add(offset)}
+}
+
+// Підписка, яка скасовується після тесту
+func testSubscribe(t *testing.T, videos, playlists []string) *streamSubscriber {
+	t.Helper()
+	s, err := subscribeStream(videos, playlists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { unsubscribeStream(s) })
+	return s
+}
+
+// Прочитати з підписки події, які вже в буфері, та перевірити, що підписка не скасована
+func receiveStream(t *testing.T, s *streamSubscriber) []*streamEvent {
+	t.Helper()
+	events := []*streamEvent{}
+	for {
+		select {
+		case e, ok := <-s.events:
+			if !ok {
+				t.Fatal("subscription is closed")
+			}
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func eventVideos(events []*streamEvent) []string {
+	ids := make([]string, len(events))
+	for i, e := range events {
+		ids[i] = e.name + ":" + e.videoId
+	}
+	return ids
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStreamSubscriberMatch(t *testing.T) {
+	s := &streamSubscriber{videos: map[string]bool{"v1": true}, playlists: map[string]bool{"pl1": true}}
+
+	tests := []struct {
+		name  string
+		event *streamEvent
+		want  bool
+	}{
+		{"subscribed video", &streamEvent{videoId: "v1"}, true},
+		{"subscribed video of other playlist", &streamEvent{videoId: "v1", playlistId: "pl2"}, true},
+		{"video of subscribed playlist", &streamEvent{videoId: "v2", playlistId: "pl1"}, true},
+		{"video of other playlist", &streamEvent{videoId: "v2", playlistId: "pl2"}, false},
+		{"video without playlist", &streamEvent{videoId: "v2"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.match(tt.event); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamPublishOrder(t *testing.T) {
+	s := testSubscribe(t, []string{"v1"}, []string{"pl1"})
+	other := testSubscribe(t, []string{"v3"}, nil)
+
+	publishStreamEvents(testNotification(t, STREAM_CHANNEL_METRIC, []*StreamMetric{
+		testStreamMetric("v1", "", 10, 0),
+		testStreamMetric("v2", "pl1", 20, time.Second),
+		testStreamMetric("v3", "pl2", 30, time.Second*2),
+		testStreamMetric("v1", "", 11, time.Second*3),
+	}))
+	publishStreamEvents(testNotification(t, STREAM_CHANNEL_VIDEO,
+		&StreamVideo{Id: "v4", PlaylistId: "pl1", TimeAdd: testStart.Add(time.Second * 4)}))
+	publishStreamEvents(testNotification(t, STREAM_CHANNEL_METRIC, []*StreamMetric{
+		testStreamMetric("v4", "pl1", 40, time.Second*5),
+	}))
+
+	want := []string{"metric:v1", "metric:v2", "metric:v1", "video:v4", "metric:v4"}
+	events := receiveStream(t, s)
+	if got := eventVideos(events); !equalStrings(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].id <= events[i-1].id {
+			t.Errorf("event %v id %v is not after %v", i, events[i].id, events[i-1].id)
+		}
+	}
+
+	metric := &StreamMetric{}
+	if err := json.Unmarshal(events[2].data, metric); err != nil {
+		t.Fatal(err)
+	}
+	if metric.ViewCount != 11 || !metric.Time.Equal(testStart.Add(time.Second*3)) {
+		t.Errorf("event data = %s", events[2].data)
+	}
+
+	if got := eventVideos(receiveStream(t, other)); !equalStrings(got, []string{"metric:v3"}) {
+		t.Errorf("other subscriber events = %v", got)
+	}
+}
+
+func TestStreamInvalidNotification(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *pq.Notification
+	}{
+		{"unknown channel", &pq.Notification{Channel: "other", Extra: "[]"}},
+		{"metric is not an array", &pq.Notification{Channel: STREAM_CHANNEL_METRIC, Extra: `{"id": "v1"}`}},
+		{"invalid video", &pq.Notification{Channel: STREAM_CHANNEL_VIDEO, Extra: "{"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newNotificationEvents(tt.n); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
+
+func TestStreamSlowSubscriber(t *testing.T) {
+	slow := testSubscribe(t, []string{"v1"}, nil)
+	fast := testSubscribe(t, []string{"v2"}, nil)
+
+	metrics := make([]*StreamMetric, STREAM_BUFFER+1)
+	for i := range metrics {
+		metrics[i] = testStreamMetric("v1", "", uint64(i), time.Second*time.Duration(i))
+	}
+	publishStreamEvents(testNotification(t, STREAM_CHANNEL_METRIC, metrics))
+	publishStreamEvents(testNotification(t, STREAM_CHANNEL_METRIC, []*StreamMetric{
+		testStreamMetric("v2", "", 1, 0),
+	}))
+
+	// буфер повільного підписника віддається до кінця, після чого канал закритий
+	received := 0
+	for range slow.events {
+		received++
+	}
+	if received != STREAM_BUFFER {
+		t.Errorf("slow subscriber received %v events, want %v", received, STREAM_BUFFER)
+	}
+
+	streamMux.Lock()
+	subscribed := streamSubscribers[slow]
+	streamMux.Unlock()
+	if subscribed {
+		t.Error("slow subscriber is not removed")
+	}
+
+	// скасування вже скасованої підписки не закриває канал вдруге
+	unsubscribeStream(slow)
+
+	if got := eventVideos(receiveStream(t, fast)); !equalStrings(got, []string{"metric:v2"}) {
+		t.Errorf("fast subscriber events = %v", got)
+	}
+}
+
+func TestStreamConnections(t *testing.T) {
+	old := config.MaxStreamConnections.String()
+	config.MaxStreamConnections.Set("2")
+	defer config.MaxStreamConnections.Set(old)
+
+	first := testSubscribe(t, []string{"v1"}, nil)
+	testSubscribe(t, []string{"v2"}, nil)
+
+	if _, err := subscribeStream([]string{"v3"}, nil); err == nil {
+		t.Fatal("subscription over maxStreamConnections is accepted")
+	}
+
+	unsubscribeStream(first)
+	testSubscribe(t, []string{"v3"}, nil)
+}
+
+func TestCloseStreamSubscribers(t *testing.T) {
+	subscribers := []*streamSubscriber{
+		testSubscribe(t, []string{"v1"}, nil),
+		testSubscribe(t, nil, []string{"pl1"}),
+	}
+
+	closeStreamSubscribers()
+
+	for i, s := range subscribers {
+		if _, ok := <-s.events; ok {
+			t.Errorf("subscription %v is not closed", i)
+		}
+	}
+	streamMux.Lock()
+	defer streamMux.Unlock()
+	if len(streamSubscribers) != 0 {
+		t.Errorf("%v subscriptions left", len(streamSubscribers))
+	}
+}
+
+func TestMergeStreamEvents(t *testing.T) {
+	metrics := []*StreamMetric{
+		testStreamMetric("v1", "pl1", 10, 0),
+		testStreamMetric("v2", "pl1", 20, time.Minute),
+		testStreamMetric("v1", "pl1", 11, time.Minute),
+		testStreamMetric("v3", "pl1", 30, time.Minute*3),
+	}
+	videos := []*StreamVideo{
+		{Id: "v3", PlaylistId: "pl1", TimeAdd: testStart.Add(time.Minute)},
+		{Id: "v4", PlaylistId: "pl1", TimeAdd: testStart.Add(time.Minute * 2)},
+	}
+
+	events, err := mergeStreamEvents(metrics, videos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// при однаковому часі нове відео йде перед вимірами, виміри зберігають порядок з БД
+	want := []string{"metric:v1", "video:v3", "metric:v2", "metric:v1", "video:v4", "metric:v3"}
+	if got := eventVideos(events); !equalStrings(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	if events[0].id != testStart.UnixMilli() {
+		t.Errorf("event id = %v, want %v", events[0].id, testStart.UnixMilli())
+	}
+}
+
+func TestLimitStreamEvents(t *testing.T) {
+	metrics := []*StreamMetric{
+		testStreamMetric("v1", "pl1", 10, 0),
+		testStreamMetric("v2", "pl1", 20, time.Minute),
+		testStreamMetric("v1", "pl1", 11, time.Minute*2),
+	}
+	videos := []*StreamVideo{
+		{Id: "v3", PlaylistId: "pl1", TimeAdd: testStart.Add(time.Minute)},
+		{Id: "v4", PlaylistId: "pl1", TimeAdd: testStart.Add(time.Minute * 3)},
+	}
+
+	tests := []struct {
+		name      string
+		metrics   []*StreamMetric
+		videos    []*StreamVideo
+		limit     int
+		want      []string
+		truncated bool
+	}{
+		{"not truncated", metrics, videos, 4, []string{"metric:v1", "video:v3", "metric:v2", "metric:v1", "video:v4"}, false},
+		// нові відео після останнього прочитаного виміру відкидаються: виміри між ними ще не прочитані
+		{"metrics truncated", metrics[:2], videos, 2, []string{"metric:v1", "video:v3", "metric:v2"}, true},
+		{"videos truncated", metrics, videos[:1], 1, []string{"metric:v1", "video:v3", "metric:v2"}, true},
+		{"both truncated", metrics[:1], videos[:1], 1, []string{"metric:v1"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, truncated, err := limitStreamEvents(tt.metrics, tt.videos, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := eventVideos(events); !equalStrings(got, tt.want) || truncated != tt.truncated {
+				t.Errorf("events = %v, truncated = %v, want %v, %v", got, truncated, tt.want, tt.truncated)
+			}
+		})
+	}
+}
+
+// Відновлення потоку з подій db: кожне читання повертає не більше limit подій з часу from
+func testStreamReplay(db *[]*streamEvent, limit int) *streamReplay {
+	return &streamReplay{
+		source: func(from time.Time) ([]*streamEvent, bool, error) {
+			events := []*streamEvent{}
+			for _, e := range *db {
+				if e.id >= from.UnixMilli() {
+					events = append(events, e)
+				}
+			}
+			if len(events) > limit {
+				return events[:limit], true, nil
+			}
+			return events, false, nil
+		},
+		last: testStart.UnixMilli(),
+		sent: make(map[string]int64),
+	}
+}
+
+func testStreamEvent(t *testing.T, id string, offset time.Duration) *streamEvent {
+	t.Helper()
+	e, err := newMetricEvent(testStreamMetric(id, "pl1", 1, offset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestStreamReplay(t *testing.T) {
+	// більше подій, ніж вміщує буфер підписника, по дві події з однаковим часом
+	db := []*streamEvent{}
+	for i := 0; i < STREAM_BUFFER*2; i++ {
+		offset := time.Duration(i/2) * time.Millisecond
+		db = append(db, testStreamEvent(t, "v"+strconv.Itoa(i%2), offset))
+	}
+	replay := testStreamReplay(&db, STREAM_BUFFER+1)
+	w := httptest.NewRecorder()
+	rc := http.NewResponseController(w)
+
+	reads := 0
+	for more := true; more; {
+		events, m, err := replay.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = replay.write(w, rc, events); err != nil {
+			t.Fatal(err)
+		}
+		more = m
+		reads++
+	}
+	if reads < 2 {
+		t.Errorf("replay is read %v times", reads)
+	}
+
+	// кожна подія відправлена один раз
+	ids := strings.Count(w.Body.String(), "id: ")
+	if ids != len(db) {
+		t.Errorf("sent %v events, want %v", ids, len(db))
+	}
+
+	// подія, збережена з часом останньої відправленої події, та нова подія, читання після підписки
+	last := db[len(db)-1]
+	added := []*streamEvent{testStreamEvent(t, "v2", time.Duration(last.id-testStart.UnixMilli())*time.Millisecond),
+		testStreamEvent(t, "v3", time.Hour)}
+	db = append(db, added...)
+
+	events, more, err := replay.read()
+	if err != nil || more {
+		t.Fatalf("more = %v, err = %v", more, err)
+	}
+	if err = replay.write(w, rc, events); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(w.Body.String(), "id: "); got != len(db) {
+		t.Errorf("sent %v events, want %v", got, len(db))
+	}
+
+	// живі події, вже відправлені з таблиць, пропускаються
+	for _, e := range append(added, last) {
+		if !replay.isSent(e) {
+			t.Errorf("event %v is not sent", e.key())
+		}
+	}
+	if replay.isSent(testStreamEvent(t, "v3", time.Hour*2)) {
+		t.Error("new live event is skipped")
+	}
+}
+
+func TestStreamReplaySameTime(t *testing.T) {
+	// всі події з однаковим часом: повторне читання поверне ті самі події, повтор закінчується
+	db := []*streamEvent{}
+	for i := 0; i < STREAM_BUFFER*2; i++ {
+		db = append(db, testStreamEvent(t, "v"+strconv.Itoa(i), 0))
+	}
+	replay := testStreamReplay(&db, STREAM_BUFFER)
+
+	events, more, err := replay.read()
+	if err != nil || more || len(events) != STREAM_BUFFER {
+		t.Errorf("events = %v, more = %v, err = %v", len(events), more, err)
+	}
+}
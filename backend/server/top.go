@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+)
+
+// Рейтинги відео за приростом переглядів, лайків або коментарів за ковзне вікно. Приріст рахує колектор в таблицю
+// video_gain (див. міграцію 0014_video_gain), тому запит рейтингу не переглядає метрики
+
+// Вікна рейтингу (period=)
+const TOP_PERIOD_HOUR = "1h"
+const TOP_PERIOD_DAY = "24h"
+const TOP_PERIOD_WEEK = "7d"
+
+// Метрики рейтингу (metric=)
+const TOP_METRIC_VIEWS = "views"
+const TOP_METRIC_LIKES = "likes"
+const TOP_METRIC_COMMENTS = "comments"
+
+// Кількість відео в рейтингу: за замовчуванням та максимальна (limit=)
+const DEFAULT_TOP_VIDEOS = 20
+const MAX_TOP_VIDEOS = 100
+
+var topPeriods = map[string]bool{TOP_PERIOD_HOUR: true, TOP_PERIOD_DAY: true, TOP_PERIOD_WEEK: true}
+
+// Префікс колонок лічильника та приросту метрики в video_gain
+var topMetricColumn = map[string]string{
+	TOP_METRIC_VIEWS:    "view",
+	TOP_METRIC_LIKES:    "like",
+	TOP_METRIC_COMMENTS: "comment",
+}
+
+// Отримати рейтинг відео за приростом метрики за вікно, playlistId = "" - відео всіх активних плейлистів
+func getTop(period, metric, playlistId string, limit int) ([]byte, error) {
+	log.Debugf("getTop(period: %v, metric: %v, idpl: %v, limit: %v)", period, metric, playlistId, limit)
+
+	if !topPeriods[period] {
+		return nil, badRequest("period must be one of %v, %v, %v", TOP_PERIOD_HOUR, TOP_PERIOD_DAY, TOP_PERIOD_WEEK)
+	}
+	column, ok := topMetricColumn[metric]
+	if !ok {
+		return nil, badRequest("metric must be one of %v, %v, %v", TOP_METRIC_VIEWS, TOP_METRIC_LIKES,
+			TOP_METRIC_COMMENTS)
+	}
+	cacheId := "top_" + period + "_" + metric + "_" + playlistId + "_" + strconv.Itoa(limit)
+
+	// з кешем робимо тільки якщо він включений. Зведення оновлюється рідше за збір метрик, тому дані в кеші
+	// актуальні протягом періоду збору метрик
+	if *config.EnableCache {
+		topi, ok := cacheVideos.Get(cacheId)
+		if ok {
+			top := topi.(*YoutubeVideoShortInCache)
+			if time.Since(top.timeUpdate) < config.PeriodMeterCache.Get() {
+				log.Infof("top: %v, get top from cache", cacheId)
+				return top.responce, nil
+			}
+			log.Debugf("top: %v, cache, skip", cacheId)
+		}
+	}
+
+	response, err := getTopVideosFromDB(period, column, playlistId, limit)
+	if err != nil {
+		return nil, err
+	}
+	response.Metric = metric
+
+	topJson, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("Error convert select to ResponceTop: error=%v", err)
+		return nil, err
+	}
+
+	if *config.EnableCache {
+		cacheVideos.Add(cacheId, &YoutubeVideoShortInCache{time.Now(), topJson})
+	}
+
+	log.Infof("top: %v, get top skip cache", cacheId)
+	return topJson, nil
+}
+
+// Оброблювач запиту рейтингу відео (period - за замовчуванням 24h, metric - за замовчуванням views, idpl,
+// limit - за замовчуванням 20)
+func getTopHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := q.Get("req")
+
+	period := q.Get("period")
+	if period == "" {
+		period = TOP_PERIOD_DAY
+	}
+	metric := q.Get("metric")
+	if metric == "" {
+		metric = TOP_METRIC_VIEWS
+	}
+
+	limit := DEFAULT_TOP_VIDEOS
+	if s := q.Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > MAX_TOP_VIDEOS {
+			writeError(w, r, badRequest("limit must be between 1 and %v", MAX_TOP_VIDEOS))
+			return
+		}
+	}
+	log.Debugf("req=%v(%v), period=%v, metric=%v, limit=%v", req, formatStringDate(req), period, metric, limit)
+
+	topJson, err := getTop(period, metric, q.Get("idpl"), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE_KEY, CONTENT_TYPE_VALUE)
+	w.WriteHeader(http.StatusOK)
+	w.Write(topJson)
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+)
+
+// Фільтри, сортування та сторінки списків відео (/view/videos, /view/videos/{id}). Сторінку можна вибрати
+// зсувом (skip) або курсором (cursor). Курсор - позиція (publishedat, id) останнього відео попередньої сторінки,
+// тому нові відео, додані колектором, не зсувають наступні сторінки
+
+// Сортування (sort=), за спаданням
+const VIDEO_SORT_PUBLISHED = "published"
+const VIDEO_SORT_VIEWS = "views"
+const VIDEO_SORT_LIKES = "likes"
+const VIDEO_SORT_COMMENTS = "comments"
+const VIDEO_SORT_GROWTH = "growth"
+
+// Стан збору метрик (status=): метрики ще збираються або збір закінчився (periodCollect плейлиста
+// або periodCollectCache)
+const VIDEO_STATUS_ACTIVE = "active"
+const VIDEO_STATUS_FINISHED = "finished"
+
+// Параметри списку відео, пусті значення - без обмеження
+type videoFilter struct {
+	Sort string
+
+	// Період публікації, мілісекунди
+	From string
+	To   string
+
+	ChannelId string
+
+	// Межі останньої кількості переглядів
+	MinViews sql.NullInt64
+	MaxViews sql.NullInt64
+
+	Status string
+
+	// Розмір сторінки (limit, за замовчуванням MaxViewVideosInPlayLists)
+	Limit int
+
+	// Курсор сторінки, nil - з початку списку
+	Cursor *videoCursor
+
+	// Відповідь - сторінка ResponceVideos (заданий limit або cursor), інакше - масив відео
+	Paged bool
+}
+
+// Позиція в списку відео, відсортованому за часом публікації
+type videoCursor struct {
+	PublishedAt time.Time `json:"p"`
+	Id          string    `json:"i"`
+}
+
+// Курсор у вигляді рядка для клієнта
+func (cursor *videoCursor) String() string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Прочитати курсор, отриманий від клієнта
+func parseVideoCursor(s string) (*videoCursor, error) {
+	cursor := &videoCursor{}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, cursor)
+	}
+	if err != nil || cursor.Id == "" || cursor.PublishedAt.IsZero() {
+		return nil, badRequest("invalid cursor: %v", s)
+	}
+	return cursor, nil
+}
+
+// Прочитати параметри списку відео з запиту
+func parseVideoFilter(q url.Values) (*videoFilter, error) {
+	filter := &videoFilter{
+		Sort:      q.Get("sort"),
+		From:      q.Get("from"),
+		To:        q.Get("to"),
+		ChannelId: strings.TrimSpace(q.Get("chid")),
+		Status:    q.Get("status"),
+	}
+
+	if filter.Sort == "" {
+		filter.Sort = VIDEO_SORT_PUBLISHED
+	}
+	if _, ok := videoSortOrder[filter.Sort]; !ok {
+		return nil, badRequest("sort must be %v, %v, %v, %v or %v", VIDEO_SORT_PUBLISHED, VIDEO_SORT_VIEWS,
+			VIDEO_SORT_LIKES, VIDEO_SORT_COMMENTS, VIDEO_SORT_GROWTH)
+	}
+
+	if filter.Status != "" && filter.Status != VIDEO_STATUS_ACTIVE && filter.Status != VIDEO_STATUS_FINISHED {
+		return nil, badRequest("status must be %v or %v", VIDEO_STATUS_ACTIVE, VIDEO_STATUS_FINISHED)
+	}
+
+	for _, views := range []struct {
+		name  string
+		value *sql.NullInt64
+	}{{"minviews", &filter.MinViews}, {"maxviews", &filter.MaxViews}} {
+		s := q.Get(views.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 {
+			return nil, badRequest("%v must be a non-negative number: %v", views.name, s)
+		}
+		*views.value = sql.NullInt64{Int64: n, Valid: true}
+	}
+
+	filter.Limit = config.MaxViewVideosInPlayLists.Get()
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > config.MaxPageSize.Get() {
+			return nil, badRequest("limit must be between 1 and %v", config.MaxPageSize.Get())
+		}
+		filter.Limit = limit
+		filter.Paged = true
+	}
+
+	if q.Has("cursor") {
+		filter.Paged = true
+		if filter.Sort != VIDEO_SORT_PUBLISHED {
+			return nil, badRequest("cursor can be used only with sort=%v", VIDEO_SORT_PUBLISHED)
+		}
+		if s := q.Get("cursor"); s != "" {
+			cursor, err := parseVideoCursor(s)
+			if err != nil {
+				return nil, err
+			}
+			filter.Cursor = cursor
+		}
+	}
+
+	// дати перевіряються тут, щоб помилка не залежала від того, чи є відповідь в кеші
+	for _, date := range []string{filter.From, filter.To} {
+		if _, err := checkDate(date); err != nil {
+			return nil, err
+		}
+	}
+
+	return filter, nil
+}
+
+// Ключ кешу списку відео: всі параметри, від яких залежить відповідь
+func (filter *videoFilter) key() string {
+	views := func(n sql.NullInt64) string {
+		if !n.Valid {
+			return ""
+		}
+		return strconv.FormatInt(n.Int64, 10)
+	}
+
+	cursor := ""
+	if filter.Cursor != nil {
+		cursor = filter.Cursor.String()
+	}
+
+	return strings.Join([]string{filter.Sort, filter.From, filter.To, filter.ChannelId, views(filter.MinViews),
+		views(filter.MaxViews), filter.Status, strconv.Itoa(filter.Limit), strconv.FormatBool(filter.Paged), cursor}, "_")
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/AleksandrKuts/youtubemeter-service/backend/config"
+)
+
+func TestParseVideoCursor(t *testing.T) {
+	cursor := &videoCursor{PublishedAt: testStart, Id: "v1"}
+	got, err := parseVideoCursor(cursor.String())
+	if err != nil || !got.PublishedAt.Equal(cursor.PublishedAt) || got.Id != cursor.Id {
+		t.Errorf("parseVideoCursor(%v) = %+v, %v, want %+v", cursor, got, err, cursor)
+	}
+
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"p":"2026-01-01T00:00:00Z","i":"v1"}`))},
+		{"not json", encode("v1")},
+		{"empty object", encode("{}")},
+		{"no id", encode(`{"p":"2026-01-01T00:00:00Z"}`)},
+		{"no time", encode(`{"i":"v1"}`)},
+		{"invalid time", encode(`{"p":"yesterday","i":"v1"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cursor, err := parseVideoCursor(tt.cursor); err == nil {
+				t.Errorf("parseVideoCursor(%q) = %+v, want error", tt.cursor, cursor)
+			}
+		})
+	}
+}
+
+func TestParseVideoFilter(t *testing.T) {
+	cursor := (&videoCursor{PublishedAt: testStart, Id: "v1"}).String()
+	maxPage := strconv.Itoa(config.MaxPageSize.Get())
+
+	tests := []struct {
+		query string
+		valid bool
+		check func(f *videoFilter) bool
+	}{
+		{"", true, func(f *videoFilter) bool {
+			return f.Sort == VIDEO_SORT_PUBLISHED && f.Limit == config.MaxViewVideosInPlayLists.Get() && !f.Paged &&
+				!f.MinViews.Valid && !f.MaxViews.Valid && f.Cursor == nil
+		}},
+		{"sort=views&status=active&chid=+ch1+", true, func(f *videoFilter) bool {
+			return f.Sort == VIDEO_SORT_VIEWS && f.Status == VIDEO_STATUS_ACTIVE && f.ChannelId == "ch1"
+		}},
+		{"sort=dislikes", false, nil},
+		{"status=paused", false, nil},
+		{"minviews=0&maxviews=100", true, func(f *videoFilter) bool {
+			return f.MinViews.Valid && f.MinViews.Int64 == 0 && f.MaxViews.Valid && f.MaxViews.Int64 == 100
+		}},
+		{"minviews=-1", false, nil},
+		{"maxviews=-100", false, nil},
+		{"maxviews=many", false, nil},
+		{"limit=1", true, func(f *videoFilter) bool { return f.Limit == 1 && f.Paged }},
+		{"limit=" + maxPage, true, func(f *videoFilter) bool { return f.Limit == config.MaxPageSize.Get() }},
+		{"limit=0", false, nil},
+		{"limit=-1", false, nil},
+		{"limit=" + strconv.Itoa(config.MaxPageSize.Get()+1), false, nil},
+		{"limit=ten", false, nil},
+		{"cursor=", true, func(f *videoFilter) bool { return f.Paged && f.Cursor == nil }},
+		{"cursor=" + cursor, true, func(f *videoFilter) bool { return f.Paged && f.Cursor != nil && f.Cursor.Id == "v1" }},
+		{"cursor=" + cursor + "&sort=published", true, nil},
+		{"cursor=" + cursor + "&sort=views", false, nil},
+		{"cursor=&sort=growth", false, nil},
+		{"cursor=invalid", false, nil},
+		{"from=1767225600000&to=1767312000000", true, nil},
+		{"from=yesterday", false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter, err := parseVideoFilter(q)
+			if (err == nil) != tt.valid {
+				t.Fatalf("parseVideoFilter(%q) error = %v, valid %v", tt.query, err, tt.valid)
+			}
+			if tt.check != nil && !tt.check(filter) {
+				t.Errorf("parseVideoFilter(%q) = %+v", tt.query, filter)
+			}
+		})
+	}
+
+	// курсор та limit потрапляють в ключ кешу
+	a, _ := parseVideoFilter(url.Values{"limit": {"10"}})
+	b, _ := parseVideoFilter(url.Values{"limit": {"10"}, "cursor": {cursor}})
+	if a.key() == b.key() {
+		t.Errorf("key() does not depend on cursor: %v", a.key())
+	}
+}
@@ -2,16 +2,20 @@ package config
 
 import (
 	"flag"
+	"fmt"
+	"os"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"time"
 	"strings"
 	"github.com/vharitonsky/iniflags"
+	"github.com/AleksandrKuts/youtubemeter-service/reload"
+	"github.com/AleksandrKuts/youtubemeter-service/settings"
 )
 
 var (
 	// Get command options
-	debugLevel = flag.String("debugLevel", "info", "")
+	debugLevel = reload.String("debugLevel", "info", "")
 	Log = flag.String("Log", "collector.log", "")
 	LogError = flag.String("LogError", "collector_error.log", "")
 	LogTimeFormat = flag.String("LogTimeFormat", "02-01-2006 15:04:05", "")
@@ -21,61 +25,111 @@ var (
 	
 	Timeout = flag.Duration("timeout", time.Second * 15, "")
 
-	PeriodPlayList = flag.Duration("periodPlayList", time.Second * 600, "")
-	PeriodVideo = flag.Duration("periodVideo", time.Second * 60, "")
-	PeriodMeter = flag.Duration("periodMetric", time.Second * 60, "")
-	ShiftPeriodMetric = flag.Duration("shiftPeriodMetric", time.Second * 30, "")
-	PeriodCount = flag.Duration("periodSaveMetricIdle", time.Hour * 1, "")
-	PeriodDeleted = flag.Duration("periodFinalDeletion", time.Hour * 24, "")
-	PeriodСollection = flag.Duration("periodCollect", time.Hour * 24 * 14, "")
-	MaxRequestVideos = flag.Int64("maxRequestVideos", 20, "")
-	MaxRequestCountVideoID = flag.Int("maxRequestCountVideoID", 50, "")
+	PeriodPlayList = reload.Duration("periodPlayList", time.Second * 600, "")
+	PeriodVideo = reload.Duration("periodVideo", time.Second * 60, "")
+	PeriodMeter = reload.Duration("periodMetric", time.Second * 60, "")
+	ShiftPeriodMetric = reload.Duration("shiftPeriodMetric", time.Second * 30, "")
+	PeriodCount = reload.Duration("periodSaveMetricIdle", time.Hour * 1, "")
+	PeriodDeleted = reload.Duration("periodFinalDeletion", time.Hour * 24, "")
+	PeriodСollection = reload.Duration("periodCollect", time.Hour * 24 * 14, "")
+	MaxRequestVideos = reload.Int64("maxRequestVideos", 20, "")
+	MaxRequestCountVideoID = reload.Int("maxRequestCountVideoID", 50, "")
+
+	PeriodRollup = reload.Duration("periodRollup", time.Hour * 1, "")
+	PeriodGain = reload.Duration("periodGain", time.Minute * 5, "")
+	KeepRawMetric = reload.Duration("keepRawMetric", 0, "")
+	PeriodPartition = reload.Duration("periodPartition", time.Hour * 24, "")
+	MetricPartitionsAhead = reload.Int("metricPartitionsAhead", 2, "")
+	ArchiveMetric = reload.Duration("archiveMetric", 0, "")
+
+	PeriodQuery = reload.Duration("periodQuery", time.Minute * 30, "")
+	MaxQueryResults = reload.Int64("maxQueryResults", 25, "")
+	SearchQuota = reload.Int("searchQuota", 2000, "")
+
+	CommentEnable = flag.Bool("commentEnable", false, "")
+	PeriodComment = reload.Duration("periodComment", time.Hour * 1, "")
+	PeriodCollectComment = reload.Duration("periodCollectComment", time.Hour * 72, "")
+	MaxCommentPages = reload.Int("maxCommentPages", 2, "")
+	CommentQuota = reload.Int("commentQuota", 2000, "")
+
+	WebSubEnable = flag.Bool("webSubEnable", false, "")
+	WebSubListen = flag.String("webSubListen", "0.0.0.0:3001", "")
+	WebSubCallbackURL = flag.String("webSubCallbackURL", "", "")
+	WebSubHub = flag.String("webSubHub", "https://pubsubhubbub.appspot.com/subscribe", "")
+	WebSubLease = flag.Duration("webSubLease", time.Hour * 24 * 5, "")
+	WebSubSecret = flag.String("webSubSecret", "", "")
+	PeriodVideoWebSub = reload.Duration("periodVideoWebSub", time.Hour * 1, "")
 	
 	DBHost = flag.String("dbhost", "localhost", "")
 	DBPort = flag.String("dbport", "5432", "")
 	DBName = flag.String("dbname", "basename", "")
 	DBUser = flag.String("dbuser", "username", "")
 	DBPassword = flag.String("dbpasswd", "userpasswd", "")
+	DBPasswordFile = flag.String("dbpasswdFile", "", "File with database password, overrides dbpasswd")
 	DBSSLMode = flag.String("dbsslmode", "disable", "")
 
+	printConfig = flag.Bool("print-config", false, "Print configuration with hidden secrets and exit")
+	Migrate = flag.String("migrate", "", "Migrate database schema (up, down or status) and exit")
+
 	Logger *zap.SugaredLogger	
+
+	// Рівень налагодження, може змінюватись під час роботи (див. Reload)
+	atomicLevel zap.AtomicLevel
 )
 
 func myTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format(*LogTimeFormat))
 }
 
-func init() {
-	iniflags.Parse() 
-
-	*FileSecret = strings.TrimSpace(*FileSecret)
-
-	// set debug level
-	var atomicLevel zapcore.Level
-
-	switch *debugLevel {
+// Рівень налагодження за назвою, невідома назва - info
+func parseLevel(level string) zapcore.Level {
+	switch level {
 	case "debug":
-		atomicLevel = zapcore.DebugLevel
+		return zapcore.DebugLevel
 	case "info":
-		atomicLevel = zapcore.InfoLevel
+		return zapcore.InfoLevel
 	case "warn":
-		atomicLevel = zapcore.WarnLevel
+		return zapcore.WarnLevel
 	case "error":
-		atomicLevel = zapcore.ErrorLevel
+		return zapcore.ErrorLevel
 	case "dpanic":
-		atomicLevel = zapcore.DPanicLevel
+		return zapcore.DPanicLevel
 	case "panic":
-		atomicLevel = zapcore.PanicLevel
+		return zapcore.PanicLevel
 	case "fatal":
-		atomicLevel = zapcore.FatalLevel
+		return zapcore.FatalLevel
 	default:
-		atomicLevel = zapcore.InfoLevel
+		return zapcore.InfoLevel
 	}
+}
+
+// Завантажити налаштування (ini-файл, командний рядок, змінні оточення YTM_*), перевірити їх та створити логер.
+// main викликає Load до роботи з іншими пакетами програми
+func Load() {
+	iniflags.Parse() 
+
+	// Змінні оточення YTM_* мають пріоритет над ini-файлом, пароль з файлу - над dbpasswd
+	errs := settings.ApplyEnv(envFlags)
+	if err := readPasswordFile(); err != nil {
+		errs = append(errs, "cannot read dbpasswdFile: "+err.Error())
+	}
+
+	*FileSecret = strings.TrimSpace(*FileSecret)
+
+	settings.ExitOnErrors(append(errs, validate()...))
+
+	if *printConfig {
+		fmt.Println(formatConfig())
+		os.Exit(0)
+	}
+
+	// set debug level
+	atomicLevel = zap.NewAtomicLevelAt(parseLevel(debugLevel.Get()))
 
 	// Set loggin systems
 	cfg := zap.Config{
 		Encoding:         "console",
-		Level:            zap.NewAtomicLevelAt(atomicLevel),
+		Level:            atomicLevel,
 		OutputPaths:      strings.Split( *Log, ","),
 		ErrorOutputPaths: strings.Split( *LogError, ","),
 		EncoderConfig: zapcore.EncoderConfig{
@@ -92,36 +146,19 @@ func init() {
 		},
 	}
 
-	if *MaxRequestCountVideoID < 1 && *MaxRequestCountVideoID > 50 {
-		*MaxRequestCountVideoID = 50
+	// всі повідомлення логера проходять через settings.RedactCore, щоб секрети не потрапили в лог
+	logger, err := cfg.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return settings.RedactCore{Core: core}
+	}))
+	if err != nil {
+		settings.ExitOnErrors([]string{"cannot create logger: " + err.Error()})
 	}
 
-	logger, _ := cfg.Build()
 	defer logger.Sync() // flushes buffer, if any
 	Logger = logger.Sugar()	
 	
-	Logger.Warnf("debug level=%v", atomicLevel)
-	Logger.Debugf("Log=%v", *Log)
-	Logger.Debugf("LogError=%v", *LogError)
-	Logger.Debugf("LogTimeFormat=%v", *LogTimeFormat)
-
-	Logger.Debugf("fileSecret=%v", *FileSecret)
-	Logger.Debugf("timeout=%v", *Timeout)
-	
-	Logger.Debugf("PeriodPlayList=%v", *PeriodPlayList)
-	Logger.Debugf("PeriodVideo=%v", *PeriodVideo)
-	Logger.Debugf("PeriodMeter=%v", *PeriodMeter)
-	Logger.Debugf("ShiftPeriodMetric=%v", *ShiftPeriodMetric)	
-	Logger.Debugf("PeriodCount=%v", *PeriodCount)
-	Logger.Debugf("PeriodDeleted=%v", *PeriodDeleted)
-	Logger.Debugf("PeriodСollection=%v", *PeriodСollection)
-	Logger.Debugf("MaxRequestVideos=%v", *MaxRequestVideos)
-	Logger.Debugf("MaxReqestCountVideoID=%v", *MaxRequestCountVideoID)
-
-	Logger.Debugf("dbhost=%s", *DBHost)
-	Logger.Debugf("dbport=%s", *DBPort)
-	Logger.Debugf("dbname=%s", *DBName)
-	Logger.Debugf("dbuser=%s", *DBUser)
-	Logger.Debugf("dbpasswd=%s", *DBPassword)
-	Logger.Debugf("dbsslmode=%s", *DBSSLMode)	
+	Logger.Warnf("debug level=%v", atomicLevel.Level())
+	for _, line := range strings.Split(formatConfig(), "\n") {
+		Logger.Debug(line)
+	}
 }
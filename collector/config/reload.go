@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/AleksandrKuts/youtubemeter-service/reload"
+)
+
+// Налаштування, які можна змінити під час роботи без перезапуску програми. Всі інші налаштування
+// (база даних, лог-файли, файли авторизації тощо) потребують перезапуску
+var reloadable = map[string]bool{
+	"debugLevel":             true,
+	"periodPlayList":         true,
+	"periodVideo":            true,
+	"periodMetric":           true,
+	"shiftPeriodMetric":      true,
+	"periodSaveMetricIdle":   true,
+	"periodFinalDeletion":    true,
+	"periodCollect":          true,
+	"maxRequestVideos":       true,
+	"maxRequestCountVideoID": true,
+	"periodRollup":           true,
+	"periodGain":             true,
+	"keepRawMetric":          true,
+	"periodPartition":        true,
+	"metricPartitionsAhead":  true,
+	"archiveMetric":          true,
+	"periodVideoWebSub":      true,
+	"periodQuery":            true,
+	"maxQueryResults":        true,
+	"searchQuota":            true,
+	"periodComment":          true,
+	"periodCollectComment":   true,
+	"maxCommentPages":        true,
+	"commentQuota":           true,
+}
+
+// Перечитування налаштувань без перезапуску програми
+var reloader = reload.New(reloadable, validate, displayValue)
+
+// Зареєструвати функцію, яка буде викликана після успішного перечитування налаштувань
+func OnReload(f func()) {
+	reloader.OnReload(f)
+}
+
+// Перечитати ini-файл налаштувань та застосувати зміни (див. reload.Reloader.Reload). Налаштування задані
+// змінними оточення мають пріоритет над ini-файлом, тому не перечитуються.
+// Повертає перелік змінених налаштувань у форматі "name: old -> new"
+func Reload() ([]string, error) {
+	changed, err := reloader.Reload(envFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	atomicLevel.SetLevel(parseLevel(debugLevel.Get()))
+
+	if len(changed) > 0 {
+		Logger.Warnf("config reloaded, changed: %v", strings.Join(changed, ", "))
+	} else {
+		Logger.Info("config reloaded, nothing changed")
+	}
+
+	return changed, nil
+}
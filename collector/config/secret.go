@@ -0,0 +1,45 @@
+package config
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/AleksandrKuts/youtubemeter-service/settings"
+)
+
+// Налаштування, значення яких є секретами і не повинні потрапляти в логи
+var secretFlags = map[string]bool{
+	"dbpasswd":     true,
+	"webSubSecret": true,
+}
+
+// Прочитати пароль до БД з файлу (dbpasswdFile), якщо файл заданий. Пароль з файлу має пріоритет над dbpasswd
+func readPasswordFile() error {
+	if *DBPasswordFile == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(*DBPasswordFile)
+	if err != nil {
+		return err
+	}
+	*DBPassword = strings.TrimSpace(string(b))
+
+	return nil
+}
+
+// Рядок підключення до БД
+// example: host=127.0.0.100 port=5432 dbname=base1 user=user1 password=lalala sslmode=disable"
+func DSN() string {
+	return "host=" + settings.QuoteDSN(*DBHost) +
+		" port=" + settings.QuoteDSN(*DBPort) +
+		" dbname=" + settings.QuoteDSN(*DBName) +
+		" user=" + settings.QuoteDSN(*DBUser) +
+		" password=" + settings.QuoteDSN(*DBPassword) +
+		" sslmode=" + settings.QuoteDSN(*DBSSLMode)
+}
+
+// Значення налаштування для виводу: секретні значення замінюються на settings.REDACTED
+func displayValue(name, value string) string {
+	return settings.Display(secretFlags, name, value)
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"github.com/AleksandrKuts/youtubemeter-service/reload"
+	"github.com/AleksandrKuts/youtubemeter-service/settings"
+)
+
+// Налаштування, задані змінними оточення. Вони мають пріоритет над ini-файлом, тому не перечитуються (див. Reload)
+var envFlags = make(map[string]bool)
+
+// Правила перевірки налаштувань
+var rules = []settings.Rule{
+	settings.NotEmpty("Log", reload.Fixed(Log)),
+	settings.NotEmpty("LogError", reload.Fixed(LogError)),
+	settings.OneOf("debugLevel", debugLevel.Candidate, "debug", "info", "warn", "error", "dpanic", "panic", "fatal"),
+	settings.NotEmpty("fileToken", reload.Fixed(FileSecret)),
+	settings.NotEmpty("fileCredential", reload.Fixed(CredentialFile)),
+	settings.PositiveDuration("timeout", reload.Fixed(Timeout)),
+	settings.PositiveDuration("periodPlayList", PeriodPlayList.Candidate),
+	settings.PositiveDuration("periodVideo", PeriodVideo.Candidate),
+	settings.PositiveDuration("periodMetric", PeriodMeter.Candidate),
+	settings.NonNegativeDuration("shiftPeriodMetric", ShiftPeriodMetric.Candidate),
+	settings.PositiveDuration("periodSaveMetricIdle", PeriodCount.Candidate),
+	settings.PositiveDuration("periodFinalDeletion", PeriodDeleted.Candidate),
+	settings.PositiveDuration("periodCollect", PeriodСollection.Candidate),
+	settings.Int64Between("maxRequestVideos", MaxRequestVideos.Candidate, 1, 50),
+	settings.IntBetween("maxRequestCountVideoID", MaxRequestCountVideoID.Candidate, 1, 50),
+	settings.PositiveDuration("periodRollup", PeriodRollup.Candidate),
+	settings.PositiveDuration("periodGain", PeriodGain.Candidate),
+	settings.NonNegativeDuration("keepRawMetric", KeepRawMetric.Candidate),
+	settings.PositiveDuration("periodPartition", PeriodPartition.Candidate),
+	settings.IntBetween("metricPartitionsAhead", MetricPartitionsAhead.Candidate, 1, 24),
+	settings.NonNegativeDuration("archiveMetric", ArchiveMetric.Candidate),
+	settings.PositiveDuration("periodQuery", PeriodQuery.Candidate),
+	settings.Int64Between("maxQueryResults", MaxQueryResults.Candidate, 1, 50),
+	settings.IntBetween("searchQuota", SearchQuota.Candidate, 100, 1000000),
+	settings.PositiveDuration("periodVideoWebSub", PeriodVideoWebSub.Candidate),
+	// таймер коментарів створюється завжди, навіть якщо збір коментарів вимкнений
+	settings.PositiveDuration("periodComment", PeriodComment.Candidate),
+	settings.IfEnabled(reload.Fixed(CommentEnable),
+		settings.PositiveDuration("periodCollectComment", PeriodCollectComment.Candidate)),
+	settings.IfEnabled(reload.Fixed(CommentEnable),
+		settings.IntBetween("maxCommentPages", MaxCommentPages.Candidate, 1, 100)),
+	settings.IfEnabled(reload.Fixed(CommentEnable),
+		settings.IntBetween("commentQuota", CommentQuota.Candidate, 1, 1000000)),
+	settings.IfEnabled(reload.Fixed(WebSubEnable), settings.HostPort("webSubListen", reload.Fixed(WebSubListen))),
+	settings.IfEnabled(reload.Fixed(WebSubEnable),
+		settings.AbsoluteURL("webSubCallbackURL", reload.Fixed(WebSubCallbackURL))),
+	settings.IfEnabled(reload.Fixed(WebSubEnable), settings.AbsoluteURL("webSubHub", reload.Fixed(WebSubHub))),
+	settings.IfEnabled(reload.Fixed(WebSubEnable), settings.PositiveDuration("webSubLease", reload.Fixed(WebSubLease))),
+	// без секрету будь-хто, хто знає адресу, може надсилати сповіщення
+	settings.IfEnabled(reload.Fixed(WebSubEnable), settings.NotEmpty("webSubSecret", reload.Fixed(WebSubSecret))),
+	settings.NotEmpty("dbhost", reload.Fixed(DBHost)),
+	settings.Port("dbport", reload.Fixed(DBPort)),
+	settings.NotEmpty("dbname", reload.Fixed(DBName)),
+	settings.NotEmpty("dbuser", reload.Fixed(DBUser)),
+	settings.OneOf("migrate", reload.Fixed(Migrate), "", "up", "down", "status"),
+	settings.OneOf("dbsslmode", reload.Fixed(DBSSLMode),
+		"disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+}
+
+// Перевірити всі налаштування, повертає перелік помилок
+func validate() []string {
+	return settings.Validate(rules)
+}
+
+// Всі налаштування у форматі ini-файлу, секретні значення приховані
+func formatConfig() string {
+	return settings.FormatConfig(displayValue, "config", "print-config", "migrate")
+}
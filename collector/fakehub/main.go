@@ -0,0 +1,185 @@
+// Локальний хаб WebSub для перевірки сповіщень колектора без доступу до хаба YouTube.
+//
+// Запуск: fakehub -listen 127.0.0.1:3002 -secret <webSubSecret колектора>
+// В налаштуваннях колектора: webSubHub = http://127.0.0.1:3002/subscribe
+//
+// Сповіщення про нове відео: curl 'http://127.0.0.1:3002/publish?channel_id=UC...&video_id=...&title=...'
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const TOPIC = "https://www.youtube.com/xml/feeds/videos.xml?channel_id="
+
+const FEED = `<?xml version='1.0' encoding='UTF-8'?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom">
+ <link rel="hub" href="https://pubsubhubbub.appspot.com"/>
+ <link rel="self" href="%[1]v"/>
+ <title>YouTube video feed</title>
+ <updated>%[5]v</updated>
+ <entry>
+  <id>yt:video:%[3]v</id>
+  <yt:videoId>%[3]v</yt:videoId>
+  <yt:channelId>%[2]v</yt:channelId>
+  <title>%[4]v</title>
+  <link rel="alternate" href="https://www.youtube.com/watch?v=%[3]v"/>
+  <published>%[5]v</published>
+  <updated>%[5]v</updated>
+ </entry>
+</feed>`
+
+var (
+	listen = flag.String("listen", "127.0.0.1:3002", "")
+	secret = flag.String("secret", "", "default secret, if subscriber does not send hub.secret")
+)
+
+// Підписник на стрічку каналу
+type subscriber struct {
+	callback string
+	secret   string
+}
+
+// Підписники по темах
+var topics = struct {
+	sync.Mutex
+	subscribers map[string]map[string]subscriber
+}{subscribers: make(map[string]map[string]subscriber)}
+
+func main() {
+	flag.Parse()
+
+	http.HandleFunc("/subscribe", subscribeHandler)
+	http.HandleFunc("/publish", publishHandler)
+
+	log.Printf("fake hub listen: %v", *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
+
+// Запит на підписку чи відписку, підтвердження робиться асинхронно, як у справжнього хаба
+func subscribeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Method != "POST" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	mode := r.PostForm.Get("hub.mode")
+	s := subscriber{callback: r.PostForm.Get("hub.callback"), secret: r.PostForm.Get("hub.secret")}
+	topic := r.PostForm.Get("hub.topic")
+	lease := r.PostForm.Get("hub.lease_seconds")
+	if s.secret == "" {
+		s.secret = *secret
+	}
+
+	if (mode != "subscribe" && mode != "unsubscribe") || s.callback == "" || !strings.HasPrefix(topic, TOPIC) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	go verify(mode, topic, lease, s)
+}
+
+// Перевірка наміру підписника: відповідь повинна містити hub.challenge
+func verify(mode, topic, lease string, s subscriber) {
+	challenge := make([]byte, 16)
+	rand.Read(challenge)
+
+	q := url.Values{}
+	q.Set("hub.mode", mode)
+	q.Set("hub.topic", topic)
+	q.Set("hub.challenge", hex.EncodeToString(challenge))
+	if lease != "" {
+		q.Set("hub.lease_seconds", lease)
+	}
+
+	callback := s.callback
+	if strings.Contains(callback, "?") {
+		callback += "&" + q.Encode()
+	} else {
+		callback += "?" + q.Encode()
+	}
+
+	resp, err := http.Get(callback)
+	if err != nil {
+		log.Printf("%v %v, verify error: %v", mode, topic, err)
+		return
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 || string(body) != q.Get("hub.challenge") {
+		log.Printf("%v %v, verify rejected: %v", mode, topic, resp.Status)
+		return
+	}
+
+	topics.Lock()
+	defer topics.Unlock()
+	if mode == "subscribe" {
+		if topics.subscribers[topic] == nil {
+			topics.subscribers[topic] = make(map[string]subscriber)
+		}
+		topics.subscribers[topic][s.callback] = s
+	} else {
+		delete(topics.subscribers[topic], s.callback)
+	}
+	log.Printf("%v %v, callback: %v, verified", mode, topic, s.callback)
+}
+
+// Надіслати підписникам каналу сповіщення про нове відео
+func publishHandler(w http.ResponseWriter, r *http.Request) {
+	channelId := r.FormValue("channel_id")
+	videoId := r.FormValue("video_id")
+	title := r.FormValue("title")
+	if channelId == "" || videoId == "" {
+		http.Error(w, "channel_id and video_id are required", http.StatusBadRequest)
+		return
+	}
+	if title == "" {
+		title = "video " + videoId
+	}
+
+	topic := TOPIC + channelId
+	feed := []byte(fmt.Sprintf(FEED, topic, channelId, videoId, title, time.Now().UTC().Format(time.RFC3339)))
+
+	topics.Lock()
+	subscribers := []subscriber{}
+	for _, s := range topics.subscribers[topic] {
+		subscribers = append(subscribers, s)
+	}
+	topics.Unlock()
+
+	for _, s := range subscribers {
+		req, _ := http.NewRequest("POST", s.callback, bytes.NewReader(feed))
+		req.Header.Set("Content-Type", "application/atom+xml")
+		req.Header.Set("Link", "<"+topic+">; rel=self")
+		if s.secret != "" {
+			mac := hmac.New(sha1.New, []byte(s.secret))
+			mac.Write(feed)
+			req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Printf("publish %v to %v, error: %v", videoId, s.callback, err)
+			continue
+		}
+		resp.Body.Close()
+		log.Printf("publish %v to %v: %v", videoId, s.callback, resp.Status)
+	}
+
+	fmt.Fprintf(w, "published to %v subscribers\n", len(subscribers))
+}
@@ -1,17 +1,33 @@
-package main
-
-import (
-	"fmt"
-	"github.com/AleksandrKuts/youtubemeter-service/collector/server"
-)
-
-const versionMajor = "0.1"
-
-var (
-	version string
-)
-
-func main() {
-	fmt.Printf("version: %s.%s\n", versionMajor, version)
-	server.StartService(versionMajor, version)
-}
+package main
+
+import (
+	"fmt"
+	"os"
+	"github.com/AleksandrKuts/youtubemeter-service/collector/config"
+	"github.com/AleksandrKuts/youtubemeter-service/collector/server"
+	"github.com/AleksandrKuts/youtubemeter-service/collector/server/database"
+)
+
+const versionMajor = "0.1"
+
+var (
+	version string
+)
+
+func main() {
+	config.Load()
+	fmt.Printf("version: %s.%s\n", versionMajor, version)
+
+	database.Open()
+
+	// Міграція схеми БД: collector --migrate=up|down|status
+	if *config.Migrate != "" {
+		if err := database.Migrate(*config.Migrate); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot migrate database: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	server.StartService(versionMajor, version)
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/AleksandrKuts/youtubemeter-service/collector/config"
+	"github.com/AleksandrKuts/youtubemeter-service/collector/server/database"
+	"github.com/AleksandrKuts/youtubemeter-service/collector/server/model"
+)
+
+// Вибірка коментарів верхнього рівня для відео в перші дні після публікації (config.PeriodCollectComment).
+// Для кожного відео запитуються найновіші коментарі до вже збережених (не більше config.MaxCommentPages сторінок)
+// та одна сторінка найпопулярніших, щоб оновити кількість лайків. Кожен запит коштує 1 одиницю квоти YouTube API,
+// загальна кількість запитів за добу обмежена config.CommentQuota
+
+const COMMENT_PART = "snippet"
+const COMMENT_ORDER_TIME = "time"
+const COMMENT_ORDER_RELEVANCE = "relevance"
+const COMMENT_TEXT_FORMAT = "plainText"
+const COMMENT_PAGE_SIZE = 100
+const COMMENTS_DISABLED = "commentsDisabled"
+
+// Використана квота запитів коментарів за поточну добу
+var commentQuota = struct {
+	sync.Mutex
+	start time.Time
+	used  int
+}{}
+
+// Ознака, що вибірка коментарів вже виконується, наступна вибірка по таймеру пропускається
+var commentsRunning int32
+
+// Відео для вибірки коментарів
+type commentVideo struct {
+	id       string
+	video    *model.YoutubeVideo
+	playList *model.YoutubePlayList
+}
+
+// Взяти з квоти один запит коментарів, false - квота на поточну добу вичерпана
+func takeCommentQuota() bool {
+	commentQuota.Lock()
+	defer commentQuota.Unlock()
+
+	if time.Since(commentQuota.start) >= time.Hour*24 {
+		commentQuota.start = time.Now()
+		commentQuota.used = 0
+	}
+	if commentQuota.used >= config.CommentQuota.Get() {
+		return false
+	}
+	commentQuota.used++
+
+	return true
+}
+
+// Вибірка коментарів для всіх відео, для яких настав час. Наймолодші відео обробляються першими,
+// щоб при нестачі квоти коментарі пропускались для старіших відео
+func getComments() {
+	if !*config.CommentEnable {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&commentsRunning, 0, 1) {
+		log.Warn("comments, SKIP - previous sampling is not finished")
+		return
+	}
+	defer atomic.StoreInt32(&commentsRunning, 0)
+
+	log.Debug("comments start")
+
+	videos := []commentVideo{}
+	for _, playList := range getRequestPlayList() {
+		videos = appendCommentVideos(videos, playList)
+	}
+	for _, query := range getRequestQueries() {
+		videos = appendCommentVideos(videos, query.YoutubePlayList)
+	}
+
+	sort.Slice(videos, func(i, j int) bool {
+		return videos[i].video.PublishedAt.After(videos[j].video.PublishedAt)
+	})
+
+	count := 0
+	for _, v := range videos {
+		if !getVideoComments(v) {
+			log.Warnf("comments, quota %v is exhausted, skip videos: %v", config.CommentQuota.Get(), len(videos)-count)
+			break
+		}
+		count++
+	}
+
+	log.Infof("comments end, videos: %v", count)
+}
+
+// Додати відео плейлиста, для яких настав час вибірки коментарів. Половина періоду - допуск на нерівномірність
+// спрацювання таймера
+func appendCommentVideos(videos []commentVideo, playList *model.YoutubePlayList) []commentVideo {
+	playList.Mux.Lock()
+	defer playList.Mux.Unlock()
+
+	for id, video := range playList.Videos {
+		if video.Deleted || video.CommentsDisabled ||
+			time.Since(video.PublishedAt) > config.PeriodCollectComment.Get() ||
+			time.Since(video.TimeComment) < config.PeriodComment.Get()/2 {
+			continue
+		}
+		videos = append(videos, commentVideo{id, video, playList})
+	}
+
+	return videos
+}
+
+// Вибірка коментарів відео, false - вичерпана квота
+func getVideoComments(v commentVideo) bool {
+	comments := make(map[string]*model.Comment)
+
+	// сторінка найпопулярніших коментарів: оновлюємо кількість лайків та відповідей
+	if !takeCommentQuota() {
+		return false
+	}
+	_, err := getCommentsPage(v, COMMENT_ORDER_RELEVANCE, "", comments)
+	if err != nil {
+		// повторний запит - тільки через periodComment, щоб помилки не витрачали квоту
+		v.playList.Mux.Lock()
+		v.video.TimeComment = time.Now()
+		v.playList.Mux.Unlock()
+		return true
+	}
+
+	// найновіші коментарі, до вже збережених
+	lastComment := v.video.LastComment
+	pageToken := ""
+	for page := 0; page < config.MaxCommentPages.Get(); page++ {
+		if !takeCommentQuota() {
+			break
+		}
+
+		response, err := getCommentsPage(v, COMMENT_ORDER_TIME, pageToken, comments)
+		if err != nil || response.NextPageToken == "" || reachedComment(response, lastComment) {
+			break
+		}
+		pageToken = response.NextPageToken
+	}
+
+	newest := lastComment
+	list := make([]*model.Comment, 0, len(comments))
+	for _, comment := range comments {
+		list = append(list, comment)
+		if comment.PublishedAt.After(newest) {
+			newest = comment.PublishedAt
+		}
+	}
+
+	if len(list) > 0 {
+		if err := database.AddComments(list); err != nil {
+			return true
+		}
+	}
+
+	v.playList.Mux.Lock()
+	v.video.TimeComment = time.Now()
+	v.video.LastComment = newest
+	v.playList.Mux.Unlock()
+
+	log.Infof("pl: %v, video: %v, comments saved: %v", v.playList.Id, v.id, len(list))
+	return true
+}
+
+// Запит сторінки коментарів, коментарі додаються в comments
+func getCommentsPage(v commentVideo, order, pageToken string, comments map[string]*model.Comment) (
+	*youtube.CommentThreadListResponse, error) {
+	call := service.CommentThreads.List(COMMENT_PART)
+	call = call.VideoId(v.id)
+	call = call.Order(order)
+	call = call.TextFormat(COMMENT_TEXT_FORMAT)
+	call = call.MaxResults(COMMENT_PAGE_SIZE)
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+
+	response, err := call.Do()
+	if err != nil {
+		if isCommentsDisabled(err) {
+			v.playList.Mux.Lock()
+			v.video.CommentsDisabled = true
+			v.playList.Mux.Unlock()
+			log.Infof("pl: %v, video: %v, comments are disabled", v.playList.Id, v.id)
+		} else {
+			log.Errorf("pl: %v, video: %v, error get comments: %v", v.playList.Id, v.id, err)
+		}
+		return nil, err
+	}
+
+	for _, thread := range response.Items {
+		if thread.Snippet == nil || thread.Snippet.TopLevelComment == nil ||
+			thread.Snippet.TopLevelComment.Snippet == nil {
+			continue
+		}
+		snippet := thread.Snippet.TopLevelComment.Snippet
+
+		publishedAt, err := time.Parse(time.RFC3339, snippet.PublishedAt)
+		if err != nil {
+			log.Errorf("pl: %v, video: %v, error parse comment PublishedAt %v", v.playList.Id, v.id,
+				snippet.PublishedAt)
+			continue
+		}
+
+		comments[thread.Id] = &model.Comment{
+			Id:          thread.Id,
+			VideoId:     v.id,
+			PublishedAt: publishedAt,
+			LikeCount:   snippet.LikeCount,
+			ReplyCount:  thread.Snippet.TotalReplyCount,
+			Text:        snippet.TextOriginal,
+		}
+	}
+
+	return response, nil
+}
+
+// Чи дійшли до вже збережених коментарів: сторінка містить коментар, не новіший за lastComment
+func reachedComment(response *youtube.CommentThreadListResponse, lastComment time.Time) bool {
+	if lastComment.IsZero() {
+		return false
+	}
+	for _, thread := range response.Items {
+		if thread.Snippet == nil || thread.Snippet.TopLevelComment == nil ||
+			thread.Snippet.TopLevelComment.Snippet == nil {
+			continue
+		}
+		publishedAt, err := time.Parse(time.RFC3339, thread.Snippet.TopLevelComment.Snippet.PublishedAt)
+		if err == nil && !publishedAt.After(lastComment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Чи вимкнені коментарі до відео (помилка 403 commentsDisabled)
+func isCommentsDisabled(err error) bool {
+	apiErr, ok := err.(*googleapi.Error)
+	if !ok {
+		return false
+	}
+	for _, item := range apiErr.Errors {
+		if item.Reason == COMMENTS_DISABLED {
+			return true
+		}
+	}
+	return false
+}
@@ -1,12 +1,13 @@
-package server
-
-import (
-	"github.com/AleksandrKuts/youtubemeter-service/collector/config"
-	"go.uber.org/zap"
-)
-
-var log *zap.SugaredLogger
-
-func init() {
-	log = config.Logger
-}
+package server
+
+import (
+	"github.com/AleksandrKuts/youtubemeter-service/collector/config"
+	"go.uber.org/zap"
+)
+
+var log *zap.SugaredLogger
+
+// Підготувати пакет до роботи після завантаження налаштувань (config.Load). Викликається з StartService
+func setup() {
+	log = config.Logger
+}
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"github.com/AleksandrKuts/youtubemeter-service/collector/config"
 	"github.com/AleksandrKuts/youtubemeter-service/collector/server/model"
+	"github.com/AleksandrKuts/youtubemeter-service/migrations"
+	"github.com/AleksandrKuts/youtubemeter-service/settings"
 	"github.com/lib/pq"
 	"go.uber.org/zap"
+	"os"
 	"strings"
 	"time"
 )
@@ -15,42 +18,101 @@ import (
 // timestamp with time zone;
 const TIME_LAYOUT = "2006-01-02T15:04:05.999999-07:00"
 
-const GET_PLAYLISTS = "SELECT pl.id FROM playlist pl WHERE pl.enable = true"
+const GET_PLAYLISTS = "SELECT pl.id, pl.periodcollect, pl.periodmetric, pl.periodsavemetricidle, pl.maxrequestvideos, " +
+	"TRIM(pl.idch) FROM playlist pl WHERE pl.enable = true AND pl.timearchive IS NULL"
 
-const GET_PLAYLISTS_WITH_VIDEO = "SELECT pl.id, v.id as vid, v.publishedat, TRIM(v.title) " +
+// Термін збору метрик береться з налаштувань плейлиста, якщо він не заданий - глобальний ($1, в секундах)
+const GET_PLAYLISTS_WITH_VIDEO = "SELECT pl.id, pl.periodcollect, pl.periodmetric, pl.periodsavemetricidle, " +
+	"pl.maxrequestvideos, TRIM(pl.idch), v.id as vid, v.publishedat, TRIM(v.title) " +
 	"FROM playlist pl " +
-	"LEFT JOIN video v ON v.idpl = pl.id AND v.publishedat > $1 " +
-	"WHERE pl.enable = true " +
+	"LEFT JOIN video v ON v.idpl = pl.id " +
+	"AND v.publishedat > now() - make_interval(secs => COALESCE(pl.periodcollect, $1)) " +
+	"WHERE pl.enable = true AND pl.timearchive IS NULL " +
 	"ORDER BY pl.id"
 
+// Відео, яке раніше знайшов запит (idpl = NULL), переходить до плейлиста
 const INSERT_VIDEO = "INSERT INTO video ( id, idpl, publishedat, title, description, chid, chtitle ) " +
 	"VALUES ( $1, $2, $3, $4, $5, $6, $7 ) " +
-	"ON CONFLICT (id) DO UPDATE SET " +
+	"ON CONFLICT (id) DO UPDATE SET idpl = COALESCE(video.idpl, EXCLUDED.idpl), " +
 	"publishedat = EXCLUDED.publishedat, title = EXCLUDED.title, description = EXCLUDED.description, " +
 	"chid = EXCLUDED.chid, chtitle = EXCLUDED.chtitle"
 
+// Відео, знайдене запитом. Якщо відео вже належить плейлисту, метрики по ньому збирає плейлист
+const INSERT_QUERY_VIDEO = "INSERT INTO video ( id, idquery, publishedat, title, description, chid, chtitle ) " +
+	"VALUES ( $1, $2, $3, $4, $5, $6, $7 ) " +
+	"ON CONFLICT (id) DO UPDATE SET idquery = COALESCE(video.idquery, EXCLUDED.idquery) " +
+	"RETURNING idpl IS NOT NULL"
+
+const GET_QUERIES = "SELECT q.id, q.kind, q.query, COALESCE(q.regioncode, ''), q.maxresults, q.periodquery " +
+	"FROM query q WHERE q.enable = true"
+
+// Відео запитів, які не належать плейлистам, в межах терміну збору метрик ($1, в секундах)
+const GET_QUERY_VIDEOS = "SELECT v.idquery, v.id, v.publishedat, TRIM(v.title) FROM video v " +
+	"JOIN query q ON q.id = v.idquery AND q.enable = true " +
+	"WHERE v.idpl IS NULL AND v.publishedat > now() - make_interval(secs => $1)"
+
+// Коментар, який вже збережений, оновлює тільки кількість лайків та відповідей
+const INSERT_COMMENT = "INSERT INTO comment ( id, idvideo, publishedat, likecount, replycount, text ) " +
+	"VALUES ( $1, $2, $3, $4, $5, $6 ) " +
+	"ON CONFLICT (id) DO UPDATE SET likecount = EXCLUDED.likecount, replycount = EXCLUDED.replycount, " +
+	"timeupdate = now()"
+
 const UPDATE_VIDEO = "UPDATE video SET title = $1 WHERE id = $2"
 
 const INSERT_METRICS = "INSERT INTO metric ( idVideo, CommentCount, LikeCount, DislikeCount, ViewCount ) " +
 	"VALUES ( $1, $2, $3, $4, $5 )"
 
+// Зведення метрик. Перераховуються інтервали, починаючи з останнього вже зведеного (з запасом в годину на метрики,
+// збережені із запізненням), тому повторний запуск безпечний
+const ROLLUP_METRIC_HOURLY = "INSERT INTO metric_hourly SELECT idvideo, date_trunc('hour', timemetric), COUNT(*), " +
+	"MIN(timemetric), MAX(timemetric), " +
+	"(array_agg(commentcount ORDER BY timemetric))[1], (array_agg(commentcount ORDER BY timemetric DESC))[1], MIN(commentcount), MAX(commentcount), " +
+	"(array_agg(likecount ORDER BY timemetric))[1], (array_agg(likecount ORDER BY timemetric DESC))[1], MIN(likecount), MAX(likecount), " +
+	"(array_agg(dislikecount ORDER BY timemetric))[1], (array_agg(dislikecount ORDER BY timemetric DESC))[1], MIN(dislikecount), MAX(dislikecount), " +
+	"(array_agg(viewcount ORDER BY timemetric))[1], (array_agg(viewcount ORDER BY timemetric DESC))[1], MIN(viewcount), MAX(viewcount) " +
+	"FROM metric WHERE timemetric >= COALESCE((SELECT MAX(bucket) FROM metric_hourly) - interval '1 hour', '-infinity') " +
+	"GROUP BY idvideo, date_trunc('hour', timemetric) " +
+	"ON CONFLICT (idvideo, bucket) DO UPDATE SET " + ROLLUP_UPDATE
+
+const ROLLUP_METRIC_DAILY = "INSERT INTO metric_daily SELECT idvideo, date_trunc('day', bucket), SUM(samples), " +
+	"MIN(timefirst), MAX(timelast), " +
+	"(array_agg(commentfirst ORDER BY bucket))[1], (array_agg(commentlast ORDER BY bucket DESC))[1], MIN(commentmin), MAX(commentmax), " +
+	"(array_agg(likefirst ORDER BY bucket))[1], (array_agg(likelast ORDER BY bucket DESC))[1], MIN(likemin), MAX(likemax), " +
+	"(array_agg(dislikefirst ORDER BY bucket))[1], (array_agg(dislikelast ORDER BY bucket DESC))[1], MIN(dislikemin), MAX(dislikemax), " +
+	"(array_agg(viewfirst ORDER BY bucket))[1], (array_agg(viewlast ORDER BY bucket DESC))[1], MIN(viewmin), MAX(viewmax) " +
+	"FROM metric_hourly WHERE bucket >= COALESCE((SELECT MAX(bucket) FROM metric_daily), '-infinity') " +
+	"GROUP BY idvideo, date_trunc('day', bucket) " +
+	"ON CONFLICT (idvideo, bucket) DO UPDATE SET " + ROLLUP_UPDATE
+
+const ROLLUP_UPDATE = "samples = EXCLUDED.samples, timefirst = EXCLUDED.timefirst, timelast = EXCLUDED.timelast, " +
+	"commentfirst = EXCLUDED.commentfirst, commentlast = EXCLUDED.commentlast, commentmin = EXCLUDED.commentmin, commentmax = EXCLUDED.commentmax, " +
+	"likefirst = EXCLUDED.likefirst, likelast = EXCLUDED.likelast, likemin = EXCLUDED.likemin, likemax = EXCLUDED.likemax, " +
+	"dislikefirst = EXCLUDED.dislikefirst, dislikelast = EXCLUDED.dislikelast, dislikemin = EXCLUDED.dislikemin, dislikemax = EXCLUDED.dislikemax, " +
+	"viewfirst = EXCLUDED.viewfirst, viewlast = EXCLUDED.viewlast, viewmin = EXCLUDED.viewmin, viewmax = EXCLUDED.viewmax"
+
+// Видалити сирі метрики відео, збір метрик яких закінчився більше ніж $2 секунд тому ($1 - periodCollect за
+// замовчуванням, в секундах). Видаляються тільки вже зведені погодинні інтервали
+const DELETE_RAW_METRICS = "DELETE FROM metric m USING video v LEFT JOIN playlist p ON p.id = v.idpl " +
+	"WHERE m.idvideo = v.id " +
+	"AND v.publishedat + make_interval(secs => COALESCE(p.periodcollect, $1) + $2) < now() " +
+	"AND m.timemetric < (SELECT MAX(bucket) FROM metric_hourly) - interval '1 hour'"
+
+const REFRESH_VIDEO_GAIN = "SELECT refresh_video_gain()"
+
+const CREATE_METRIC_PARTITIONS = "SELECT create_metric_partitions($1)"
+const ARCHIVE_METRIC_PARTITIONS = "SELECT archive_metric_partitions($1)"
+
 var db *sql.DB
 var errDB error
 var log *zap.SugaredLogger
 
-func init() {
+// Відкрити з'єднання з БД. Викликається з main після завантаження налаштувань (config.Load)
+func Open() {
 	log = config.Logger
 
-	// creat connections string
-	// example: host=127.0.0.100 port=5432 dbname=base1 user=user1 password=lalala sslmode=disable"
-	connStrForDatabse := "host=" + *config.DBHost +
-		" port=" + *config.DBPort +
-		" dbname=" + *config.DBName +
-		" user=" + *config.DBUser +
-		" password=" + *config.DBPassword +
-		" sslmode=" + *config.DBSSLMode
+	connStrForDatabse := config.DSN()
 
-	log.Debugf("connStr=%s", connStrForDatabse)
+	log.Debugf("connStr=%s", settings.Redact(connStrForDatabse))
 
 	db, errDB = sql.Open("postgres", connStrForDatabse)
 	if errDB != nil {
@@ -74,15 +136,31 @@ func closeDB() {
 	}
 }
 
+// Перевірити версію схеми БД, зі схемою іншої версії колектор не працює
+func CheckSchema() error {
+	if err := migrations.Check(db); err != nil {
+		return err
+	}
+	log.Infof("database schema version: %v", migrations.Latest())
+	return nil
+}
+
+// Виконати команду міграції схеми БД (up, down, status): collector --migrate=<команда>
+func Migrate(command string) error {
+	defer closeDB()
+
+	log.Warnf("migrate database schema: %v", command)
+	return migrations.Run(db, command, os.Stdout)
+}
+
 // Отримати массив ID списків відтворення та відео з БД 
 func GetPlaylistWithVideo() (model.YoutubePlayLists, error) {
 	log.Debugf("dbstats=%v", db.Stats())
-	maxTimePublished := time.Now().Add(-*config.PeriodСollection)
-	log.Debugf("get playlists with videos, maxTimePublished: %v", maxTimePublished)
+	log.Debugf("get playlists with videos, periodCollection: %v", config.PeriodСollection.Get())
 
 	var playlists model.YoutubePlayLists = model.YoutubePlayLists{Playlists: make(map[string]*model.YoutubePlayList)}
 
-	rows, err := db.Query(GET_PLAYLISTS_WITH_VIDEO, maxTimePublished)
+	rows, err := db.Query(GET_PLAYLISTS_WITH_VIDEO, int64(config.PeriodСollection.Get()/time.Second))
 	if err != nil {
 		log.Errorf("Error get playlists: %v", err)
 		return playlists, err
@@ -92,23 +170,31 @@ func GetPlaylistWithVideo() (model.YoutubePlayLists, error) {
 	pl := ""
 	for rows.Next() {
 		var id string
-		var videoId string
-		var publishedat time.Time
-		var title string
-
-		rows.Scan(&id, &videoId, &publishedat, &title)
-		log.Debugf("pl: %v, video: %v, publishedat: %v, title: %v", id, videoId, publishedat, title)
+		var periodCollect, periodMeter, periodCount, maxRequestVideos sql.NullInt64
+		var channelId string
+		// для плейлиста без відео поля відео - NULL
+		var videoId, title sql.NullString
+		var publishedat sql.NullTime
+
+		err = rows.Scan(&id, &periodCollect, &periodMeter, &periodCount, &maxRequestVideos, &channelId, &videoId,
+			&publishedat, &title)
+		if err != nil {
+			break
+		}
+		log.Debugf("pl: %v, video: %v, publishedat: %v, title: %v", id, videoId.String, publishedat.Time, title.String)
 
 		if pl != id {
-			playlists.Append(id)
+			playlists.Append(id, settingsFromDB(periodCollect, periodMeter, periodCount, maxRequestVideos, channelId))
 			pl = id
 		}
-		if videoId != "" {
-			playlists.Playlists[id].Append(videoId, &model.YoutubeVideo{PublishedAt: publishedat, 
-					Deleted: false, Title: title})
+		if videoId.Valid {
+			playlists.Playlists[id].Append(videoId.String, &model.YoutubeVideo{PublishedAt: publishedat.Time, 
+					Deleted: false, Title: title.String})
 		}
 	}
-	err = rows.Err()
+	if err == nil {
+		err = rows.Err()
+	}
 	if err != nil {
 		log.Error(err)
 		return playlists, err
@@ -117,8 +203,20 @@ func GetPlaylistWithVideo() (model.YoutubePlayLists, error) {
 	return playlists, nil
 }
 
-// Отримати массив ID списків відтворення
-func GetPlaylistIDs() (map[string]bool, error) {
+// Перетворення налаштувань плейлиста з БД, періоди в БД зберігаються в секундах, NULL - налаштування не задане
+func settingsFromDB(periodCollect, periodMeter, periodCount, maxRequestVideos sql.NullInt64,
+	channelId string) model.PlayListSettings {
+	return model.PlayListSettings{
+		PeriodCollection: time.Duration(periodCollect.Int64) * time.Second,
+		PeriodMeter:      time.Duration(periodMeter.Int64) * time.Second,
+		PeriodCount:      time.Duration(periodCount.Int64) * time.Second,
+		MaxRequestVideos: maxRequestVideos.Int64,
+		ChannelId:        channelId,
+	}
+}
+
+// Отримати массив ID списків відтворення з їх індивідуальними налаштуваннями
+func GetPlaylistIDs() (map[string]model.PlayListSettings, error) {
 	log.Debugf("dbstats=%v", db.Stats())
 
 	rows, err := db.Query(GET_PLAYLISTS)
@@ -128,15 +226,21 @@ func GetPlaylistIDs() (map[string]bool, error) {
 	}
 	defer rows.Close()
 
-	response := make(map[string]bool)
+	response := make(map[string]model.PlayListSettings)
 
 	for rows.Next() {
 		var Id string
+		var periodCollect, periodMeter, periodCount, maxRequestVideos sql.NullInt64
+		var channelId string
 
-		rows.Scan(&Id)
+		err = rows.Scan(&Id, &periodCollect, &periodMeter, &periodCount, &maxRequestVideos, &channelId)
+		if err != nil {
+			log.Errorf("Error get playlists: %v", err)
+			return nil, err
+		}
 		Id = strings.TrimSpace(Id)
 
-		response[Id] = true
+		response[Id] = settingsFromDB(periodCollect, periodMeter, periodCount, maxRequestVideos, channelId)
 	}
 	err = rows.Err()
 	if err != nil {
@@ -243,3 +347,270 @@ func AddMetric(metrics []*model.Metrics) error {
 
 	return nil
 }
+
+
+// Оновити погодинні та щоденні зведення метрик
+func RollupMetrics() error {
+	log.Debugf("dbstats=%v", db.Stats())
+
+	result, err := db.Exec(ROLLUP_METRIC_HOURLY)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+	hourly, _ := result.RowsAffected()
+
+	result, err = db.Exec(ROLLUP_METRIC_DAILY)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+	daily, _ := result.RowsAffected()
+
+	log.Infof("metrics rolled up, hourly: %v, daily: %v", hourly, daily)
+	return nil
+}
+
+// Перерахувати приріст метрик відео за ковзні вікна (таблиця video_gain), повертає кількість рядків зведення
+func RefreshVideoGain() (int, error) {
+	log.Debugf("dbstats=%v", db.Stats())
+
+	var count int
+	err := db.QueryRow(REFRESH_VIDEO_GAIN).Scan(&count)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Видалити сирі метрики відео, збір метрик яких закінчився більше ніж keep тому
+func DeleteRawMetrics(periodCollection, keep time.Duration) (int64, error) {
+	log.Debugf("delete raw metrics, periodCollection: %v, keep: %v", periodCollection, keep)
+
+	result, err := db.Exec(DELETE_RAW_METRICS, int64(periodCollection.Seconds()), int64(keep.Seconds()))
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Створити місячні секції таблиці metric на ahead місяців вперед, повертає кількість створених секцій
+func CreateMetricPartitions(ahead int) (int, error) {
+	log.Debugf("create metric partitions, ahead: %v", ahead)
+
+	var count int
+	err := db.QueryRow(CREATE_METRIC_PARTITIONS, ahead).Scan(&count)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Від'єднати секції таблиці metric з вимірами, старішими за before, та перенести їх в схему metric_archive.
+// Повертає назви перенесених секцій
+func ArchiveMetricPartitions(before time.Time) ([]string, error) {
+	log.Debugf("archive metric partitions, before: %v", before)
+
+	rows, err := db.Query(ARCHIVE_METRIC_PARTITIONS, before)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			log.Errorf("err=%v", err)
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); err != nil {
+		log.Errorf("err=%v", err)
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// Отримати активні запити з їх параметрами
+func GetQueries() (map[int64]model.QueryParams, error) {
+	log.Debugf("dbstats=%v", db.Stats())
+
+	rows, err := db.Query(GET_QUERIES)
+	if err != nil {
+		log.Errorf("Error get queries: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := make(map[int64]model.QueryParams)
+
+	for rows.Next() {
+		var id int64
+		var params model.QueryParams
+		var maxResults, periodQuery sql.NullInt64
+
+		err = rows.Scan(&id, &params.Kind, &params.Query, &params.RegionCode, &maxResults, &periodQuery)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		params.RegionCode = strings.TrimSpace(params.RegionCode)
+		params.MaxResults = maxResults.Int64
+		params.PeriodQuery = time.Duration(periodQuery.Int64) * time.Second
+
+		response[id] = params
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Отримати відео запитів, для яких ще збираються метрики: id запиту -> id відео -> відео
+func GetQueryVideos() (map[int64]map[string]*model.YoutubeVideo, error) {
+	rows, err := db.Query(GET_QUERY_VIDEOS, int64(config.PeriodСollection.Get()/time.Second))
+	if err != nil {
+		log.Errorf("Error get query videos: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	response := make(map[int64]map[string]*model.YoutubeVideo)
+
+	for rows.Next() {
+		var idquery int64
+		var videoId string
+		var publishedat time.Time
+		var title string
+
+		err = rows.Scan(&idquery, &videoId, &publishedat, &title)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+
+		if response[idquery] == nil {
+			response[idquery] = make(map[string]*model.YoutubeVideo)
+		}
+		response[idquery][videoId] = &model.YoutubeVideo{PublishedAt: publishedat, Title: title}
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Додати відео, знайдене запитом. Повертає true, якщо відео вже належить плейлисту
+func AddQueryVideo(id string, idquery int64, publishedat time.Time, title, description, channelId,
+	channelTitle string) (bool, error) {
+	if id == "" {
+		return false, errors.New("Error add video, id is null")
+	}
+
+	var inPlayList bool
+	err := db.QueryRow(INSERT_QUERY_VIDEO, id, idquery, publishedat, title, description, channelId,
+		channelTitle).Scan(&inPlayList)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return false, err
+	}
+
+	log.Debugf("insert video: id=%v, idquery=%v, publishedat=%v, title=%v, in playlist: %v", id, idquery,
+		publishedat, title, inPlayList)
+
+	return inPlayList, nil
+}
+
+// Додати позиції відео в результатах запиту
+func AddQueryRanks(idquery int64, ranks []*model.QueryRank) error {
+	txn, err := db.Begin()
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+	defer txn.Rollback()
+
+	stmt, err := txn.Prepare(pq.CopyIn("queryrank", "idquery", "idvideo", "rank", "timerank"))
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+
+	for _, rank := range ranks {
+		_, err = stmt.Exec(idquery, rank.VideoId, rank.Rank, rank.Time)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+	}
+
+	_, err = stmt.Exec()
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+
+	err = stmt.Close()
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+
+	err = txn.Commit()
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+
+	return nil
+}
+
+// Додати коментарі, вже збережені коментарі оновлюються
+func AddComments(comments []*model.Comment) error {
+	txn, err := db.Begin()
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+	defer txn.Rollback()
+
+	stmt, err := txn.Prepare(INSERT_COMMENT)
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+	defer stmt.Close()
+
+	for _, comment := range comments {
+		_, err = stmt.Exec(comment.Id, comment.VideoId, comment.PublishedAt, comment.LikeCount, comment.ReplyCount,
+			comment.Text)
+		if err != nil {
+			log.Errorf("err=%v", err)
+			return err
+		}
+	}
+
+	err = txn.Commit()
+	if err != nil {
+		log.Errorf("err=%v", err)
+		return err
+	}
+
+	return nil
+}
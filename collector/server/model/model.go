@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strconv"
 	"sync"
 	"time"
 )
@@ -36,7 +37,15 @@ type YoutubeVideo struct {
 	
 	// Time elapsed since deleted video
 	TimeDeleted time.Time
-			
+
+	// Час останнього запиту коментарів
+	TimeComment time.Time
+
+	// Час публікації найновішого збереженого коментаря, старіші коментарі вже збережені
+	LastComment time.Time
+
+	// Коментарі до відео вимкнені, запитувати їх не потрібно
+	CommentsDisabled bool
 }
 
 func (video *YoutubeVideo) SetMetrics(CommentCount, LikeCount, DislikeCount, ViewCount uint64) {
@@ -47,11 +56,36 @@ func (video *YoutubeVideo) SetMetrics(CommentCount, LikeCount, DislikeCount, Vie
 	video.TimeCount = time.Now()
 }
 
+// Індивідуальні налаштування збору метрик плейлиста. Нульове значення - використовуються глобальні налаштування
+type PlayListSettings struct {
+	// Термін збору метрик для відео, рахується з часу опублікування відео
+	PeriodCollection time.Duration
+
+	// Періодичність отримання метрик відео
+	PeriodMeter time.Duration
+
+	// Періодичність збереження метрик відео в БД навіть якщо жодна з них не змінились
+	PeriodCount time.Duration
+
+	// Максимальна кількість відео в запиті до плейлиста
+	MaxRequestVideos int64
+
+	// ID каналу плейлиста, використовується для підписки на сповіщення про нові відео (WebSub)
+	ChannelId string
+}
+
 type YoutubePlayList struct {
 	Id string
 	
 	// Video: A list video resource represents a YouTube video.
 	Videos map[string]*YoutubeVideo
+
+	// Індивідуальні налаштування збору метрик. Змінюються під блокуванням списку плейлистів, тому читаються
+	// через YoutubePlayLists.GetSettings
+	Settings PlayListSettings
+
+	// Час останнього запиту метрик по плейлисту
+	TimeMeter time.Time
 	
 	// is deleted or deactivated
 	Deleted bool
@@ -97,11 +131,107 @@ func (playlists *YoutubePlayLists) Delete(id string) {
 	delete(playlists.Playlists, id)	
 }
 
-func (playlists *YoutubePlayLists) Append(id string) {	
-	v := YoutubePlayList{Videos: make(map[string]*YoutubeVideo), Deleted: false, Id: id }
+// Індивідуальні налаштування плейлиста, читаються під тим же блокуванням, під яким змінюються
+func (playlists *YoutubePlayLists) GetSettings(playlist *YoutubePlayList) PlayListSettings {
+	playlists.Mux.Lock()
+	defer playlists.Mux.Unlock()
+
+	return playlist.Settings
+}
+
+func (playlists *YoutubePlayLists) Append(id string, settings PlayListSettings) {	
+	v := YoutubePlayList{Videos: make(map[string]*YoutubeVideo), Deleted: false, Id: id, Settings: settings }
 	playlists.Playlists[id] = &v  	
 }
 
+// Види запитів, результати яких відстежуються
+const (
+	// Пошук відео за ключовими словами (search.list)
+	QUERY_SEARCH = "search"
+
+	// Чарт популярних відео регіону (videos.list chart=mostPopular)
+	QUERY_TRENDING = "trending"
+)
+
+// Параметри запиту. Нульове значення MaxResults, PeriodQuery - використовуються глобальні налаштування
+type QueryParams struct {
+	// Вид запиту: QUERY_SEARCH або QUERY_TRENDING
+	Kind string
+
+	// Ключові слова для пошуку
+	Query string
+
+	// Код регіону (ISO 3166-1 alpha-2)
+	RegionCode string
+
+	// Кількість результатів запиту
+	MaxResults int64
+
+	// Періодичність виконання запиту
+	PeriodQuery time.Duration
+}
+
+// Запит, результати якого відстежуються. Знайдені запитом відео обробляються як відео плейлиста,
+// Id плейлиста має вигляд "query:<id запиту>" і використовується тільки для логування
+type YoutubeQuery struct {
+	*YoutubePlayList
+
+	QueryId int64
+
+	Params QueryParams
+
+	// Час останнього виконання запиту
+	TimeQuery time.Time
+}
+
+type YoutubeQueries struct {
+	// Словник посилань на запити
+	Queries map[int64]*YoutubeQuery
+	Mux sync.Mutex
+}
+
+func (queries *YoutubeQueries) Append(id int64, params QueryParams) *YoutubeQuery {
+	q := &YoutubeQuery{
+		YoutubePlayList: &YoutubePlayList{Videos: make(map[string]*YoutubeVideo), Id: "query:" + strconv.FormatInt(id, 10)},
+		QueryId:         id,
+		Params:          params,
+	}
+	queries.Queries[id] = q
+	return q
+}
+
+func (queries *YoutubeQueries) Delete(id int64) {
+	delete(queries.Queries, id)
+}
+
+// Позиція відео в результатах запиту
+type QueryRank struct {
+	VideoId string
+
+	// Позиція, починаючи з 1
+	Rank int
+
+	// Час виконання запиту
+	Time time.Time
+}
+
+// Коментар верхнього рівня (гілка коментарів)
+type Comment struct {
+	// Id гілки коментарів
+	Id string
+
+	VideoId string
+
+	PublishedAt time.Time
+
+	LikeCount int64
+
+	// Кількість відповідей на коментар
+	ReplyCount int64
+
+	Text string
+}
+
 // Video: A video resource represents a YouTube video.
 type Metrics struct {
 	//The id parameter specifies a comma-separated list of the YouTube video ID(s)
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"time"
+
+	"github.com/AleksandrKuts/youtubemeter-service/collector/config"
+	"github.com/AleksandrKuts/youtubemeter-service/collector/server/database"
+)
+
+// Створити секції таблиці metric на metricPartitionsAhead місяців вперед та перенести в архів секції,
+// старші за archiveMetric
+func partitionMetrics() {
+	count, err := database.CreateMetricPartitions(config.MetricPartitionsAhead.Get())
+	if err != nil {
+		log.Errorf("metric partitions are not created, err=%v", err)
+	} else if count > 0 {
+		log.Infof("metric partitions created: %v", count)
+	}
+
+	if config.ArchiveMetric.Get() == 0 {
+		return
+	}
+
+	names, err := database.ArchiveMetricPartitions(time.Now().Add(-config.ArchiveMetric.Get()))
+	if err != nil {
+		log.Errorf("metric partitions are not archived, err=%v", err)
+		return
+	}
+	if len(names) > 0 {
+		log.Warnf("metric partitions moved to metric_archive: %v", names)
+	}
+}
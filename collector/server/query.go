@@ -0,0 +1,345 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/AleksandrKuts/youtubemeter-service/collector/config"
+	"github.com/AleksandrKuts/youtubemeter-service/collector/server/database"
+	"github.com/AleksandrKuts/youtubemeter-service/collector/server/model"
+)
+
+// Відстеження результатів запитів: пошук відео за ключовими словами та чарт популярних відео регіону.
+// Кожен запит виконується з періодичністю config.PeriodQuery (або індивідуальною), позиції знайдених відео
+// зберігаються в БД, а самі відео додаються до збору метрик так само, як відео плейлистів. Пошук коштує
+// SEARCH_QUOTA_COST одиниць квоти YouTube API, загальна вартість пошуків за добу обмежена config.SearchQuota
+
+const SEARCH_PART = "id,snippet"
+const SEARCH_TYPE_VIDEO = "video"
+const CHART_MOST_POPULAR = "mostPopular"
+
+// Вартість одного запиту search.list в одиницях квоти YouTube API
+const SEARCH_QUOTA_COST = 100
+
+// Використана квота пошуку за поточну добу
+var searchQuota = struct {
+	sync.Mutex
+	start time.Time
+	used  int
+}{}
+
+// Список запитів для відстеження. Список корегується разом зі списком плейлистів (config.PeriodPlayList)
+var queries = model.YoutubeQueries{Queries: make(map[int64]*model.YoutubeQuery)}
+
+// Заповнюємо список запитів та їх відео з БД
+func initQueries() {
+	log.Debug("init queries")
+
+	checkQueries()
+
+	videos, err := database.GetQueryVideos()
+	if err != nil {
+		log.Errorf("Error get query videos from DB: %v", err)
+		return
+	}
+
+	queries.Mux.Lock()
+	defer queries.Mux.Unlock()
+
+	for id, query := range queries.Queries {
+		for videoId, video := range videos[id] {
+			query.Append(videoId, video)
+		}
+		log.Infof("pl: %v, count videos: %v", query.Id, len(query.Videos))
+	}
+}
+
+// Перевіряємо список запитів, чи додав адміністратор нові, чи видалив, чи деактивував, чи змінив параметри
+func checkQueries() {
+	log.Debug("check queries")
+
+	params, err := database.GetQueries()
+	if err != nil {
+		log.Errorf("Error get queries: %v", err)
+		return
+	}
+
+	queries.Mux.Lock()
+	defer queries.Mux.Unlock()
+
+	// Перевіряємо список на видалення чи деактивування, як і для плейлистів спочатку запит помічається
+	// на видалення, а через config.PeriodDeleted видаляється остаточно
+	for id, query := range queries.Queries {
+		p, ok := params[id]
+		if !ok {
+			if !query.Deleted {
+				query.Deleted = true
+				query.TimeDeleted = time.Now()
+				log.Debugf("pl: %v, set stop processing query", query.Id)
+			} else if time.Since(query.TimeDeleted) > config.PeriodDeleted.Get() {
+				queries.Delete(id)
+				log.Infof("pl: %v, stop processing query", query.Id)
+			}
+			continue
+		}
+
+		if query.Deleted {
+			query.Deleted = false
+			log.Debugf("pl: %v, cansel stop processing query", query.Id)
+		}
+
+		if query.Params != p {
+			query.Mux.Lock()
+			query.Params = p
+			query.Mux.Unlock()
+			log.Infof("pl: %v, update query: %+v", query.Id, p)
+		}
+	}
+
+	// Перевіряємо список на додавання нових запитів
+	for id, p := range params {
+		if _, ok := queries.Queries[id]; !ok {
+			query := queries.Append(id, p)
+			log.Infof("pl: %v, append query: %+v", query.Id, p)
+		}
+	}
+}
+
+// Отримати тимчасовий список запитів, запити помічені на видалення ігноруються
+func getRequestQueries() []*model.YoutubeQuery {
+	requestQueries := []*model.YoutubeQuery{}
+
+	queries.Mux.Lock()
+	defer queries.Mux.Unlock()
+	for _, query := range queries.Queries {
+		if !query.Deleted {
+			requestQueries = append(requestQueries, query)
+		}
+	}
+
+	return requestQueries
+}
+
+// Періодичність виконання запиту: індивідуальна, якщо задана, інакше глобальна
+func periodQuery(query *model.YoutubeQuery) time.Duration {
+	if query.Params.PeriodQuery > 0 {
+		return query.Params.PeriodQuery
+	}
+	return config.PeriodQuery.Get()
+}
+
+// Кількість результатів запиту: індивідуальна, якщо задана, інакше глобальна
+func maxQueryResults(query *model.YoutubeQuery) int64 {
+	if query.Params.MaxResults > 0 {
+		return query.Params.MaxResults
+	}
+	return config.MaxQueryResults.Get()
+}
+
+// Взяти з квоти один пошук, false - квота на поточну добу вичерпана
+func takeSearchQuota() bool {
+	searchQuota.Lock()
+	defer searchQuota.Unlock()
+
+	if time.Since(searchQuota.start) >= time.Hour*24 {
+		searchQuota.start = time.Now()
+		searchQuota.used = 0
+	}
+	if searchQuota.used+SEARCH_QUOTA_COST > config.SearchQuota.Get() {
+		return false
+	}
+	searchQuota.used += SEARCH_QUOTA_COST
+
+	return true
+}
+
+// Виконуємо запити, для яких настав час. Запити робляться по таймеру config.PeriodQuery, тому індивідуальна
+// періодичність не може бути меншою за нього, половина періоду таймера - допуск на нерівномірність спрацювання
+func runQueries() {
+	log.Debug("run queries start")
+
+	for _, query := range getRequestQueries() {
+		if time.Since(query.TimeQuery) < periodQuery(query)-config.PeriodQuery.Get()/2 {
+			log.Debugf("pl: %v, SKIP - period query: %v", query.Id, periodQuery(query))
+			continue
+		}
+		query.TimeQuery = time.Now()
+
+		go runQuery(query)
+	}
+
+	log.Debug("run queries end")
+}
+
+// Виконати запит: зберегти позиції знайдених відео та додати нові відео для збору метрик
+func runQuery(query *model.YoutubeQuery) {
+	// перевіряємо запит на застаріле відео яке вже не потрібно обробляти
+	if len(query.Videos) > 0 {
+		checkElapsedVideos(query.YoutubePlayList)
+	}
+
+	var items []*youtube.PlaylistItem
+	var err error
+
+	switch query.Params.Kind {
+	case model.QUERY_SEARCH:
+		if !takeSearchQuota() {
+			log.Warnf("pl: %v, search quota %v is exhausted, skip query", query.Id, config.SearchQuota.Get())
+			return
+		}
+		items, err = searchVideos(query)
+	case model.QUERY_TRENDING:
+		items, err = trendingVideos(query)
+	default:
+		log.Errorf("pl: %v, unknown query kind: %v", query.Id, query.Params.Kind)
+		return
+	}
+	if err != nil {
+		log.Errorf("pl: %v, error run query: %v", query.Id, err)
+		return
+	}
+
+	timeRank := time.Now()
+	ranks := make([]*model.QueryRank, 0, len(items))
+	for i, item := range items {
+		ranks = append(ranks, &model.QueryRank{VideoId: item.ContentDetails.VideoId, Rank: i + 1, Time: timeRank})
+	}
+
+	if len(ranks) > 0 {
+		if err := database.AddQueryRanks(query.QueryId, ranks); err != nil {
+			return
+		}
+	}
+
+	for _, item := range items {
+		videoId := item.ContentDetails.VideoId
+		if !hasVideo(query.YoutubePlayList, videoId) && !isPlayListVideo(videoId) {
+			addQueryVideo(query, videoId, item)
+		}
+	}
+
+	log.Infof("pl: %v, query results: %v, count videos: %v", query.Id, len(items), len(query.Videos))
+}
+
+// Пошук відео за ключовими словами, результати в порядку релевантності
+func searchVideos(query *model.YoutubeQuery) ([]*youtube.PlaylistItem, error) {
+	call := service.Search.List(SEARCH_PART)
+	call = call.Q(query.Params.Query)
+	call = call.Type(SEARCH_TYPE_VIDEO)
+	call = call.MaxResults(maxQueryResults(query))
+	if query.Params.RegionCode != "" {
+		call = call.RegionCode(query.Params.RegionCode)
+	}
+
+	response, err := call.Do()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*youtube.PlaylistItem{}
+	for _, result := range response.Items {
+		if result.Id == nil || result.Id.VideoId == "" || result.Snippet == nil {
+			continue
+		}
+		items = append(items, &youtube.PlaylistItem{
+			ContentDetails: &youtube.PlaylistItemContentDetails{VideoId: result.Id.VideoId},
+			Snippet: &youtube.PlaylistItemSnippet{
+				Title:        result.Snippet.Title,
+				PublishedAt:  result.Snippet.PublishedAt,
+				Description:  result.Snippet.Description,
+				ChannelId:    result.Snippet.ChannelId,
+				ChannelTitle: result.Snippet.ChannelTitle,
+			},
+		})
+	}
+
+	return items, nil
+}
+
+// Чарт популярних відео регіону, результати в порядку позиції в чарті
+func trendingVideos(query *model.YoutubeQuery) ([]*youtube.PlaylistItem, error) {
+	call := service.Videos.List(VIDEO_SNIPPET_PART)
+	call = call.Chart(CHART_MOST_POPULAR)
+	call = call.RegionCode(query.Params.RegionCode)
+	call = call.MaxResults(maxQueryResults(query))
+
+	response, err := call.Do()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*youtube.PlaylistItem{}
+	for _, video := range response.Items {
+		if video.Snippet == nil {
+			continue
+		}
+		items = append(items, &youtube.PlaylistItem{
+			ContentDetails: &youtube.PlaylistItemContentDetails{VideoId: video.Id},
+			Snippet: &youtube.PlaylistItemSnippet{
+				Title:        video.Snippet.Title,
+				PublishedAt:  video.Snippet.PublishedAt,
+				Description:  video.Snippet.Description,
+				ChannelId:    video.Snippet.ChannelId,
+				ChannelTitle: video.Snippet.ChannelTitle,
+			},
+		})
+	}
+
+	return items, nil
+}
+
+// Чи обробляється відео в якомусь плейлисті. Метрики по такому відео збирає плейлист
+func isPlayListVideo(videoId string) bool {
+	for _, playList := range getRequestPlayList() {
+		if hasVideo(playList, videoId) {
+			return true
+		}
+	}
+	return false
+}
+
+// Додаємо відео, знайдене запитом, для збору статистики
+func addQueryVideo(query *model.YoutubeQuery, videoId string, item *youtube.PlaylistItem) {
+	timePublishedAt, err := time.Parse(LAYOUT_ISO_8601, item.Snippet.PublishedAt)
+	if err != nil {
+		log.Errorf("pl: %v, error parse PublishedAt %v", query.Id, item.Snippet.PublishedAt)
+		return
+	}
+
+	// пошук може знайти давно опубліковане відео, метрики по ньому не збираються, зберігається тільки позиція
+	timeElapsed := time.Since(timePublishedAt)
+	if timeElapsed > config.PeriodСollection.Get() {
+		log.Debugf("pl: %v, video: %v, skip proccessing, time elapsed: %v", query.Id, videoId, timeElapsed)
+		return
+	}
+
+	inPlayList, err := database.AddQueryVideo(videoId, query.QueryId, timePublishedAt, item.Snippet.Title,
+		item.Snippet.Description, item.Snippet.ChannelId, item.Snippet.ChannelTitle)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if inPlayList {
+		log.Debugf("pl: %v, video: %v, skip proccessing, video belongs to playlist", query.Id, videoId)
+		return
+	}
+
+	query.Mux.Lock()
+	defer query.Mux.Unlock()
+	query.Append(videoId, &model.YoutubeVideo{PublishedAt: timePublishedAt, Title: item.Snippet.Title})
+	log.Infof("pl: %v, video: %v, add new at: %v, title: %v", query.Id, videoId, timePublishedAt, item.Snippet.Title)
+}
+
+// Відео перейшло до плейлиста: припиняємо збір метрик по ньому в запитах, щоб не зберігати їх двічі
+func releaseQueryVideo(videoId string) {
+	for _, query := range getRequestQueries() {
+		query.Mux.Lock()
+		if _, ok := query.Videos[videoId]; ok {
+			query.Delete(videoId)
+			log.Infof("pl: %v, video: %v, moved to playlist", query.Id, videoId)
+		}
+		query.Mux.Unlock()
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/AleksandrKuts/youtubemeter-service/collector/config"
+	"github.com/AleksandrKuts/youtubemeter-service/collector/server/database"
+)
+
+// Оновлення зведень метрик може тривати довго, тому одночасно виконується тільки одне
+var rollupMux sync.Mutex
+
+// Перерахунок приросту метрик теж може тривати довго
+var gainMux sync.Mutex
+
+// Оновити погодинні та щоденні зведення метрик та видалити сирі метрики старше keepRawMetric
+// після закінчення збору метрик відео
+func rollupMetrics() {
+	if !rollupMux.TryLock() {
+		log.Warn("rollup of metrics is already running")
+		return
+	}
+	defer rollupMux.Unlock()
+
+	if err := database.RollupMetrics(); err != nil {
+		log.Errorf("metrics are not rolled up, err=%v", err)
+		return
+	}
+
+	if config.KeepRawMetric.Get() == 0 {
+		return
+	}
+
+	count, err := database.DeleteRawMetrics(config.PeriodСollection.Get(), config.KeepRawMetric.Get())
+	if err != nil {
+		log.Errorf("raw metrics are not deleted, err=%v", err)
+		return
+	}
+	log.Infof("raw metrics deleted: %v", count)
+}
+
+// Перерахувати приріст метрик відео за останню годину, добу та тиждень для рейтингів бекенда
+func refreshVideoGain() {
+	if !gainMux.TryLock() {
+		log.Warn("refresh of video gain is already running")
+		return
+	}
+	defer gainMux.Unlock()
+
+	count, err := database.RefreshVideoGain()
+	if err != nil {
+		log.Errorf("video gain is not refreshed, err=%v", err)
+		return
+	}
+	log.Debugf("video gain refreshed, rows: %v", count)
+}
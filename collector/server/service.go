@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/context"
@@ -34,7 +35,9 @@ var playlists model.YoutubePlayLists
 
 var service *youtube.Service
 
-func init() {
+// Клієнт YouTube API. Створюється під час запуску сервісу, а не при імпорті пакета, тому команди, яким
+// YouTube не потрібен (collector --migrate), не читають секрет OAuth і не запитують токен
+func initService() {
 	ctx := context.Background()
 
 	b, err := ioutil.ReadFile(*config.FileSecret)
@@ -56,34 +59,90 @@ func init() {
 }
 
 func StartService(versionMajor, versionMin string) {
+	setup()
 	log.Warnf("server start, version: %s.%s", versionMajor, versionMin)
 
+	initService()
+
+	if err := database.CheckSchema(); err != nil {
+		log.Fatalf("err=%v", err)
+	}
+
+	// секції для нових метрик потрібні до першого збереження метрик
+	partitionMetrics()
+
 	initPlayLists()
+	initQueries()
 
 	checkPlayLists()
 	checkVideos()
+	runQueries()
 
 	time.Sleep(10 * time.Second)
 
 	getMeters()
 
-	timerPlayList := time.Tick(*config.PeriodPlayList)
-	timerVideo := time.Tick(*config.PeriodVideo)
+	startWebSub()
+
+	timerPlayList := time.NewTicker(config.PeriodPlayList.Get())
+	timerVideo := time.NewTicker(periodVideo())
+	timerQuery := time.NewTicker(config.PeriodQuery.Get())
+	timerComment := time.NewTicker(config.PeriodComment.Get())
+	timerRollup := time.NewTicker(config.PeriodRollup.Get())
+	timerGain := time.NewTicker(config.PeriodGain.Get())
+	timerPartition := time.NewTicker(config.PeriodPartition.Get())
+
+	time.Sleep(config.ShiftPeriodMetric.Get())
+	timerMeter := time.NewTicker(config.PeriodMeter.Get())
+
+	// Після перечитування налаштувань таймери треба перезапустити, бо могли змінитися періоди
+	reload := make(chan bool, 1)
+	config.OnReload(func() {
+		select {
+		case reload <- true:
+		default: // перезапуск таймерів вже очікує
+		}
+	})
 
-	time.Sleep(*config.ShiftPeriodMetric)
-	timerMeter := time.Tick(*config.PeriodMeter)
+	// Налаштування перечитуються по сигналу SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 
 	for {
 		select {
-		case <-timerPlayList:
+		case <-timerPlayList.C:
 			go checkPlayLists()
-		case <-timerVideo:
+			go checkQueries()
+		case <-timerVideo.C:
 			go checkVideos()
-		case <-timerMeter:
+		case <-timerQuery.C:
+			go runQueries()
+		case <-timerComment.C:
+			go getComments()
+		case <-timerMeter.C:
 			go getMeters()
+		case <-timerRollup.C:
+			go rollupMetrics()
+		case <-timerGain.C:
+			go refreshVideoGain()
+		case <-timerPartition.C:
+			go partitionMetrics()
+		case <-hup:
+			go reloadConfig()
+		case <-reload:
+			timerPlayList.Reset(config.PeriodPlayList.Get())
+			timerVideo.Reset(periodVideo())
+			timerQuery.Reset(config.PeriodQuery.Get())
+			timerComment.Reset(config.PeriodComment.Get())
+			timerMeter.Reset(config.PeriodMeter.Get())
+			timerRollup.Reset(config.PeriodRollup.Get())
+			timerGain.Reset(config.PeriodGain.Get())
+			timerPartition.Reset(config.PeriodPartition.Get())
+			log.Infof("timers restarted, playlist: %v, video: %v, query: %v, metric: %v", config.PeriodPlayList.Get(),
+				periodVideo(), config.PeriodQuery.Get(), config.PeriodMeter.Get())
 		case <-quit:
 			log.Warn("Service shutting down")
 			return
@@ -93,6 +152,18 @@ func StartService(versionMajor, versionMin string) {
 	}
 }
 
+// Перечитати налаштування з ini-файлу. Зміни налаштувань, які не можна змінити під час роботи, відхиляються
+func reloadConfig() {
+	log.Warn("reload config")
+
+	changed, err := config.Reload()
+	if err != nil {
+		log.Errorf("config is not reloaded: %v", err)
+		return
+	}
+	log.Infof("config reloaded, changed: %v", changed)
+}
+
 // getClient uses a Context and Config to retrieve a Token
 // then generate a Client. It returns the generated Client.
 func getClient(ctx context.Context, config *oauth2.Config) *http.Client {
@@ -106,7 +177,8 @@ func getClient(ctx context.Context, config *oauth2.Config) *http.Client {
 		saveToken(cacheFile, tok)
 	}
 
-	log.Debugf("token=%v", tok)
+	// сам токен є секретом, тому в лог пишемо тільки термін дії
+	log.Debugf("token expiry=%v", tok.Expiry)
 	return config.Client(ctx, tok)
 }
 
@@ -215,7 +287,7 @@ func checkPlayLists() {
 			_, ok := ids[id]
 			if ok == false { // підлягає припиненню обробки
 				if pl.Deleted { // вже помічений на припинення обробки
-					if time.Since(pl.TimeDeleted) > *config.PeriodDeleted { // перевіряємо, чи не час припиняти обробку
+					if time.Since(pl.TimeDeleted) > config.PeriodDeleted.Get() { // перевіряємо, чи не час припиняти обробку
 						playlists.Delete(id) // видалення
 						log.Infof("pl: %v, stop processing playlist", id)
 					}
@@ -228,15 +300,22 @@ func checkPlayLists() {
 					playlists.CanselDeletedPlayList(id) // відміна видалення
 					log.Debugf("pl: %v, cansel stop processing playlist", id)
 				}
+
+				// Адміністратор міг змінити індивідуальні налаштування плейлиста. Налаштування змінюються тільки
+				// під блокуванням списку плейлистів (див. YoutubePlayLists.GetSettings)
+				if settings := ids[id]; pl.Settings != settings {
+					pl.Settings = settings
+					log.Infof("pl: %v, update settings: %+v", id, settings)
+				}
 			}
 
 		}
 
 		// Перевіряємо список на додавання нових плейлистів
-		for id, _ := range ids {
+		for id, settings := range ids {
 			_, ok := playlists.Playlists[id]
 			if ok == false {
-				playlists.Append(id) // додаемо новий PlayList
+				playlists.Append(id, settings) // додаемо новий PlayList
 				log.Infof("pl: %v, Append playlist", id)
 			}
 		}
@@ -262,6 +341,30 @@ func getRequestPlayList() map[string]*model.YoutubePlayList {
 	return requestPlayList
 }
 
+// Термін збору метрик для відео плейлиста: індивідуальний, якщо заданий, інакше глобальний
+func periodCollection(playList *model.YoutubePlayList) time.Duration {
+	if settings := playlists.GetSettings(playList); settings.PeriodCollection > 0 {
+		return settings.PeriodCollection
+	}
+	return config.PeriodСollection.Get()
+}
+
+// Періодичність збереження незмінних метрик для відео плейлиста: індивідуальна, якщо задана, інакше глобальна
+func periodCount(playList *model.YoutubePlayList) time.Duration {
+	if settings := playlists.GetSettings(playList); settings.PeriodCount > 0 {
+		return settings.PeriodCount
+	}
+	return config.PeriodCount.Get()
+}
+
+// Максимальна кількість відео в запиті до плейлиста: індивідуальна, якщо задана, інакше глобальна
+func maxRequestVideos(playList *model.YoutubePlayList) int64 {
+	if settings := playlists.GetSettings(playList); settings.MaxRequestVideos > 0 {
+		return settings.MaxRequestVideos
+	}
+	return config.MaxRequestVideos.Get()
+}
+
 // Перевіряємо список відео в плейлистах, чи були додані нові, чи вичерпався термін збору статистики на старих
 func checkVideos() {
 	log.Debug("check videos start")
@@ -289,7 +392,7 @@ func checkVideosByPlaylistId(playList *model.YoutubePlayList) {
 	playListId := playList.Id
 
 	call := service.PlaylistItems.List(PLAY_LIST_PART)
-	call = call.MaxResults(*config.MaxRequestVideos)
+	call = call.MaxResults(maxRequestVideos(playList))
 	call = call.PlaylistId(playListId)
 	response, err := call.Do()
 	if err != nil {
@@ -321,10 +424,14 @@ func checkVideosByPlaylistId(playList *model.YoutubePlayList) {
 	log.Infof("pl: %v, count videos: %v", playList.Id, len(playList.Videos))
 }
 
-// Перевіряє ПлейЛист чи не настав час (задається через config.PeriodСollection) припинити обробку якихось відео
+// Перевіряє ПлейЛист чи не настав час (задається через config.PeriodСollection, або індивідуально для плейлиста)
+// припинити обробку якихось відео
 // Спочатку відео помічаєтеся для видалення, а через заданий час (config.PeriodDeleted) видаляється остаточно
 // Рознесення в часі помітки відео на видалення і само видалення гарантує коректну роботу потоків програми
 func checkElapsedVideos(playList *model.YoutubePlayList) {
+	// налаштування читаються під блокуванням списку плейлистів, тому до блокування плейлиста
+	periodCollection := periodCollection(playList)
+
 	playList.Mux.Lock()
 	defer playList.Mux.Unlock()
 
@@ -333,13 +440,13 @@ func checkElapsedVideos(playList *model.YoutubePlayList) {
 
 		if video.Deleted { // якщо відео призначене для видалення
 			countDeleted++
-			if time.Since(video.TimeDeleted) > *config.PeriodDeleted { // перевіряємо, чи не час видаляти
+			if time.Since(video.TimeDeleted) > config.PeriodDeleted.Get() { // перевіряємо, чи не час видаляти
 				playList.Delete(id) // видалення
 				log.Infof("pl: %v, video: %v, stop processing", playList.Id, id)
 			}
 		} else { // відео ще не призначене для видалення
 			// Перевірка чи не потрібно припинити обробку відео за часом
-			if time.Since(video.PublishedAt) > *config.PeriodСollection {
+			if time.Since(video.PublishedAt) > periodCollection {
 				playList.SetDeletedVideo(id)
 				log.Infof("pl: %v, video: %v, set stop processing", playList.Id, id)
 			}
@@ -371,7 +478,7 @@ func addVideo(playList *model.YoutubePlayList, videoId string, item *youtube.Pla
 		return
 	}
 	timeElapsed := time.Since(timePublishedAt)
-	if timeElapsed > *config.PeriodСollection {
+	if timeElapsed > periodCollection(playList) {
 		log.Debugf("pl: %v, video: %v, skip proccessing, time elapsed: %v", playListId, videoId, timeElapsed)
 		return
 	}
@@ -387,6 +494,9 @@ func addVideo(playList *model.YoutubePlayList, videoId string, item *youtube.Pla
 	defer playList.Mux.Unlock()
 	playList.Append(videoId, &model.YoutubeVideo{PublishedAt: timePublishedAt, Title: title, Deleted: false})
 	log.Infof("pl: %v, video: %v, add new at: %v, title: %v", playListId, videoId, timePublishedAt, title)
+
+	// відео могло бути раніше знайдене запитом, тепер метрики по ньому збирає плейлист
+	releaseQueryVideo(videoId)
 }
 
 func getMeters() {
@@ -396,16 +506,33 @@ func getMeters() {
 	log.Debugf("check meters, count request playlists: %v", len(requestPlayList))
 
 	for _, playList := range requestPlayList {
+		// плейлист з індивідуальною періодичністю обробляється тільки коли настав його час. Запити
+		// робляться по таймеру config.PeriodMeter, тому індивідуальна періодичність не може бути меншою за нього,
+		// половина періоду таймера - допуск на нерівномірність спрацювання таймера
+		settings := playlists.GetSettings(playList)
+		if settings.PeriodMeter > 0 &&
+			time.Since(playList.TimeMeter) < settings.PeriodMeter-config.PeriodMeter.Get()/2 {
+			log.Debugf("pl: %v, SKIP - period metric: %v", playList.Id, settings.PeriodMeter)
+			continue
+		}
+		playList.TimeMeter = time.Now()
+
 		go getMetersVideos(playList)
 	}
+
+	// відео, знайдені запитами, обробляються з глобальною періодичністю
+	for _, query := range getRequestQueries() {
+		go getMetersVideos(query.YoutubePlayList)
+	}
 	log.Debug("check meters end")
 }
 
 func getMetersVideos(playList *model.YoutubePlayList) {
 	if len(playList.Videos) > 0 {
 		mRrequestVideos := getRequestVideosFromPlayList(playList)
+		periodCount := periodCount(playList)
 		for i := 0; i < len(mRrequestVideos); i++ {
-			getMetersVideosInd(playList.Id, mRrequestVideos[i])
+			getMetersVideosInd(playList.Id, mRrequestVideos[i], periodCount)
 		}
 	} else {
 		log.Infof("pl: %v, SKIP - count videos 0", playList.Id)
@@ -437,7 +564,7 @@ func getRequestVideosFromPlayList(playList *model.YoutubePlayList) []map[string]
 		if !video.Deleted { // додаються тільки робочі плейлисти
 			// обробляємо тільки дозволену кількість відео. Запрос ділимо на частини
 			// Робимо нову частину запросу: ще 50 відео
-			if count >= *config.MaxRequestCountVideoID {
+			if count >= config.MaxRequestCountVideoID.Get() {
 				requestVideos = make(map[string]*model.YoutubeVideo)
 				mRequestVideos = append(mRequestVideos, requestVideos)
 				count = 0
@@ -454,7 +581,7 @@ func getRequestVideosFromPlayList(playList *model.YoutubePlayList) []map[string]
 	return mRequestVideos
 }
 
-func getMetersVideosInd(idpl string, requestVideos map[string]*model.YoutubeVideo) {
+func getMetersVideosInd(idpl string, requestVideos map[string]*model.YoutubeVideo, periodCount time.Duration) {
 	log.Debugf("pl: %v, getMetersVideo, count request videos: %v", idpl, len(requestVideos))
 
 	// Формуємо стрічку з id подилену комами
@@ -500,9 +627,9 @@ func getMetersVideosInd(idpl string, requestVideos map[string]*model.YoutubeVide
 		if ok == true {
 
 			// Заносимо метрики до БД в двох випадках:
-			//   1. якщо пройшов заданий період ( PeriodCount )
+			//   1. якщо пройшов заданий період ( PeriodCount, або індивідуальний для плейлиста )
 			//   2. якщо змінилась будь яка метрика (лайки, дізлайки тощо)
-			if time.Since(rVideo.TimeCount) > *config.PeriodCount ||
+			if time.Since(rVideo.TimeCount) > periodCount ||
 				rVideo.CommentCount != videoCommentCount ||
 				rVideo.LikeCount != videoLikeCount ||
 				rVideo.DislikeCount != videoDislikeCount ||
@@ -0,0 +1,370 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/AleksandrKuts/youtubemeter-service/collector/config"
+	"github.com/AleksandrKuts/youtubemeter-service/collector/server/model"
+)
+
+// Сповіщення про нові відео каналу через WebSub (PubSubHubbub). Колектор підписується на Atom-стрічку кожного
+// каналу, хаб надсилає POST-запит на config.WebSubCallbackURL при появі нового відео. Опитування плейлистів
+// (config.PeriodVideoWebSub) залишається як більш рідка звірка на випадок втрачених сповіщень
+
+const WEBSUB_TOPIC = "https://www.youtube.com/xml/feeds/videos.xml?channel_id="
+const VIDEO_SNIPPET_PART = "snippet"
+
+// За скільки до закінчення терміну підписки (частка від webSubLease) її треба поновити
+const WEBSUB_RENEW_PART = 10
+
+// Максимальний розмір тіла сповіщення. Сповіщення хаба - Atom-стрічка з одним-двома записами, кілька кілобайт
+const WEBSUB_MAX_BODY = 1 << 20
+
+// Підписка на сповіщення про нові відео каналу
+type subscription struct {
+	// Час закінчення підписки, нульовий - хаб ще не підтвердив підписку
+	expires time.Time
+
+	// Час останнього запиту на підписку
+	requested time.Time
+}
+
+// Підписки по ID каналів
+var subscriptions = struct {
+	sync.Mutex
+	channels map[string]*subscription
+}{channels: make(map[string]*subscription)}
+
+// Стрічка сповіщень YouTube у форматі Atom
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	VideoId   string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+	ChannelId string `xml:"http://www.youtube.com/xml/schemas/2015 channelId"`
+	Title     string `xml:"title"`
+	Published string `xml:"published"`
+}
+
+// Період опитування плейлистів: якщо працюють сповіщення WebSub, опитування потрібне тільки для звірки
+func periodVideo() time.Duration {
+	if *config.WebSubEnable {
+		return config.PeriodVideoWebSub.Get()
+	}
+	return config.PeriodVideo.Get()
+}
+
+// Запустити прийом сповіщень WebSub та періодичне поновлення підписок
+func startWebSub() {
+	if !*config.WebSubEnable {
+		return
+	}
+
+	callbackURL, err := url.Parse(*config.WebSubCallbackURL)
+	if err != nil {
+		log.Fatalf("websub, error parse callback url: %v", err)
+	}
+	path := callbackURL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, webSubHandler)
+
+	srv := &http.Server{
+		Addr:         *config.WebSubListen,
+		WriteTimeout: *config.Timeout,
+		ReadTimeout:  *config.Timeout,
+		Handler:      mux,
+	}
+
+	go func() {
+		log.Infof("websub, listen: %v, callback: %v", *config.WebSubListen, *config.WebSubCallbackURL)
+		if err := srv.ListenAndServe(); err != nil {
+			log.Fatalf("websub, error listen: %v", err)
+		}
+	}()
+
+	go func() {
+		checkSubscriptions()
+		for range time.Tick(*config.WebSubLease / WEBSUB_RENEW_PART) {
+			checkSubscriptions()
+		}
+	}()
+}
+
+// Перевірити підписки: підписатися на нові канали, поновити підписки, термін яких закінчується,
+// відписатися від каналів, плейлисти яких більше не обробляються
+func checkSubscriptions() {
+	channels := make(map[string]bool)
+	for _, playList := range getRequestPlayList() {
+		if channelId := playlists.GetSettings(playList).ChannelId; channelId != "" {
+			channels[channelId] = true
+		}
+	}
+
+	renew := *config.WebSubLease / WEBSUB_RENEW_PART
+
+	subscriptions.Lock()
+	defer subscriptions.Unlock()
+
+	for channelId := range channels {
+		s, ok := subscriptions.channels[channelId]
+		if !ok {
+			s = &subscription{}
+			subscriptions.channels[channelId] = s
+		}
+
+		// підписка діє і не скоро закінчується, або хаб ще не встиг підтвердити запит
+		if time.Until(s.expires) > renew || time.Since(s.requested) < renew {
+			continue
+		}
+
+		if err := requestSubscription(channelId, "subscribe"); err != nil {
+			log.Errorf("ch: %v, websub, error subscribe: %v", channelId, err)
+			continue
+		}
+		s.requested = time.Now()
+	}
+
+	for channelId := range subscriptions.channels {
+		if channels[channelId] {
+			continue
+		}
+		delete(subscriptions.channels, channelId)
+
+		if err := requestSubscription(channelId, "unsubscribe"); err != nil {
+			log.Errorf("ch: %v, websub, error unsubscribe: %v", channelId, err)
+		}
+	}
+}
+
+// Запит до хаба на підписку чи відписку (mode: subscribe, unsubscribe). Хаб підтверджує запит асинхронно
+// GET-запитом на callback (див. verifySubscription)
+func requestSubscription(channelId, mode string) error {
+	form := url.Values{}
+	form.Set("hub.callback", *config.WebSubCallbackURL)
+	form.Set("hub.mode", mode)
+	form.Set("hub.topic", WEBSUB_TOPIC+channelId)
+	form.Set("hub.verify", "async")
+	form.Set("hub.lease_seconds", strconv.FormatInt(int64(*config.WebSubLease/time.Second), 10))
+	form.Set("hub.secret", *config.WebSubSecret)
+
+	client := &http.Client{Timeout: *config.Timeout}
+	resp, err := client.PostForm(*config.WebSubHub, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNoContent {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return &hubError{resp.StatusCode, strings.TrimSpace(string(body))}
+	}
+
+	log.Infof("ch: %v, websub, %v requested", channelId, mode)
+	return nil
+}
+
+type hubError struct {
+	status int
+	body   string
+}
+
+func (e *hubError) Error() string {
+	return "hub response status " + strconv.Itoa(e.status) + ": " + e.body
+}
+
+// Оброблювач запитів хаба: GET - підтвердження підписки, POST - сповіщення про нові відео
+func webSubHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		verifySubscription(w, r)
+	case "POST":
+		receiveNotification(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Підтвердження запиту на підписку чи відписку: повертаємо hub.challenge тільки для запитів, які ми робили
+func verifySubscription(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	mode := q.Get("hub.mode")
+	topic := q.Get("hub.topic")
+	challenge := q.Get("hub.challenge")
+	log.Debugf("websub, verify, mode: %v, topic: %v", mode, topic)
+
+	if !strings.HasPrefix(topic, WEBSUB_TOPIC) || challenge == "" {
+		http.NotFound(w, r)
+		return
+	}
+	channelId := strings.TrimPrefix(topic, WEBSUB_TOPIC)
+
+	subscriptions.Lock()
+	s, ok := subscriptions.channels[channelId]
+	switch {
+	case mode == "subscribe" && ok:
+		lease, err := strconv.ParseInt(q.Get("hub.lease_seconds"), 10, 64)
+		if err != nil {
+			lease = int64(*config.WebSubLease / time.Second)
+		}
+		s.expires = time.Now().Add(time.Duration(lease) * time.Second)
+		log.Infof("ch: %v, websub, subscribed until %v", channelId, s.expires)
+	case mode == "unsubscribe" && !ok:
+		log.Infof("ch: %v, websub, unsubscribed", channelId)
+	default:
+		subscriptions.Unlock()
+		log.Warnf("ch: %v, websub, reject unexpected %v", channelId, mode)
+		http.NotFound(w, r)
+		return
+	}
+	subscriptions.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(challenge))
+}
+
+// Прийом сповіщення про нові чи змінені відео
+func receiveNotification(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, WEBSUB_MAX_BODY))
+	if err != nil {
+		log.Errorf("websub, error read notification from %v: %v", r.RemoteAddr, err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		return
+	}
+
+	// згідно специфікації, на сповіщення з неправильним підписом відповідаємо 2xx, але не обробляємо його
+	if !validSignature(body, r.Header.Get("X-Hub-Signature")) {
+		log.Warnf("websub, skip notification with invalid signature from %v", r.RemoteAddr)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		log.Errorf("websub, error parse notification: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	for _, entry := range feed.Entries {
+		log.Infof("ch: %v, video: %v, websub, notification, title: %v", entry.ChannelId, entry.VideoId, entry.Title)
+		if entry.VideoId != "" && entry.ChannelId != "" {
+			go pushVideo(entry.ChannelId, entry.VideoId)
+		}
+	}
+}
+
+// Перевірка підпису сповіщення: X-Hub-Signature: sha1=<hex HMAC-SHA1 тіла запиту з ключем webSubSecret>
+func validSignature(body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, "sha1=") {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha1="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, []byte(*config.WebSubSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Додати відео, про яке прийшло сповіщення, до плейлистів каналу. Плейлист завантажень каналу (UU...)
+// містить всі відео каналу, тому відео додається одразу. Для інших плейлистів каналу невідомо, чи входить
+// туди відео, тому для них позачергово перевіряється список відео
+func pushVideo(channelId, videoId string) {
+	uploadsId := "UU" + strings.TrimPrefix(channelId, "UC")
+
+	var uploads *model.YoutubePlayList
+	others := []*model.YoutubePlayList{}
+	for id, playList := range getRequestPlayList() {
+		if playlists.GetSettings(playList).ChannelId != channelId {
+			continue
+		}
+		if id == uploadsId {
+			uploads = playList
+		} else {
+			others = append(others, playList)
+		}
+	}
+
+	if uploads == nil && len(others) == 0 {
+		log.Debugf("ch: %v, video: %v, websub, channel is not tracked", channelId, videoId)
+		return
+	}
+
+	for _, playList := range others {
+		go checkVideosByPlaylistId(playList)
+	}
+
+	if uploads == nil || hasVideo(uploads, videoId) {
+		return
+	}
+
+	item, err := getPlaylistItemByVideoId(videoId)
+	if err != nil {
+		log.Errorf("pl: %v, video: %v, websub, error get video: %v", uploads.Id, videoId, err)
+		return
+	}
+	if item == nil {
+		log.Warnf("pl: %v, video: %v, websub, video not found", uploads.Id, videoId)
+		return
+	}
+
+	addVideo(uploads, videoId, item)
+}
+
+// Чи вже обробляється відео в плейлисті
+func hasVideo(playList *model.YoutubePlayList, videoId string) bool {
+	playList.Mux.Lock()
+	defer playList.Mux.Unlock()
+
+	_, ok := playList.Videos[videoId]
+	return ok
+}
+
+// Отримати опис відео у вигляді елемента плейлиста для addVideo
+func getPlaylistItemByVideoId(videoId string) (*youtube.PlaylistItem, error) {
+	response, err := service.Videos.List(VIDEO_SNIPPET_PART).Id(videoId).Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Items) == 0 || response.Items[0].Snippet == nil {
+		return nil, nil
+	}
+
+	snippet := response.Items[0].Snippet
+	return &youtube.PlaylistItem{
+		ContentDetails: &youtube.PlaylistItemContentDetails{VideoId: videoId},
+		Snippet: &youtube.PlaylistItemSnippet{
+			Title:        snippet.Title,
+			PublishedAt:  snippet.PublishedAt,
+			Description:  snippet.Description,
+			ChannelId:    snippet.ChannelId,
+			ChannelTitle: snippet.ChannelTitle,
+		},
+	}, nil
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AleksandrKuts/youtubemeter-service/collector/config"
+)
+
+// Сповіщення хаба YouTube про нове відео
+const testFeed = `<?xml version='1.0' encoding='UTF-8'?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom">
+  <link rel="hub" href="https://pubsubhubbub.appspot.com"/>
+  <link rel="self" href="https://www.youtube.com/xml/feeds/videos.xml?channel_id=UCtest"/>
+  <title>YouTube video feed</title>
+  <updated>2026-10-19T12:00:00.000000+00:00</updated>
+  <entry>
+    <id>yt:video:video1</id>
+    <yt:videoId>video1</yt:videoId>
+    <yt:channelId>UCtest</yt:channelId>
+    <title>Title &amp; "quotes"</title>
+    <link rel="alternate" href="https://www.youtube.com/watch?v=video1"/>
+    <author>
+      <name>Channel</name>
+      <uri>https://www.youtube.com/channel/UCtest</uri>
+    </author>
+    <published>2026-10-19T11:59:00+00:00</published>
+    <updated>2026-10-19T12:00:00.000000+00:00</updated>
+  </entry>
+</feed>`
+
+// Сповіщення хаба про видалене відео: запис іншого типу, відео в ньому немає
+const testDeletedFeed = `<?xml version='1.0' encoding='UTF-8'?>
+<feed xmlns:at="http://purl.org/atompub/tombstones/1.0" xmlns="http://www.w3.org/2005/Atom">
+  <at:deleted-entry ref="yt:video:video1" when="2026-10-19T12:00:00.000000+00:00">
+    <link href="https://www.youtube.com/watch?v=video1"/>
+  </at:deleted-entry>
+</feed>`
+
+// Встановити секрет сповіщень на час тесту
+func testWebSubSecret(t *testing.T, secret string) {
+	old := *config.WebSubSecret
+	*config.WebSubSecret = secret
+	t.Cleanup(func() { *config.WebSubSecret = old })
+}
+
+func testSignature(secret, body string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidSignature(t *testing.T) {
+	testWebSubSecret(t, "secret")
+	body := "<feed/>"
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		want      bool
+	}{
+		{"valid", body, testSignature("secret", body), true},
+		{"upper case hex", body, "sha1=" + strings.ToUpper(strings.TrimPrefix(testSignature("secret", body), "sha1=")), true},
+		{"other secret", body, testSignature("other", body), false},
+		{"changed body", body + " ", testSignature("secret", body), false},
+		{"no signature", body, "", false},
+		{"no algorithm", body, strings.TrimPrefix(testSignature("secret", body), "sha1="), false},
+		{"other algorithm", body, "sha256=" + strings.TrimPrefix(testSignature("secret", body), "sha1="), false},
+		{"invalid hex", body, "sha1=zz", false},
+		{"truncated", body, testSignature("secret", body)[:20], false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validSignature([]byte(tt.body), tt.signature); got != tt.want {
+				t.Errorf("validSignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAtomFeed(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []atomEntry
+	}{
+		{"new video", testFeed, []atomEntry{{VideoId: "video1", ChannelId: "UCtest", Title: `Title & "quotes"`,
+			Published: "2026-10-19T11:59:00+00:00"}}},
+		{"deleted video", testDeletedFeed, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var feed atomFeed
+			if err := xml.Unmarshal([]byte(tt.body), &feed); err != nil {
+				t.Fatal(err)
+			}
+			if len(feed.Entries) != len(tt.want) {
+				t.Fatalf("entries = %+v, want %+v", feed.Entries, tt.want)
+			}
+			for i := range tt.want {
+				if feed.Entries[i] != tt.want[i] {
+					t.Errorf("entry %v = %+v, want %+v", i, feed.Entries[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReceiveNotification(t *testing.T) {
+	testWebSubSecret(t, "secret")
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		want      int
+	}{
+		// канал сповіщення не відстежується, тому відео ніде не додається
+		{"new video", testFeed, testSignature("secret", testFeed), http.StatusAccepted},
+		{"deleted video", testDeletedFeed, testSignature("secret", testDeletedFeed), http.StatusAccepted},
+		{"invalid signature is accepted and ignored", "not xml", testSignature("other", "not xml"), http.StatusAccepted},
+		{"no signature is accepted and ignored", "not xml", "", http.StatusAccepted},
+		{"invalid xml", "not xml", testSignature("secret", "not xml"), http.StatusBadRequest},
+		{"too large", strings.Repeat(" ", WEBSUB_MAX_BODY+1), "", http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/websub", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				r.Header.Set("X-Hub-Signature", tt.signature)
+			}
+			w := httptest.NewRecorder()
+
+			receiveNotification(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %v, want %v", w.Code, tt.want)
+			}
+		})
+	}
+}
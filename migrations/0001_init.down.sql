@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS public.metric;
+DROP TABLE IF EXISTS public.video;
+DROP TABLE IF EXISTS public.playlist;
@@ -0,0 +1,43 @@
+/* Плейлисти, відео та метрики відео. Всі міграції можна застосувати і до бази, створеної до появи міграцій (дамп
+   sql/db.sql та скрипти sql/add_*.sql): вже створені таблиці, колонки та індекси залишаються, дані не змінюються */
+CREATE TABLE IF NOT EXISTS public.playlist (
+    id character(24) NOT NULL,
+    enable boolean,
+    title character(80),
+    idch character(24) NOT NULL,
+    timeadd timestamp with time zone DEFAULT now(),
+    CONSTRAINT playlist_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS public.video (
+    id character(11) NOT NULL,
+    idpl character(24) NOT NULL,
+    title character(100) DEFAULT ''::bpchar,
+    description character varying(5000),
+    chtitle character(100) DEFAULT ''::bpchar,
+    chid character(24) DEFAULT ''::bpchar,
+    publishedat timestamp with time zone,
+    CONSTRAINT video_pkey PRIMARY KEY (id),
+    CONSTRAINT video_idpl_fkey FOREIGN KEY (idpl) REFERENCES public.playlist(id)
+);
+
+CREATE INDEX IF NOT EXISTS video_idpl_idx ON public.video USING btree (idpl);
+
+CREATE SEQUENCE IF NOT EXISTS public.metrics_id_seq;
+
+CREATE TABLE IF NOT EXISTS public.metric (
+    id integer DEFAULT nextval('public.metrics_id_seq'::regclass) NOT NULL,
+    idvideo character(11) NOT NULL,
+    commentcount bigint DEFAULT 0,
+    dislikecount bigint DEFAULT 0,
+    likecount bigint DEFAULT 0,
+    viewcount bigint DEFAULT 0,
+    timemetric timestamp with time zone DEFAULT now(),
+    CONSTRAINT metrics_pkey PRIMARY KEY (id),
+    CONSTRAINT metrics_idv_fkey FOREIGN KEY (idvideo) REFERENCES public.video(id)
+);
+
+ALTER SEQUENCE public.metrics_id_seq OWNED BY public.metric.id;
+
+/* Колектор не передає час виміру, в дампі значення за замовчуванням не було */
+ALTER TABLE public.metric ALTER COLUMN timemetric SET DEFAULT now();
@@ -0,0 +1,3 @@
+DROP FUNCTION IF EXISTS public.return_video(character);
+DROP FUNCTION IF EXISTS public.return_metrics(character, character, character);
+DROP FUNCTION IF EXISTS public.return_metrics(character);
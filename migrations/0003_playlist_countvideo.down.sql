@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS tr_change_video ON public.video;
+DROP FUNCTION IF EXISTS public.change_video();
+ALTER TABLE public.playlist DROP COLUMN IF EXISTS countvideo;
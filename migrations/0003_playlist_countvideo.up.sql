@@ -0,0 +1,24 @@
+/* Кількість відео плейлиста, яку підтримує тригер tr_change_video. Для вже заповненої бази лічильник
+   перераховується */
+ALTER TABLE public.playlist ADD COLUMN IF NOT EXISTS countvideo integer DEFAULT 0 NOT NULL;
+
+UPDATE public.playlist p SET countvideo = (SELECT COUNT(*) FROM public.video v WHERE v.idpl = p.id);
+
+CREATE OR REPLACE FUNCTION public.change_video() RETURNS TRIGGER AS
+$BODY$
+BEGIN
+	IF (TG_OP = 'INSERT') THEN
+		UPDATE playlist SET countvideo = countvideo + 1 WHERE id = NEW.idpl;
+		RETURN NEW;
+	ELSIF (TG_OP = 'DELETE') THEN
+		UPDATE playlist SET countvideo = countvideo - 1 WHERE id = OLD.idpl;
+		RETURN OLD;
+	END IF;
+END
+$BODY$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS tr_change_video ON public.video;
+
+CREATE TRIGGER tr_change_video
+AFTER INSERT OR DELETE ON public.video
+    FOR EACH ROW EXECUTE PROCEDURE public.change_video();
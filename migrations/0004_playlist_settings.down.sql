@@ -0,0 +1,5 @@
+ALTER TABLE public.playlist DROP CONSTRAINT IF EXISTS playlist_settings_check;
+ALTER TABLE public.playlist DROP COLUMN IF EXISTS periodcollect;
+ALTER TABLE public.playlist DROP COLUMN IF EXISTS periodmetric;
+ALTER TABLE public.playlist DROP COLUMN IF EXISTS periodsavemetricidle;
+ALTER TABLE public.playlist DROP COLUMN IF EXISTS maxrequestvideos;
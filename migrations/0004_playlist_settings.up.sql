@@ -0,0 +1,13 @@
+/* Індивідуальні налаштування збору метрик для плейлиста. Якщо значення не задане (NULL), колектор використовує
+   глобальні налаштування з collector.ini. Періоди зберігаються в секундах */
+ALTER TABLE public.playlist ADD COLUMN IF NOT EXISTS periodcollect integer; /* термін збору метрик відео (periodCollect) */
+ALTER TABLE public.playlist ADD COLUMN IF NOT EXISTS periodmetric integer; /* періодичність отримання метрик (periodMetric) */
+ALTER TABLE public.playlist ADD COLUMN IF NOT EXISTS periodsavemetricidle integer; /* періодичність збереження незмінних метрик (periodSaveMetricIdle) */
+ALTER TABLE public.playlist ADD COLUMN IF NOT EXISTS maxrequestvideos integer; /* кількість відео в запиті до плейлиста (maxRequestVideos) */
+
+ALTER TABLE public.playlist DROP CONSTRAINT IF EXISTS playlist_settings_check;
+ALTER TABLE public.playlist ADD CONSTRAINT playlist_settings_check CHECK (
+	(periodcollect IS NULL OR periodcollect > 0) AND
+	(periodmetric IS NULL OR periodmetric > 0) AND
+	(periodsavemetricidle IS NULL OR periodsavemetricidle > 0) AND
+	(maxrequestvideos IS NULL OR maxrequestvideos BETWEEN 1 AND 50));
@@ -0,0 +1,8 @@
+/* Відео, які знайшли тільки запити, видаляються разом з їх метриками */
+DROP INDEX IF EXISTS public.video_idquery_idx;
+ALTER TABLE public.video DROP COLUMN IF EXISTS idquery;
+DELETE FROM public.metric WHERE idvideo IN (SELECT id FROM public.video WHERE idpl IS NULL);
+DELETE FROM public.video WHERE idpl IS NULL;
+ALTER TABLE public.video ALTER COLUMN idpl SET NOT NULL;
+DROP TABLE IF EXISTS public.queryrank;
+DROP TABLE IF EXISTS public.query;
@@ -0,0 +1,41 @@
+/* Запити, результати яких відстежуються: пошук відео за ключовими словами (search.list) та чарт популярних відео
+   регіону (videos.list chart=mostPopular). Колектор періодично виконує запит, зберігає позиції (rank) знайдених відео
+   та додає відео до збору метрик */
+CREATE TABLE IF NOT EXISTS public.query (
+    id serial NOT NULL,
+    kind character varying(8) NOT NULL, /* search - пошук за ключовими словами, trending - чарт популярних відео */
+    query character varying(200) DEFAULT ''::character varying NOT NULL, /* ключові слова для пошуку */
+    regioncode character(2), /* код регіону ISO 3166-1 alpha-2, для чарту обов'язковий */
+    title character(80),
+    enable boolean DEFAULT true NOT NULL,
+    maxresults integer, /* кількість результатів запиту, NULL - глобальне налаштування maxQueryResults */
+    periodquery integer, /* періодичність виконання запиту в секундах, NULL - глобальне налаштування periodQuery */
+    timeadd timestamp with time zone DEFAULT now(),
+    CONSTRAINT query_pkey PRIMARY KEY (id),
+    CONSTRAINT query_check CHECK (
+        (kind = 'search' AND query <> '') OR (kind = 'trending' AND regioncode IS NOT NULL)),
+    CONSTRAINT query_settings_check CHECK (
+        (maxresults IS NULL OR maxresults BETWEEN 1 AND 50) AND
+        (periodquery IS NULL OR periodquery > 0))
+);
+
+/* Позиції відео в результатах запиту. Відео може бути не додане до збору метрик (наприклад, якщо воно старше
+   за periodCollect), тому зовнішнього ключа на video нема */
+CREATE TABLE IF NOT EXISTS public.queryrank (
+    idquery integer NOT NULL,
+    idvideo character(11) NOT NULL,
+    rank integer NOT NULL,
+    timerank timestamp with time zone NOT NULL,
+    CONSTRAINT queryrank_idquery_fkey FOREIGN KEY (idquery) REFERENCES public.query(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS queryrank_idquery_timerank_idx ON public.queryrank USING btree (idquery, timerank);
+
+/* Відео, знайдене запитом, може не належати жодному плейлисту */
+ALTER TABLE public.video ALTER COLUMN idpl DROP NOT NULL;
+ALTER TABLE public.video ADD COLUMN IF NOT EXISTS idquery integer; /* запит, який першим знайшов відео */
+ALTER TABLE public.video DROP CONSTRAINT IF EXISTS video_idquery_fkey;
+ALTER TABLE public.video ADD CONSTRAINT video_idquery_fkey FOREIGN KEY (idquery) REFERENCES public.query(id)
+    ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS video_idquery_idx ON public.video USING btree (idquery);
@@ -0,0 +1,17 @@
+/* Вибірка коментарів верхнього рівня (commentThreads.list) для відео в перші дні після публікації.
+   Коментарі збираються вибірково (в межах квоти колектора), тому кількість рядків може бути меншою за commentcount
+   в таблиці metric */
+CREATE TABLE IF NOT EXISTS public.comment (
+    id character varying(64) NOT NULL, /* id гілки коментарів */
+    idvideo character(11) NOT NULL,
+    publishedat timestamp with time zone NOT NULL, /* час публікації коментаря */
+    likecount bigint DEFAULT 0 NOT NULL,
+    replycount bigint DEFAULT 0 NOT NULL,
+    text character varying(10000) DEFAULT ''::character varying NOT NULL,
+    timeupdate timestamp with time zone DEFAULT now() NOT NULL, /* час останнього оновлення likecount, replycount */
+    CONSTRAINT comment_pkey PRIMARY KEY (id),
+    CONSTRAINT comment_idvideo_fkey FOREIGN KEY (idvideo) REFERENCES public.video(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS comment_idvideo_publishedat_idx ON public.comment USING btree (idvideo, publishedat);
+CREATE INDEX IF NOT EXISTS comment_idvideo_likecount_idx ON public.comment USING btree (idvideo, likecount DESC);
@@ -0,0 +1,15 @@
+/* Користувачі API бекенда. Користувач авторизується або API-токеном (Authorization: Bearer <токен>),
+   або логіном та паролем (Authorization: Basic). Зберігаються тільки хеші: токена - SHA-256 (hex), пароля - bcrypt.
+   Ролі: viewer - перегляд, editor - редагування плейлистів та запитів, admin - все, включно з користувачами */
+CREATE TABLE IF NOT EXISTS public.apiuser (
+    name character varying(64) NOT NULL,
+    role character varying(8) NOT NULL,
+    tokenhash character(64),
+    passwordhash character varying(100),
+    enable boolean DEFAULT true NOT NULL,
+    timeadd timestamp with time zone DEFAULT now(),
+    CONSTRAINT apiuser_pkey PRIMARY KEY (name),
+    CONSTRAINT apiuser_tokenhash_key UNIQUE (tokenhash),
+    CONSTRAINT apiuser_role_check CHECK (role IN ('viewer', 'editor', 'admin')),
+    CONSTRAINT apiuser_auth_check CHECK (tokenhash IS NOT NULL OR passwordhash IS NOT NULL)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.metric_daily;
+DROP TABLE IF EXISTS public.metric_hourly;
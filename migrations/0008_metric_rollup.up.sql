@@ -0,0 +1,37 @@
+/* Погодинні та щоденні зведення метрик відео. Для кожного інтервалу зберігаються перше, останнє, найменше та
+   найбільше значення лічильників. Зведення підтримує колектор (periodRollup): погодинні рахуються з таблиці metric,
+   щоденні - з погодинних. Сирі рядки metric видаляються через keepRawMetric після закінчення збору метрик відео,
+   старіші періоди бекенд бере із зведень */
+CREATE TABLE IF NOT EXISTS public.metric_hourly (
+    idvideo character(11) NOT NULL,
+    bucket timestamp with time zone NOT NULL, /* початок інтервалу */
+    samples integer NOT NULL, /* кількість вимірів */
+    timefirst timestamp with time zone NOT NULL,
+    timelast timestamp with time zone NOT NULL,
+    commentfirst bigint NOT NULL,
+    commentlast bigint NOT NULL,
+    commentmin bigint NOT NULL,
+    commentmax bigint NOT NULL,
+    likefirst bigint NOT NULL,
+    likelast bigint NOT NULL,
+    likemin bigint NOT NULL,
+    likemax bigint NOT NULL,
+    dislikefirst bigint NOT NULL,
+    dislikelast bigint NOT NULL,
+    dislikemin bigint NOT NULL,
+    dislikemax bigint NOT NULL,
+    viewfirst bigint NOT NULL,
+    viewlast bigint NOT NULL,
+    viewmin bigint NOT NULL,
+    viewmax bigint NOT NULL,
+    CONSTRAINT metric_hourly_pkey PRIMARY KEY (idvideo, bucket),
+    CONSTRAINT metric_hourly_idvideo_fkey FOREIGN KEY (idvideo) REFERENCES public.video(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS public.metric_daily (LIKE public.metric_hourly INCLUDING DEFAULTS,
+    CONSTRAINT metric_daily_pkey PRIMARY KEY (idvideo, bucket),
+    CONSTRAINT metric_daily_idvideo_fkey FOREIGN KEY (idvideo) REFERENCES public.video(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS metric_hourly_bucket_idx ON public.metric_hourly USING btree (bucket);
+CREATE INDEX IF NOT EXISTS metric_daily_bucket_idx ON public.metric_daily USING btree (bucket);
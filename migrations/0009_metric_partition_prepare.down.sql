@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS public.metric_legacy_timemetric_idx;
+DROP INDEX IF EXISTS public.metric_legacy_pkey;
+ALTER TABLE public.metric DROP CONSTRAINT IF EXISTS metric_legacy_bound_check;
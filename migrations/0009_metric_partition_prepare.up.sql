@@ -0,0 +1,21 @@
+-- migrate: no transaction
+/* Підготовка таблиці metric до секціонування (міграція 0010) без тривалих блокувань: перевірка обмеження та
+   побудова індексів йдуть паралельно зі збором метрик, тому 0010 приєднує таблицю як секцію без перевірки рядків.
+   Межа секції - початок місяця, що настане через два місяці; виміри до неї потрапляють у цю секцію */
+DELETE FROM public.metric WHERE timemetric IS NULL;
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'metric_legacy_bound_check') THEN
+		EXECUTE format('ALTER TABLE public.metric ADD CONSTRAINT metric_legacy_bound_check '
+			'CHECK (timemetric IS NOT NULL AND timemetric < %L) NOT VALID',
+			date_trunc('month', now()) + interval '2 months');
+	END IF;
+END
+$$;
+
+ALTER TABLE public.metric VALIDATE CONSTRAINT metric_legacy_bound_check;
+
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS metric_legacy_pkey
+    ON public.metric USING btree (idvideo, timemetric, id);
+CREATE INDEX CONCURRENTLY IF NOT EXISTS metric_legacy_timemetric_idx ON public.metric USING brin (timemetric);
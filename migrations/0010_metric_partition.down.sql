@@ -0,0 +1,19 @@
+/* Виміри всіх секцій копіюються в звичайну таблицю metric. Архівні таблиці (схема metric_archive) залишаються */
+DROP FUNCTION IF EXISTS public.archive_metric_partitions(timestamp with time zone);
+DROP FUNCTION IF EXISTS public.create_metric_partitions(integer);
+DROP VIEW IF EXISTS public.metric_partitions;
+
+CREATE TABLE public.metric_plain (LIKE public.metric INCLUDING DEFAULTS);
+INSERT INTO public.metric_plain SELECT * FROM public.metric;
+
+ALTER SEQUENCE public.metrics_id_seq OWNED BY NONE;
+DROP TABLE public.metric;
+
+ALTER TABLE public.metric_plain RENAME TO metric;
+ALTER SEQUENCE public.metrics_id_seq OWNED BY public.metric.id;
+ALTER TABLE public.metric ADD CONSTRAINT metrics_pkey PRIMARY KEY (id);
+ALTER TABLE public.metric ADD CONSTRAINT metrics_idv_fkey FOREIGN KEY (idvideo) REFERENCES public.video(id);
+CREATE UNIQUE INDEX metric_legacy_pkey ON public.metric USING btree (idvideo, timemetric, id);
+CREATE INDEX metric_legacy_timemetric_idx ON public.metric USING brin (timemetric);
+ALTER TABLE public.metric ADD CONSTRAINT metric_legacy_bound_check
+    CHECK (timemetric IS NOT NULL AND timemetric < 'infinity');
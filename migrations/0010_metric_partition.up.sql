@@ -0,0 +1,102 @@
+/* Таблиця metric секціонується за часом виміру по місяцях. Виміри, збережені до міграції, залишаються в секції
+   metric_legacy. Нові секції наперед створює колектор (create_metric_partitions), старі секції, вже зведені
+   в metric_hourly, від'єднуються та переносяться в схему metric_archive (archive_metric_partitions).
+   Первинний ключ (idvideo, timemetric, id) є і індексом для вибірки метрик відео за період, BRIN-індекс за часом -
+   для зведень та видалення сирих метрик */
+ALTER TABLE public.metric RENAME TO metric_legacy;
+
+/* Обмеження metric_legacy_bound_check вже перевірене, тому NOT NULL та приєднання секції не читають рядки */
+ALTER TABLE public.metric_legacy ALTER COLUMN timemetric SET NOT NULL;
+ALTER TABLE public.metric_legacy DROP CONSTRAINT metrics_pkey;
+ALTER TABLE public.metric_legacy ADD CONSTRAINT metric_legacy_pkey PRIMARY KEY USING INDEX metric_legacy_pkey;
+
+CREATE TABLE public.metric (
+    LIKE public.metric_legacy INCLUDING DEFAULTS,
+    CONSTRAINT metric_pkey PRIMARY KEY (idvideo, timemetric, id),
+    CONSTRAINT metric_idvideo_fkey FOREIGN KEY (idvideo) REFERENCES public.video(id)
+) PARTITION BY RANGE (timemetric);
+
+ALTER SEQUENCE public.metrics_id_seq OWNED BY public.metric.id;
+
+DO $$
+BEGIN
+	EXECUTE format('ALTER TABLE public.metric ATTACH PARTITION public.metric_legacy FOR VALUES FROM (MINVALUE) TO (%L)',
+		date_trunc('month', now()) + interval '2 months');
+END
+$$;
+
+ALTER TABLE public.metric_legacy DROP CONSTRAINT metric_legacy_bound_check;
+
+CREATE INDEX metric_timemetric_idx ON public.metric USING brin (timemetric);
+
+CREATE SCHEMA IF NOT EXISTS metric_archive;
+
+/* Секції таблиці metric та верхні межі їх інтервалів */
+CREATE VIEW public.metric_partitions AS
+SELECT c.relname AS name,
+       (regexp_match(pg_get_expr(c.relpartbound, c.oid), 'TO \(''([^'']+)''\)'))[1]::timestamp with time zone AS bound
+  FROM pg_inherits i
+  JOIN pg_class c ON c.oid = i.inhrelid
+  WHERE i.inhparent = 'public.metric'::regclass;
+
+/* Створює місячні секції metric від кінця останньої секції до кінця місяця, що настане через _months місяців.
+   Повертає кількість створених секцій */
+CREATE OR REPLACE FUNCTION public.create_metric_partitions(
+    IN _months integer)
+  RETURNS integer AS
+$BODY$
+  DECLARE _from timestamp with time zone;
+  DECLARE _to timestamp with time zone;
+  DECLARE _count integer := 0;
+
+  BEGIN
+	SELECT COALESCE(MAX(bound), date_trunc('month', now())) FROM metric_partitions INTO _from;
+
+	WHILE _from < date_trunc('month', now()) + make_interval(months => _months + 1) LOOP
+		_to := date_trunc('month', _from) + interval '1 month';
+		EXECUTE format('CREATE TABLE public.%I PARTITION OF public.metric FOR VALUES FROM (%L) TO (%L)',
+			'metric_' || to_char(_from, 'YYYY_MM'), _from, _to);
+		_from := _to;
+		_count := _count + 1;
+	END LOOP;
+
+	RETURN _count;
+  END;
+$BODY$
+  LANGUAGE plpgsql;
+
+/* Від'єднує секції metric, всі виміри яких старіші за _before та вже зведені в metric_hourly, і переносить їх
+   в схему metric_archive. Зовнішні ключі архівних таблиць видаляються, щоб вони не заважали видаленню відео.
+   Повертає назви перенесених секцій */
+CREATE OR REPLACE FUNCTION public.archive_metric_partitions(
+    IN _before timestamp with time zone)
+  RETURNS SETOF text AS
+$BODY$
+  DECLARE _rolled timestamp with time zone;
+  DECLARE _part record;
+  DECLARE _fkey record;
+
+  BEGIN
+	SELECT MAX(bucket) - interval '1 hour' FROM metric_hourly INTO _rolled;
+	IF _rolled IS NULL THEN
+		RETURN;
+	END IF;
+
+	FOR _part IN SELECT name FROM metric_partitions WHERE bound <= LEAST(_before, _rolled) ORDER BY bound
+	LOOP
+		EXECUTE format('ALTER TABLE public.metric DETACH PARTITION public.%I', _part.name);
+		EXECUTE format('ALTER TABLE public.%I SET SCHEMA metric_archive', _part.name);
+
+		FOR _fkey IN SELECT conname FROM pg_constraint
+		  WHERE conrelid = format('metric_archive.%I', _part.name)::regclass AND contype = 'f'
+		LOOP
+			EXECUTE format('ALTER TABLE metric_archive.%I DROP CONSTRAINT %I', _part.name, _fkey.conname);
+		END LOOP;
+
+		RETURN NEXT _part.name;
+	END LOOP;
+  END;
+$BODY$
+  LANGUAGE plpgsql;
+
+SELECT public.create_metric_partitions(2);
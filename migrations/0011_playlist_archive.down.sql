@@ -0,0 +1 @@
+ALTER TABLE public.playlist DROP COLUMN IF EXISTS timearchive;
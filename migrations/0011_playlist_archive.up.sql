@@ -0,0 +1,4 @@
+/* Видалення плейлиста переносить його в архів (timearchive - час перенесення): збір метрик припиняється, плейлист
+   та його відео не показуються в списках, але дані залишаються і плейлист можна відновити. Остаточно плейлист з
+   відео та метриками видаляє окрема операція очищення */
+ALTER TABLE public.playlist ADD COLUMN IF NOT EXISTS timearchive timestamp with time zone;
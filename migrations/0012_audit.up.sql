@@ -0,0 +1,20 @@
+/* Журнал аудиту змін, зроблених через API адміністратора: хто (actor - користувач API, NULL - з командного рядка),
+   що (action, наприклад playlist.update), над чим (target - id плейлиста, запиту або ім'я користувача), стан
+   об'єкта до та після зміни (NULL - об'єкта не було або його видалено), звідки (addr - адреса клієнта).
+   Запис додається в тій самій транзакції, що і зміна. idpl - плейлист, якого стосується зміна, для відбору */
+CREATE TABLE IF NOT EXISTS public.audit (
+    id bigserial NOT NULL,
+    timeaudit timestamp with time zone DEFAULT now() NOT NULL,
+    actor character varying(64),
+    action character varying(32) NOT NULL,
+    target character varying(64) NOT NULL,
+    idpl character(24),
+    before jsonb,
+    after jsonb,
+    addr character varying(64),
+    CONSTRAINT audit_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS audit_timeaudit_idx ON public.audit USING btree (timeaudit);
+CREATE INDEX IF NOT EXISTS audit_actor_idx ON public.audit USING btree (actor, timeaudit);
+CREATE INDEX IF NOT EXISTS audit_idpl_idx ON public.audit USING btree (idpl, timeaudit);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS public.video_chid_idx;
+DROP INDEX IF EXISTS public.video_search_idx;
+ALTER TABLE public.video DROP COLUMN IF EXISTS search;
+DROP TEXT SEARCH CONFIGURATION IF EXISTS public.ukrainian;
+DROP TEXT SEARCH DICTIONARY IF EXISTS public.ukrainian_hunspell;
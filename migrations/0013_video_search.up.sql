@@ -0,0 +1,31 @@
+/* Повнотекстовий пошук по назві та опису відео (/view/search). Текст індексується двома конфігураціями:
+   english (стемінг англійських слів) та ukrainian. Вбудованого словника української мови в Postgres немає,
+   тому ukrainian використовує hunspell-словник uk_ua, якщо його файли встановлені (uk_ua.dict, uk_ua.affix,
+   ukrainian.stop в $SHAREDIR/tsearch_data), інакше - слова без стемінгу (як simple). Словник можна встановити
+   пізніше, створити його та змінити конфігурацію так само, як нижче, після чого перерахувати вектори:
+   UPDATE video SET title = title */
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'ukrainian' AND cfgnamespace = 'public'::regnamespace) THEN
+    CREATE TEXT SEARCH CONFIGURATION public.ukrainian (COPY = pg_catalog.simple);
+    BEGIN
+      CREATE TEXT SEARCH DICTIONARY public.ukrainian_hunspell (
+        TEMPLATE = ispell, DictFile = uk_ua, AffFile = uk_ua, StopWords = ukrainian);
+      ALTER TEXT SEARCH CONFIGURATION public.ukrainian
+        ALTER MAPPING FOR word, hword, hword_part WITH public.ukrainian_hunspell, simple;
+    EXCEPTION WHEN others THEN
+      RAISE NOTICE 'hunspell dictionary uk_ua is not installed, Ukrainian words are indexed without stemming';
+    END;
+  END IF;
+END
+$$;
+
+/* Вага A - назва, B - опис */
+ALTER TABLE public.video ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english'::regconfig, COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('public.ukrainian'::regconfig, COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('english'::regconfig, COALESCE(description, '')), 'B') ||
+    setweight(to_tsvector('public.ukrainian'::regconfig, COALESCE(description, '')), 'B')) STORED;
+
+CREATE INDEX IF NOT EXISTS video_search_idx ON public.video USING gin (search);
+CREATE INDEX IF NOT EXISTS video_chid_idx ON public.video USING btree (chid);
@@ -0,0 +1,4 @@
+DROP FUNCTION IF EXISTS public.refresh_video_gain();
+DROP FUNCTION IF EXISTS public.metric_point_after(character, timestamp with time zone);
+DROP FUNCTION IF EXISTS public.metric_point_before(character, timestamp with time zone);
+DROP TABLE IF EXISTS public.video_gain;
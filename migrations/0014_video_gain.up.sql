@@ -0,0 +1,124 @@
+/* Приріст переглядів, лайків та коментарів відео за ковзні вікна 1h, 24h, 7d для рейтингів (/view/top).
+   Зведення перераховує колектор (periodGain) функцією refresh_video_gain, бекенд тільки читає готові рядки.
+   Приріст - різниця між останнім виміром та значенням на початок вікна. Виміри рідкі (незмінні метрики
+   зберігаються рідше, старі сирі метрики замінені зведеннями metric_hourly), тому значення на початок вікна
+   інтерполюється між найближчими вимірами до та після нього. Якщо вимірів до початку вікна немає, відео,
+   опубліковане у вікні, рахується від нуля, інше - від першого виміру (timefrom пізніше за початок вікна) */
+CREATE TABLE IF NOT EXISTS public.video_gain (
+    period character varying(3) NOT NULL, /* вікно: 1h, 24h, 7d */
+    idvideo character(11) NOT NULL,
+    timefrom timestamp with time zone NOT NULL, /* з якого часу рахується приріст */
+    timelast timestamp with time zone NOT NULL, /* час останнього виміру */
+    viewcount bigint NOT NULL, /* останні значення лічильників */
+    likecount bigint NOT NULL,
+    commentcount bigint NOT NULL,
+    viewgain bigint NOT NULL,
+    likegain bigint NOT NULL,
+    commentgain bigint NOT NULL,
+    timeupdate timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT video_gain_pkey PRIMARY KEY (period, idvideo),
+    CONSTRAINT video_gain_idvideo_fkey FOREIGN KEY (idvideo) REFERENCES public.video(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS video_gain_view_idx ON public.video_gain USING btree (period, viewgain DESC);
+CREATE INDEX IF NOT EXISTS video_gain_like_idx ON public.video_gain USING btree (period, likegain DESC);
+CREATE INDEX IF NOT EXISTS video_gain_comment_idx ON public.video_gain USING btree (period, commentgain DESC);
+
+/* Останній вимір відео не пізніше _t: з сирих метрик, а якщо їх вже видалено - зі зведень */
+CREATE OR REPLACE FUNCTION public.metric_point_before(
+    IN _idv character,
+    IN _t timestamp with time zone)
+  RETURNS TABLE(t timestamp with time zone, viewcount bigint, likecount bigint, commentcount bigint) AS
+$BODY$
+	SELECT * FROM (
+		(SELECT m.timemetric, m.viewcount, m.likecount, m.commentcount FROM metric m
+		  WHERE m.idvideo = _idv AND m.timemetric <= _t ORDER BY m.timemetric DESC LIMIT 1)
+		UNION ALL
+		(SELECT h.timelast, h.viewlast, h.likelast, h.commentlast FROM metric_hourly h
+		  WHERE h.idvideo = _idv AND h.bucket <= _t AND h.timelast <= _t ORDER BY h.bucket DESC LIMIT 1)
+	) p ORDER BY 1 DESC LIMIT 1;
+$BODY$
+  LANGUAGE sql STABLE;
+
+/* Перший вимір відео пізніше _t. Зі зведення береться перший вимір інтервалу, якщо він пізніше _t, інакше
+   останній */
+CREATE OR REPLACE FUNCTION public.metric_point_after(
+    IN _idv character,
+    IN _t timestamp with time zone)
+  RETURNS TABLE(t timestamp with time zone, viewcount bigint, likecount bigint, commentcount bigint) AS
+$BODY$
+	SELECT * FROM (
+		(SELECT m.timemetric, m.viewcount, m.likecount, m.commentcount FROM metric m
+		  WHERE m.idvideo = _idv AND m.timemetric > _t ORDER BY m.timemetric LIMIT 1)
+		UNION ALL
+		(SELECT CASE WHEN h.timefirst > _t THEN h.timefirst ELSE h.timelast END,
+		        CASE WHEN h.timefirst > _t THEN h.viewfirst ELSE h.viewlast END,
+		        CASE WHEN h.timefirst > _t THEN h.likefirst ELSE h.likelast END,
+		        CASE WHEN h.timefirst > _t THEN h.commentfirst ELSE h.commentlast END
+		  FROM metric_hourly h
+		  WHERE h.idvideo = _idv AND h.bucket >= date_trunc('hour', _t) AND h.timelast > _t
+		  ORDER BY h.bucket LIMIT 1)
+	) p ORDER BY 1 LIMIT 1;
+$BODY$
+  LANGUAGE sql STABLE;
+
+/* Перерахувати приріст за вікна 1h, 24h, 7d. Рахуються тільки відео з вимірами у вікні: сирі метрики
+   переглядаються тільки після останнього зведеного інтервалу, старіші виміри беруться з metric_hourly.
+   Зведення замінюється в одній транзакції, тому читачі завжди бачать повний попередній або новий стан.
+   Повертає кількість рядків зведення */
+CREATE OR REPLACE FUNCTION public.refresh_video_gain()
+  RETURNS integer AS
+$BODY$
+  DECLARE _now timestamp with time zone := now();
+  DECLARE _rolled timestamp with time zone;
+  DECLARE _period text;
+  DECLARE _from timestamp with time zone;
+  DECLARE _count integer := 0;
+  DECLARE _rows integer;
+
+  BEGIN
+	SELECT COALESCE(MAX(bucket), '-infinity') FROM metric_hourly INTO _rolled;
+
+	DELETE FROM video_gain;
+
+	FOREACH _period IN ARRAY ARRAY['1h', '24h', '7d']
+	LOOP
+		_from := _now - CASE _period WHEN '1h' THEN interval '1 hour' WHEN '24h' THEN interval '24 hours'
+			ELSE interval '7 days' END;
+
+		INSERT INTO video_gain (period, idvideo, timefrom, timelast, viewcount, likecount, commentcount,
+			viewgain, likegain, commentgain, timeupdate)
+		SELECT _period, c.idvideo, GREATEST(_from, b.t), l.t, l.viewcount, l.likecount, l.commentcount,
+			l.viewcount - (b.viewcount + round((a.viewcount - b.viewcount) * f.frac)),
+			l.likecount - (b.likecount + round((a.likecount - b.likecount) * f.frac)),
+			l.commentcount - (b.commentcount + round((a.commentcount - b.commentcount) * f.frac)),
+			_now
+		  FROM (SELECT m.idvideo FROM metric m WHERE m.timemetric > GREATEST(_from, _rolled)
+		        UNION
+		        SELECT h.idvideo FROM metric_hourly h
+		          WHERE h.bucket >= date_trunc('hour', _from) AND h.timelast > _from) c
+		  JOIN video v ON v.id = c.idvideo
+		  CROSS JOIN LATERAL metric_point_before(c.idvideo, _now) l
+		  CROSS JOIN LATERAL metric_point_after(c.idvideo, _from) a
+		  /* Точка відліку: останній вимір до початку вікна; для відео, опублікованого у вікні, - нуль на час
+		     публікації; інакше - перший вимір у вікні */
+		  CROSS JOIN LATERAL (
+			SELECT 1 AS n, p.* FROM metric_point_before(c.idvideo, _from) p
+			UNION ALL
+			SELECT 2, v.publishedat, 0, 0, 0 WHERE v.publishedat >= _from
+			UNION ALL
+			SELECT 3, a.t, a.viewcount, a.likecount, a.commentcount
+			ORDER BY n LIMIT 1) b
+		  /* Частка відрізку між точкою відліку та першим виміром у вікні, яка припадає до початку вікна */
+		  CROSS JOIN LATERAL (
+			SELECT CASE WHEN b.t < _from AND a.t > b.t
+				THEN EXTRACT(EPOCH FROM _from - b.t) / EXTRACT(EPOCH FROM a.t - b.t) ELSE 0 END AS frac) f;
+
+		GET DIAGNOSTICS _rows = ROW_COUNT;
+		_count := _count + _rows;
+	END LOOP;
+
+	RETURN _count;
+  END;
+$BODY$
+  LANGUAGE plpgsql;
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS tr_notify_video ON public.video;
+DROP TRIGGER IF EXISTS tr_notify_metric ON public.metric;
+DROP FUNCTION IF EXISTS public.notify_video();
+DROP FUNCTION IF EXISTS public.notify_metric();
+DROP INDEX IF EXISTS public.video_timeadd_idx;
+ALTER TABLE public.video DROP COLUMN IF EXISTS timeadd;
@@ -0,0 +1,56 @@
+/* Сповіщення бекенда про нові виміри метрик та нові відео (живий потік /view/stream). Тригери надсилають NOTIFY
+   з json-описом рядків в каналах new_metric та new_video. Сповіщення доставляються тільки після фіксації
+   транзакції і тільки підключеним слухачам, тому пропущені події бекенд при відновленні потоку бере з таблиць:
+   метрики - за timemetric, нові відео - за часом додавання timeadd */
+ALTER TABLE public.video ADD COLUMN IF NOT EXISTS timeadd timestamp with time zone; /* для старих відео невідомий */
+ALTER TABLE public.video ALTER COLUMN timeadd SET DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS video_timeadd_idx ON public.video USING btree (timeadd);
+
+/* Колектор додає виміри пакетами (COPY), тому тригер metric спрацьовує раз на інструкцію і читає нові рядки
+   з перехідної таблиці new_metric. Сповіщення - json-масив вимірів, не більше 30 в одному сповіщенні, щоб не
+   перевищити обмеження розміру NOTIFY (8000 байт) */
+CREATE OR REPLACE FUNCTION public.notify_metric() RETURNS TRIGGER AS
+$BODY$
+DECLARE
+	batch text;
+BEGIN
+	FOR batch IN
+		SELECT json_agg(m.metric ORDER BY m.pos)::text FROM (
+			SELECT json_build_object(
+				'id', n.idvideo, 'idpl', TRIM(v.idpl),
+				'comment', n.commentcount, 'like', n.likecount, 'dislike', n.dislikecount, 'view', n.viewcount,
+				'mtime', n.timemetric) AS metric,
+				row_number() OVER (ORDER BY n.timemetric) - 1 AS pos
+			FROM new_metric n LEFT JOIN video v ON v.id = n.idvideo
+		) m GROUP BY m.pos / 30 ORDER BY m.pos / 30
+	LOOP
+		PERFORM pg_notify('new_metric', batch);
+	END LOOP;
+	RETURN NULL;
+END
+$BODY$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION public.notify_video() RETURNS TRIGGER AS
+$BODY$
+BEGIN
+	PERFORM pg_notify('new_video', json_build_object(
+		'id', NEW.id, 'idpl', TRIM(NEW.idpl), 'title', TRIM(NEW.title), 'publishedat', NEW.publishedat,
+		'timeadd', NEW.timeadd)::text);
+	RETURN NULL;
+END
+$BODY$ LANGUAGE plpgsql;
+
+/* Тригер секціонованої таблиці metric діє і на секції, створені пізніше */
+DROP TRIGGER IF EXISTS tr_notify_metric ON public.metric;
+
+CREATE TRIGGER tr_notify_metric
+AFTER INSERT ON public.metric
+    REFERENCING NEW TABLE AS new_metric
+    FOR EACH STATEMENT EXECUTE PROCEDURE public.notify_metric();
+
+DROP TRIGGER IF EXISTS tr_notify_video ON public.video;
+
+CREATE TRIGGER tr_notify_video
+AFTER INSERT ON public.video
+    FOR EACH ROW EXECUTE PROCEDURE public.notify_video();
@@ -0,0 +1,402 @@
+// Нумеровані міграції схеми БД, спільні для бекенда та колектора. Міграція складається з файлів
+// <номер>_<назва>.up.sql та <номер>_<назва>.down.sql, які вбудовуються в програму. Застосовані міграції
+// записуються в таблицю schema_migrations, версія схеми - номер останньої застосованої міграції
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CREATE_MIGRATIONS = "CREATE TABLE IF NOT EXISTS public.schema_migrations (" +
+	"version integer NOT NULL, name character varying(100) NOT NULL, " +
+	"timeapply timestamp with time zone DEFAULT now() NOT NULL, " +
+	"CONSTRAINT schema_migrations_pkey PRIMARY KEY (version))"
+const MIGRATIONS_EXISTS = "SELECT to_regclass('public.schema_migrations') IS NOT NULL"
+const GET_VERSION = "SELECT COALESCE(MAX(version), 0) FROM schema_migrations"
+const GET_APPLIED = "SELECT version, timeapply FROM schema_migrations"
+const INSERT_MIGRATION = "INSERT INTO schema_migrations ( version, name ) VALUES ( $1, $2 )"
+const DELETE_MIGRATION = "DELETE FROM schema_migrations WHERE version = $1"
+
+// Бекенд та колектор можуть запускати міграції одночасно, тому кожна міграція виконується під блокуванням
+const LOCK_MIGRATIONS = "SELECT pg_advisory_xact_lock(7140652)"
+const LOCK_MIGRATIONS_SESSION = "SELECT pg_advisory_lock(7140652)"
+const UNLOCK_MIGRATIONS = "SELECT pg_advisory_unlock(7140652)"
+
+// Міграція, файл якої починається з цього рядка, виконується без транзакції, по одній команді. Потрібна для команд,
+// які не можна виконувати в транзакції (CREATE INDEX CONCURRENTLY), і для довгих операцій, які не повинні тримати
+// блокування до кінця міграції. Така міграція повинна бути повторюваною: після помилки вона виконується заново
+const NO_TRANSACTION = "-- migrate: no transaction"
+
+// Команди міграції (налаштування migrate)
+const COMMAND_UP = "up"
+const COMMAND_DOWN = "down"
+const COMMAND_STATUS = "status"
+
+//go:embed *.sql
+var files embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// Стан міграції, Applied = nil - міграція не застосована
+type MigrationStatus struct {
+	*Migration
+	Applied *time.Time
+}
+
+// Всі міграції за зростанням номера
+var migrations []*Migration
+
+func init() {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		panic(err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction := strings.TrimSuffix(name, ".sql"), ""
+		switch {
+		case strings.HasSuffix(base, ".up"):
+			base, direction = strings.TrimSuffix(base, ".up"), COMMAND_UP
+		case strings.HasSuffix(base, ".down"):
+			base, direction = strings.TrimSuffix(base, ".down"), COMMAND_DOWN
+		default:
+			panic("migration " + name + " must end with .up.sql or .down.sql")
+		}
+
+		number, title, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil || version <= 0 || title == "" {
+			panic("migration " + name + " must be named <number>_<name>")
+		}
+
+		body, err := files.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if direction == COMMAND_UP {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	for _, m := range byVersion {
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	// номери міграцій йдуть підряд з 1, кожна міграція має обидва файли
+	for i, m := range migrations {
+		if m.Version != i+1 || m.up == "" || m.down == "" {
+			panic(fmt.Sprintf("migration %04d_%v is missing or incomplete", i+1, m.Name))
+		}
+	}
+}
+
+// Версія схеми, яку потребує програма
+func Latest() int {
+	return len(migrations)
+}
+
+// Поточна версія схеми БД, 0 - міграції не застосовувались
+func Version(db *sql.DB) (int, error) {
+	var exists bool
+	if err := db.QueryRow(MIGRATIONS_EXISTS).Scan(&exists); err != nil || !exists {
+		return 0, err
+	}
+
+	var version int
+	err := db.QueryRow(GET_VERSION).Scan(&version)
+	return version, err
+}
+
+// Перевірити, що схема БД відповідає програмі: застосовані всі її міграції і жодної новішої
+func Check(db *sql.DB) error {
+	version, err := Version(db)
+	if err != nil {
+		return fmt.Errorf("cannot get database schema version: %v", err)
+	}
+	if version < Latest() {
+		return fmt.Errorf("database schema version %v is older than required %v, run with --migrate=up", version, Latest())
+	}
+	if version > Latest() {
+		return fmt.Errorf("database schema version %v is newer than supported %v, update the program", version, Latest())
+	}
+	return nil
+}
+
+// Застосувати всі незастосовані міграції, повертає застосовані
+func Up(db *sql.DB) ([]*Migration, error) {
+	applied := []*Migration{}
+	for _, m := range migrations {
+		done, err := apply(db, m, COMMAND_UP)
+		if err != nil {
+			return applied, err
+		}
+		if done {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// Відкотити останню застосовану міграцію, nil - міграції не застосовувались
+func Down(db *sql.DB) (*Migration, error) {
+	version, err := Version(db)
+	if err != nil || version == 0 {
+		return nil, err
+	}
+	if version > Latest() {
+		return nil, fmt.Errorf("database schema version %v is newer than supported %v", version, Latest())
+	}
+
+	m := migrations[version-1]
+	done, err := apply(db, m, COMMAND_DOWN)
+	if err != nil || !done {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Tx та Conn, в межах яких виконується міграція
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Застосувати (up) або відкотити (down) міграцію в окремій транзакції (або без неї, див. NO_TRANSACTION). Версія
+// перевіряється вже під блокуванням, тому міграція, яку встигла виконати інша програма, пропускається (false)
+func apply(db *sql.DB, m *Migration, direction string) (bool, error) {
+	ctx := context.Background()
+
+	body := m.up
+	if direction == COMMAND_DOWN {
+		body = m.down
+	}
+
+	if !strings.HasPrefix(body, NO_TRANSACTION) {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return false, err
+		}
+		defer tx.Rollback()
+
+		if _, err = tx.ExecContext(ctx, LOCK_MIGRATIONS); err != nil {
+			return false, err
+		}
+		done, err := execute(ctx, tx, m, direction, []string{body})
+		if err != nil || !done {
+			return false, err
+		}
+		return true, tx.Commit()
+	}
+
+	// блокування сесії тримається на одному з'єднанні
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, LOCK_MIGRATIONS_SESSION); err != nil {
+		return false, err
+	}
+	defer conn.ExecContext(ctx, UNLOCK_MIGRATIONS)
+
+	return execute(ctx, conn, m, direction, statements(body))
+}
+
+// Виконати команди міграції та записати її стан
+func execute(ctx context.Context, ex executor, m *Migration, direction string, commands []string) (bool, error) {
+	if _, err := ex.ExecContext(ctx, CREATE_MIGRATIONS); err != nil {
+		return false, err
+	}
+
+	var version int
+	if err := ex.QueryRowContext(ctx, GET_VERSION).Scan(&version); err != nil {
+		return false, err
+	}
+	if (direction == COMMAND_UP && version >= m.Version) || (direction == COMMAND_DOWN && version != m.Version) {
+		return false, nil
+	}
+
+	for _, command := range commands {
+		if _, err := ex.ExecContext(ctx, command); err != nil {
+			if direction == COMMAND_DOWN {
+				return false, fmt.Errorf("rollback of migration %04d_%v: %v", m.Version, m.Name, err)
+			}
+			return false, fmt.Errorf("migration %04d_%v: %v", m.Version, m.Name, err)
+		}
+	}
+
+	var err error
+	if direction == COMMAND_UP {
+		_, err = ex.ExecContext(ctx, INSERT_MIGRATION, m.Version, m.Name)
+	} else {
+		_, err = ex.ExecContext(ctx, DELETE_MIGRATION, m.Version)
+	}
+	return err == nil, err
+}
+
+// Початок або кінець тексту в доларових лапках ($$, $BODY$)
+var dollarQuote = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// Розбити текст міграції на окремі команди по ";". Крапка з комою всередині рядків, ідентифікаторів в лапках,
+// коментарів та тексту в доларових лапках (тіла функцій та блоків DO) команду не завершує. Команди, які
+// складаються тільки з коментарів, пропускаються
+func statements(body string) []string {
+	commands := []string{}
+	start, empty := 0, true
+
+	// позиція після кінця фрагмента, який починається з i та закінчується end
+	skip := func(i int, end string) int {
+		if pos := strings.Index(body[i:], end); pos >= 0 {
+			return i + pos + len(end)
+		}
+		return len(body)
+	}
+
+	for i := 0; i < len(body); {
+		switch c := body[i]; {
+		case strings.HasPrefix(body[i:], "--"):
+			i = skip(i, "\n")
+			continue
+		case strings.HasPrefix(body[i:], "/*"):
+			i = skip(i+2, "*/")
+			continue
+		case c == '\'' || c == '"':
+			// подвоєна лапка всередині рядка - це закриття та відкриття рядка, результат той самий
+			i = skip(i+1, string(c))
+		case c == '$' && dollarQuote.MatchString(body[i:]):
+			tag := dollarQuote.FindString(body[i:])
+			i = skip(i+len(tag), tag)
+		case c == ';':
+			if !empty {
+				commands = append(commands, strings.TrimSpace(body[start:i+1]))
+			}
+			i++
+			start, empty = i, true
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		default:
+			i++
+		}
+		empty = false
+	}
+	if !empty {
+		commands = append(commands, strings.TrimSpace(body[start:]))
+	}
+	return commands
+}
+
+// Стан всіх міграцій програми
+func Status(db *sql.DB) ([]*MigrationStatus, error) {
+	applied := make(map[int]time.Time)
+
+	var exists bool
+	if err := db.QueryRow(MIGRATIONS_EXISTS).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		rows, err := db.Query(GET_APPLIED)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var version int
+			var timeApply time.Time
+			if err = rows.Scan(&version, &timeApply); err != nil {
+				return nil, err
+			}
+			applied[version] = timeApply
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	response := make([]*MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := &MigrationStatus{Migration: m}
+		if timeApply, ok := applied[m.Version]; ok {
+			status.Applied = &timeApply
+		}
+		response = append(response, status)
+	}
+	return response, nil
+}
+
+// Виконати команду міграції (COMMAND_*) та вивести результат в w
+func Run(db *sql.DB, command string, w io.Writer) error {
+	switch command {
+	case COMMAND_UP:
+		applied, err := Up(db)
+		for _, m := range applied {
+			fmt.Fprintf(w, "applied %04d_%v\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(w, "no pending migrations")
+		}
+	case COMMAND_DOWN:
+		m, err := Down(db)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			fmt.Fprintln(w, "no applied migrations")
+			return nil
+		}
+		fmt.Fprintf(w, "rolled back %04d_%v\n", m.Version, m.Name)
+	case COMMAND_STATUS:
+		statuses, err := Status(db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied != nil {
+				applied = "applied " + s.Applied.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d_%-24v %v\n", s.Version, s.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected %v, %v or %v", command,
+			COMMAND_UP, COMMAND_DOWN, COMMAND_STATUS)
+	}
+
+	version, err := Version(db)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "schema version: %v, required: %v\n", version, Latest())
+	return nil
+}
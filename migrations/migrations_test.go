@@ -0,0 +1,114 @@
+package migrations
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"one command per line", "CREATE TABLE a (id int);\nDROP TABLE b;\n",
+			[]string{"CREATE TABLE a (id int);", "DROP TABLE b;"}},
+		{"several commands on a line", "SELECT 1; SELECT 2;", []string{"SELECT 1;", "SELECT 2;"}},
+		{"command over several lines", "CREATE INDEX a_idx\n    ON a (id);",
+			[]string{"CREATE INDEX a_idx\n    ON a (id);"}},
+		{"last command without semicolon", "SELECT 1;\nSELECT 2\n", []string{"SELECT 1;", "SELECT 2"}},
+		{"semicolon in string", "INSERT INTO a VALUES ('x;\ny', 'it''s;');",
+			[]string{"INSERT INTO a VALUES ('x;\ny', 'it''s;');"}},
+		{"semicolon in quoted identifier", `CREATE TABLE "a;b" (id int);`, []string{`CREATE TABLE "a;b" (id int);`}},
+		{"semicolon in line comment", "SELECT 1 -- one;\n, 2;", []string{"SELECT 1 -- one;\n, 2;"}},
+		{"semicolon in block comment", "SELECT /* one;\n two; */ 1;", []string{"SELECT /* one;\n two; */ 1;"}},
+		{"comment before command", "-- comment;\nSELECT 1;", []string{"-- comment;\nSELECT 1;"}},
+		{"comments only", "SELECT 1;\n-- end;\n/* end; */\n", []string{"SELECT 1;"}},
+		{"empty commands", ";\n ; SELECT 1;;", []string{"SELECT 1;"}},
+		{"dollar-quoted body", "DO $$\nBEGIN\n\tPERFORM 1;\nEND\n$$;\nSELECT 1;",
+			[]string{"DO $$\nBEGIN\n\tPERFORM 1;\nEND\n$$;", "SELECT 1;"}},
+		{"tagged dollar-quoted body", "CREATE FUNCTION f() RETURNS void AS $BODY$\nBEGIN\n\tRAISE NOTICE '$$;';\n" +
+			"END\n$BODY$ LANGUAGE plpgsql;",
+			[]string{"CREATE FUNCTION f() RETURNS void AS $BODY$\nBEGIN\n\tRAISE NOTICE '$$;';\nEND\n$BODY$ LANGUAGE plpgsql;"}},
+		{"positional parameter is not a quote", "PREPARE p AS SELECT $1;\nSELECT 2;",
+			[]string{"PREPARE p AS SELECT $1;", "SELECT 2;"}},
+		{"no transaction marker", NO_TRANSACTION + "\nCREATE INDEX CONCURRENTLY a_idx ON a (id);\nVACUUM a;",
+			[]string{NO_TRANSACTION + "\nCREATE INDEX CONCURRENTLY a_idx ON a (id);", "VACUUM a;"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := statements(tt.body)
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+				t.Errorf("statements = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Міграції без транзакції виконуються по одній команді, тому кожна команда повинна бути повною
+func TestStatementsNoTransactionMigrations(t *testing.T) {
+	for _, m := range migrations {
+		for direction, body := range map[string]string{COMMAND_UP: m.up, COMMAND_DOWN: m.down} {
+			if !strings.HasPrefix(body, NO_TRANSACTION) {
+				continue
+			}
+			commands := statements(body)
+			if len(commands) == 0 {
+				t.Errorf("migration %04d_%v.%v has no commands", m.Version, m.Name, direction)
+			}
+			for _, command := range commands[:len(commands)-1] {
+				if !strings.HasSuffix(command, ";") {
+					t.Errorf("migration %04d_%v.%v: incomplete command %q", m.Version, m.Name, direction, command)
+				}
+			}
+		}
+	}
+}
+
+var migrationFile = regexp.MustCompile(`^(\d{4})_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+func TestMigrationFiles(t *testing.T) {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[int]string)
+	directions := make(map[int]map[string]bool)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			t.Errorf("migration file %v must be named NNNN_<name>.up.sql or NNNN_<name>.down.sql", entry.Name())
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		if name, ok := names[version]; ok && name != match[2] {
+			t.Errorf("migration %04d has different names: %v and %v", version, name, match[2])
+		}
+		names[version] = match[2]
+		if directions[version] == nil {
+			directions[version] = make(map[string]bool)
+		}
+		directions[version][match[3]] = true
+	}
+
+	for version := 1; version <= len(names); version++ {
+		if _, ok := names[version]; !ok {
+			t.Errorf("migration %04d is missing", version)
+			continue
+		}
+		for _, direction := range []string{COMMAND_UP, COMMAND_DOWN} {
+			if !directions[version][direction] {
+				t.Errorf("migration %04d_%v has no .%v.sql file", version, names[version], direction)
+			}
+		}
+	}
+
+	if Latest() != len(names) {
+		t.Errorf("Latest = %v, want %v", Latest(), len(names))
+	}
+}
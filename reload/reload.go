@@ -0,0 +1,201 @@
+// Перечитування налаштувань програми без перезапуску, спільне для бекенда та колектора. Налаштування, які
+// можна змінити під час роботи, задаються значеннями Value: їх читають через Get з будь-якої горутини,
+// а Reloader атомарно замінює значення після перечитування ini-файлу
+package reload
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Значення, яке можна змінити під час перечитування налаштувань (Value)
+type candidate interface {
+	propose(s string) error
+	settle(apply bool)
+}
+
+// Перечитування налаштувань програми
+type Reloader struct {
+	// Налаштування, які можна змінити під час роботи. Всі інші потребують перезапуску
+	reloadable map[string]bool
+	// Перевірка всіх налаштувань, повертає перелік помилок
+	validate func() []string
+	// Значення налаштування для виводу (секретні значення приховуються)
+	display func(name, value string) string
+
+	// Функції, які викликаються після успішного перечитування налаштувань
+	onReload []func()
+	mux      sync.Mutex
+}
+
+func New(reloadable map[string]bool, validate func() []string, display func(name, value string) string) *Reloader {
+	return &Reloader{reloadable: reloadable, validate: validate, display: display}
+}
+
+// Зареєструвати функцію, яка буде викликана після успішного перечитування налаштувань
+func (r *Reloader) OnReload(f func()) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.onReload = append(r.onReload, f)
+}
+
+// Перечитати ini-файл налаштувань та застосувати зміни. Якщо змінено налаштування, яке не можна змінити
+// під час роботи, або значення некоректне - не застосовується жодна зміна, повертається помилка з переліком
+// причин. Налаштування задані в командному рядку та в fixed (наприклад змінними оточення) мають пріоритет
+// над ini-файлом, тому не перечитуються. Налаштування, яке можна змінити під час роботи і яке видалене
+// з ini-файлу, повертається до значення за замовчуванням; видалення інших налаштувань ігнорується (вони
+// змінюються тільки після перезапуску). Нові значення перевіряються тими ж правилами, що й при запуску,
+// до того як їх побачать читачі. Повертає перелік змінених налаштувань у форматі "name: old -> new"
+func (r *Reloader) Reload(fixed map[string]bool) ([]string, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	values, err := ReadIniFile()
+	if err != nil {
+		return nil, err
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		if _, ok := values[f.Name]; !ok && r.reloadable[f.Name] {
+			values[f.Name] = f.DefValue
+		}
+	})
+
+	type change struct {
+		f        *flag.Flag
+		value    candidate
+		old, new string
+	}
+	changes := []change{}
+	rejected := []string{}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fromCommandLine := CommandLineFlags()
+	for _, name := range names {
+		f := flag.Lookup(name)
+		if f == nil {
+			rejected = append(rejected, "unknown setting "+name)
+			continue
+		}
+		if fromCommandLine[name] || fixed[name] {
+			continue
+		}
+
+		// значення порівнюються після нормалізації (наприклад 60s та 1m0s - однакові)
+		old := f.Value.String()
+		value, err := normalize(f, values[name])
+		if err != nil {
+			rejected = append(rejected, "invalid value for "+name+": "+err.Error())
+			continue
+		}
+
+		if value == old {
+			continue
+		}
+		c, ok := f.Value.(candidate)
+		if !r.reloadable[name] || !ok {
+			rejected = append(rejected, "setting "+name+" cannot be changed at runtime, restart is required")
+			continue
+		}
+		changes = append(changes, change{f, c, old, value})
+	}
+
+	if len(rejected) > 0 {
+		return nil, errors.New(strings.Join(rejected, "; "))
+	}
+
+	// нові значення перевіряються до застосування, правила читають їх через Value.Candidate
+	for _, c := range changes {
+		if err := c.value.propose(c.new); err != nil {
+			rejected = append(rejected, "invalid value for "+c.f.Name+": "+err.Error())
+		}
+	}
+	errs := rejected
+	if len(errs) == 0 {
+		errs = r.validate()
+	}
+	for _, c := range changes {
+		c.value.settle(len(errs) == 0)
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+
+	changed := []string{}
+	for _, c := range changes {
+		changed = append(changed, c.f.Name+": "+r.display(c.f.Name, c.old)+" -> "+r.display(c.f.Name, c.new))
+	}
+
+	if len(changed) > 0 {
+		for _, f := range r.onReload {
+			f()
+		}
+	}
+
+	return changed, nil
+}
+
+// Прочитати ini-файл налаштувань (задається параметром -config) у вигляді пар назва=значення
+func ReadIniFile() (map[string]string, error) {
+	configFlag := flag.Lookup("config")
+	if configFlag == nil || configFlag.Value.String() == "" {
+		return nil, errors.New("config file is not set, use -config")
+	}
+
+	file, err := os.Open(configFlag.Value.String())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '[' {
+			continue
+		}
+
+		pos := strings.Index(line, "=")
+		if pos < 0 {
+			return nil, errors.New("cannot parse config line: " + line)
+		}
+		name := strings.TrimSpace(line[:pos])
+		value := strings.TrimSpace(line[pos+1:])
+		if len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		values[name] = value
+	}
+
+	return values, scanner.Err()
+}
+
+// Налаштування задані в командному рядку
+func CommandLineFlags() map[string]bool {
+	names := make(map[string]bool)
+
+	for _, arg := range os.Args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if pos := strings.Index(name, "="); pos >= 0 {
+			name = name[:pos]
+		}
+		names[name] = true
+	}
+
+	return names
+}
@@ -0,0 +1,193 @@
+package reload
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Налаштування для тестів, у програмі їх реєструють пакети config
+var (
+	testConfig  = flag.String("config", "", "")
+	testMin     = Int("testMin", 1, "")
+	testMax     = Int("testMax", 10, "")
+	testPeriod  = Duration("testPeriod", time.Minute, "")
+	testFixed   = flag.String("testFixed", "fixed", "")
+	testReloads = 0
+)
+
+// Налаштування, які можна змінити під час роботи, перевірка testMin <= testMax
+func testReloader() *Reloader {
+	r := New(map[string]bool{"testMin": true, "testMax": true, "testPeriod": true}, func() []string {
+		if testMin.Candidate() > testMax.Candidate() {
+			return []string{"testMin must not be greater than testMax"}
+		}
+		return nil
+	}, func(name, value string) string { return value })
+	r.OnReload(func() { testReloads++ })
+	return r
+}
+
+// Записати ini-файл налаштувань та встановити його в -config. Після тесту налаштування повертаються
+// до значень за замовчуванням
+func testIniFile(t *testing.T, lines ...string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "test.ini")
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	*testConfig = file
+	t.Cleanup(func() {
+		testMin.Set("1")
+		testMax.Set("10")
+		testPeriod.Set("1m")
+		*testFixed = "fixed"
+		testReloads = 0
+	})
+}
+
+func testValues(t *testing.T, min, max int, period time.Duration) {
+	t.Helper()
+	if testMin.Get() != min || testMax.Get() != max || testPeriod.Get() != period {
+		t.Errorf("values = %v, %v, %v, want %v, %v, %v", testMin.Get(), testMax.Get(), testPeriod.Get(),
+			min, max, period)
+	}
+	if testMin.Candidate() != testMin.Get() || testMax.Candidate() != testMax.Get() {
+		t.Error("candidate is left after reload")
+	}
+}
+
+func TestReload(t *testing.T) {
+	testIniFile(t, "testMin = 2", "testMax = 10", "testPeriod = 60s", "testFixed = fixed")
+
+	changed, err := testReloader().Reload(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 60s та 1m0s - однакові значення
+	if len(changed) != 1 || changed[0] != "testMin: 1 -> 2" {
+		t.Errorf("changed = %v", changed)
+	}
+	testValues(t, 2, 10, time.Minute)
+	if testReloads != 1 {
+		t.Errorf("OnReload is called %v times", testReloads)
+	}
+}
+
+func TestReloadNothingChanged(t *testing.T) {
+	testIniFile(t, "testMin = 1")
+
+	changed, err := testReloader().Reload(nil)
+	if err != nil || len(changed) != 0 {
+		t.Errorf("changed = %v, err = %v", changed, err)
+	}
+	if testReloads != 0 {
+		t.Errorf("OnReload is called %v times", testReloads)
+	}
+}
+
+func TestReloadRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+	}{
+		{"invalid value", []string{"testMin = 2", "testMax = ten"}},
+		{"invalid duration", []string{"testMin = 2", "testPeriod = 5"}},
+		{"not reloadable", []string{"testMin = 2", "testFixed = other"}},
+		{"unknown setting", []string{"testMin = 2", "testUnknown = 1"}},
+		{"cross-field validation", []string{"testMin = 20", "testMax = 15", "testPeriod = 2m"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testIniFile(t, tt.lines...)
+
+			if _, err := testReloader().Reload(nil); err == nil {
+				t.Fatal("no error")
+			}
+			// не застосовується жодна зміна, в тому числі коректні
+			testValues(t, 1, 10, time.Minute)
+			if *testFixed != "fixed" || testReloads != 0 {
+				t.Errorf("testFixed = %v, reloads = %v", *testFixed, testReloads)
+			}
+		})
+	}
+}
+
+func TestReloadSkipsFixed(t *testing.T) {
+	testIniFile(t, "testMin = 2", "testMax = 3", "testPeriod = 2m")
+
+	args := os.Args
+	os.Args = []string{args[0], "-testMax=5", "--testPeriod", "5m"}
+	defer func() { os.Args = args }()
+	testMax.Set("5")
+
+	// testMin задано змінною оточення, testMax та testPeriod - в командному рядку
+	changed, err := testReloader().Reload(map[string]bool{"testMin": true})
+	if err != nil || len(changed) != 0 {
+		t.Errorf("changed = %v, err = %v", changed, err)
+	}
+	testValues(t, 1, 5, time.Minute)
+}
+
+func TestReloadDeleted(t *testing.T) {
+	testIniFile(t, "testMin = 2", "testPeriod = 2m")
+	if _, err := testReloader().Reload(nil); err != nil {
+		t.Fatal(err)
+	}
+	testValues(t, 2, 10, time.Minute*2)
+
+	// налаштування, видалені з ini-файлу, повертаються до значень за замовчуванням
+	testIniFile(t, "testMax = 10")
+	changed, err := testReloader().Reload(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 2 {
+		t.Errorf("changed = %v", changed)
+	}
+	testValues(t, 1, 10, time.Minute)
+}
+
+func TestReadIniFile(t *testing.T) {
+	testIniFile(t, "\ufeff# comment", "; comment", "[section]", "", "  name = value  ", `quoted = "a b"`, "empty =",
+		"url = https://example.com/?a=b")
+
+	values, err := ReadIniFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"name": "value", "quoted": "a b", "empty": "", "url": "https://example.com/?a=b"}
+	if len(values) != len(want) {
+		t.Errorf("values = %v, want %v", values, want)
+	}
+	for name, value := range want {
+		if values[name] != value {
+			t.Errorf("%v = %q, want %q", name, values[name], value)
+		}
+	}
+
+	testIniFile(t, "name value")
+	if _, err := ReadIniFile(); err == nil {
+		t.Error("line without = is accepted")
+	}
+}
+
+func TestCommandLineFlags(t *testing.T) {
+	args := os.Args
+	os.Args = []string{args[0], "-a=1", "--b", "2", "-c", "value", "-d=x=y"}
+	defer func() { os.Args = args }()
+
+	names := CommandLineFlags()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if !names[name] {
+			t.Errorf("%v is not found in %v", name, names)
+		}
+	}
+	if names["2"] || names["value"] || len(names) != 4 {
+		t.Errorf("names = %v", names)
+	}
+}
@@ -0,0 +1,146 @@
+package reload
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Налаштування, яке можна змінити під час роботи. Значення замінюється атомарно, тому горутини, які читають
+// його через Get, бачать або старе, або нове значення і не потребують блокувань
+type Value[T any] struct {
+	value  atomic.Pointer[T]
+	parse  func(string) (T, error)
+	format func(T) string
+
+	// Нове значення, яке перевіряється під час перечитування налаштувань (див. Reloader.Reload),
+	// змінюється тільки під блокуванням Reloader
+	candidate *T
+}
+
+func newValue[T any](name string, value T, usage string, parse func(string) (T, error), format func(T) string) *Value[T] {
+	v := &Value[T]{parse: parse, format: format}
+	v.value.Store(&value)
+	flag.Var(v, name, usage)
+	return v
+}
+
+// Поточне значення налаштування
+func (v *Value[T]) Get() T {
+	return *v.value.Load()
+}
+
+// Значення для правил перевірки налаштувань: під час перечитування - нове значення, яке ще не застосоване,
+// інакше поточне
+func (v *Value[T]) Candidate() T {
+	if v.candidate != nil {
+		return *v.candidate
+	}
+	return v.Get()
+}
+
+// Запропонувати нове значення з рядка, поточне значення не змінюється
+func (v *Value[T]) propose(s string) error {
+	value, err := v.parse(s)
+	if err != nil {
+		return err
+	}
+	v.candidate = &value
+	return nil
+}
+
+// Застосувати запропоноване значення (apply = true) або відкинути його
+func (v *Value[T]) settle(apply bool) {
+	if apply && v.candidate != nil {
+		v.value.Store(v.candidate)
+	}
+	v.candidate = nil
+}
+
+// Встановити значення з рядка (flag.Value)
+func (v *Value[T]) Set(s string) error {
+	value, err := v.parse(s)
+	if err != nil {
+		return err
+	}
+	v.value.Store(&value)
+	return nil
+}
+
+// Значення у вигляді рядка (flag.Value). Пакет flag викликає String і для нульового Value
+func (v *Value[T]) String() string {
+	if v.format == nil {
+		return ""
+	}
+	return v.format(v.Get())
+}
+
+// Нормалізоване значення з рядка (наприклад 60s та 1m0s - однакові), поточне значення не змінюється
+func (v *Value[T]) normalize(s string) (string, error) {
+	value, err := v.parse(s)
+	if err != nil {
+		return "", err
+	}
+	return v.format(value), nil
+}
+
+// Bool - булеве значення, яке можна задати без аргументу (-name замість -name=true)
+func (v *Value[T]) IsBoolFlag() bool {
+	_, ok := any(v.value.Load()).(*bool)
+	return ok
+}
+
+func String(name string, value string, usage string) *Value[string] {
+	return newValue(name, value, usage, func(s string) (string, error) { return s, nil },
+		func(v string) string { return v })
+}
+
+func Bool(name string, value bool, usage string) *Value[bool] {
+	return newValue(name, value, usage, strconv.ParseBool, strconv.FormatBool)
+}
+
+func Int(name string, value int, usage string) *Value[int] {
+	return newValue(name, value, usage, func(s string) (int, error) {
+		v, err := strconv.ParseInt(s, 0, strconv.IntSize)
+		return int(v), err
+	}, strconv.Itoa)
+}
+
+func Int64(name string, value int64, usage string) *Value[int64] {
+	return newValue(name, value, usage, func(s string) (int64, error) { return strconv.ParseInt(s, 0, 64) },
+		func(v int64) string { return strconv.FormatInt(v, 10) })
+}
+
+func Duration(name string, value time.Duration, usage string) *Value[time.Duration] {
+	return newValue(name, value, usage, time.ParseDuration, time.Duration.String)
+}
+
+// Нормалізоване значення налаштування f з рядка, поточне значення не змінюється. Для звичайних налаштувань
+// пакета flag рядок встановлюється в новий екземпляр значення того ж типу
+func normalize(f *flag.Flag, s string) (string, error) {
+	if v, ok := f.Value.(interface{ normalize(string) (string, error) }); ok {
+		return v.normalize(s)
+	}
+
+	t := reflect.TypeOf(f.Value)
+	if t.Kind() != reflect.Pointer {
+		return "", fmt.Errorf("setting %v cannot be normalized", f.Name)
+	}
+	value, ok := reflect.New(t.Elem()).Interface().(flag.Value)
+	if !ok {
+		return "", fmt.Errorf("setting %v cannot be normalized", f.Name)
+	}
+	if err := value.Set(s); err != nil {
+		return "", err
+	}
+	return value.String(), nil
+}
+
+// Функція читання налаштування пакета flag, яке не змінюється під час роботи, для правил перевірки, спільних
+// для таких налаштувань та Value (Value.Candidate)
+func Fixed[T any](p *T) func() T {
+	return func() T { return *p }
+}
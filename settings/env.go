@@ -0,0 +1,69 @@
+package settings
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/AleksandrKuts/youtubemeter-service/reload"
+)
+
+// Префікс змінних оточення для налаштувань: YTM_<НАЗВА НАЛАШТУВАННЯ У ВЕРХНЬОМУ РЕГІСТРІ>,
+// наприклад YTM_DBPASSWD, YTM_PERIODMETRIC
+const ENV_PREFIX = "YTM_"
+
+// Застосувати змінні оточення YTM_*. Налаштування задані в командному рядку мають вищий пріоритет.
+// Застосовані налаштування додаються в fixed: вони мають пріоритет над ini-файлом, тому не перечитуються
+// (див. reload.Reloader.Reload)
+func ApplyEnv(fixed map[string]bool) []string {
+	errs := []string{}
+	fromCommandLine := reload.CommandLineFlags()
+
+	flag.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(ENV_PREFIX + strings.ToUpper(f.Name))
+		if !ok || fromCommandLine[f.Name] {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid value of %v%v: %v", ENV_PREFIX, strings.ToUpper(f.Name), err))
+			return
+		}
+		fixed[f.Name] = true
+	})
+
+	return errs
+}
+
+// Всі налаштування у форматі ini-файлу, крім команд skip (config, print-config тощо). Значення виводяться
+// через display, щоб приховати секретні
+func FormatConfig(display func(name, value string) string, skip ...string) string {
+	commands := make(map[string]bool)
+	for _, name := range skip {
+		commands[name] = true
+	}
+
+	lines := []string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		if commands[f.Name] {
+			return
+		}
+		lines = append(lines, fmt.Sprintf("%v = %v", f.Name, display(f.Name, f.Value.String())))
+	})
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n")
+}
+
+// Завершити роботу з описом помилок налаштування. Логер ще не створений, тому пишемо в stderr
+func ExitOnErrors(errs []string) {
+	if len(errs) == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "invalid configuration:")
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, "  - "+Redact(err))
+	}
+	os.Exit(2)
+}
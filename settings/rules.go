@@ -0,0 +1,145 @@
+// Спільні для бекенда та колектора правила перевірки налаштувань, змінні оточення YTM_* та приховування
+// секретних значень у логах і виводі налаштувань. Пакети config програм містять тільки свої налаштування
+// та переліки правил
+package settings
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AleksandrKuts/youtubemeter-service/reload"
+)
+
+// Правило перевірки налаштувань, повертає опис помилки або пустий рядок. Значення читаються через функції:
+// reload.Value.Candidate для налаштувань, які можна змінити під час роботи, reload.Fixed - для інших
+type Rule func() string
+
+// Перевірити всі налаштування, повертає перелік помилок
+func Validate(rules []Rule) []string {
+	errs := []string{}
+	for _, r := range rules {
+		if err := r(); err != "" {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func NotEmpty(name string, value func() string) Rule {
+	return func() string {
+		if strings.TrimSpace(value()) == "" {
+			return name + " must not be empty"
+		}
+		return ""
+	}
+}
+
+func OneOf(name string, value func() string, allowed ...string) Rule {
+	return func() string {
+		for _, a := range allowed {
+			if value() == a {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%v must be one of [%v], got %q", name, strings.Join(allowed, ", "), value())
+	}
+}
+
+func PositiveDuration(name string, value func() time.Duration) Rule {
+	return func() string {
+		if value() <= 0 {
+			return fmt.Sprintf("%v must be greater than 0, got %v", name, value())
+		}
+		return ""
+	}
+}
+
+func NonNegativeDuration(name string, value func() time.Duration) Rule {
+	return func() string {
+		if value() < 0 {
+			return fmt.Sprintf("%v must not be negative, got %v", name, value())
+		}
+		return ""
+	}
+}
+
+func IntBetween(name string, value func() int, min, max int) Rule {
+	return func() string {
+		if value() < min || value() > max {
+			return fmt.Sprintf("%v must be between %v and %v, got %v", name, min, max, value())
+		}
+		return ""
+	}
+}
+
+func Int64Between(name string, value func() int64, min, max int64) Rule {
+	return func() string {
+		if value() < min || value() > max {
+			return fmt.Sprintf("%v must be between %v and %v, got %v", name, min, max, value())
+		}
+		return ""
+	}
+}
+
+func IntNotGreater(name string, value func() int, maxName string, max func() int) Rule {
+	return func() string {
+		if value() > max() {
+			return fmt.Sprintf("%v must not be greater than %v (%v), got %v", name, maxName, max(), value())
+		}
+		return ""
+	}
+}
+
+// Кількість кроків step в періоді value має бути меншою за max
+func StepsLess(name string, value func() time.Duration, stepName string, step func() time.Duration, max int64) Rule {
+	return func() string {
+		if step() > 0 && int64(value()/step()) >= max {
+			return fmt.Sprintf("%v / %v must be less than %v, got %v / %v", name, stepName, max, value(), step())
+		}
+		return ""
+	}
+}
+
+func Port(name string, value func() string) Rule {
+	return func() string {
+		p, err := strconv.Atoi(value())
+		if err != nil || p < 1 || p > 65535 {
+			return fmt.Sprintf("%v must be a port number between 1 and 65535, got %q", name, value())
+		}
+		return ""
+	}
+}
+
+func HostPort(name string, value func() string) Rule {
+	return func() string {
+		_, p, err := net.SplitHostPort(value())
+		if err != nil {
+			return fmt.Sprintf("%v must be in form host:port, got %q: %v", name, value(), err)
+		}
+		return Port(name+" port", reload.Fixed(&p))()
+	}
+}
+
+func AbsoluteURL(name string, value func() string) Rule {
+	return func() string {
+		u, err := url.Parse(value())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Sprintf("%v must be an absolute http(s) URL, got %q", name, value())
+		}
+		return ""
+	}
+}
+
+// Правило перевіряється тільки якщо увімкнена відповідна функціональність
+func IfEnabled(enabled func() bool, r Rule) Rule {
+	return func() string {
+		if !enabled() {
+			return ""
+		}
+		return r()
+	}
+}
@@ -0,0 +1,57 @@
+package settings
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AleksandrKuts/youtubemeter-service/reload"
+)
+
+func TestRules(t *testing.T) {
+	str := func(s string) func() string { return reload.Fixed(&s) }
+	duration := func(d time.Duration) func() time.Duration { return reload.Fixed(&d) }
+	integer := func(i int) func() int { return reload.Fixed(&i) }
+	enabled := func(b bool) func() bool { return reload.Fixed(&b) }
+
+	tests := []struct {
+		name  string
+		rule  Rule
+		valid bool
+	}{
+		{"not empty", NotEmpty("a", str("x")), true},
+		{"empty", NotEmpty("a", str(" ")), false},
+		{"one of", OneOf("a", str("up"), "", "up"), true},
+		{"not one of", OneOf("a", str("left"), "", "up"), false},
+		{"positive duration", PositiveDuration("a", duration(time.Second)), true},
+		{"zero duration", PositiveDuration("a", duration(0)), false},
+		{"zero non-negative duration", NonNegativeDuration("a", duration(0)), true},
+		{"negative duration", NonNegativeDuration("a", duration(-time.Second)), false},
+		{"int between", IntBetween("a", integer(10), 1, 10), true},
+		{"int out of range", IntBetween("a", integer(11), 1, 10), false},
+		{"int not greater", IntNotGreater("a", integer(10), "b", integer(10)), true},
+		{"int greater", IntNotGreater("a", integer(11), "b", integer(10)), false},
+		{"steps less", StepsLess("a", duration(time.Hour), "b", duration(time.Minute), 61), true},
+		{"too many steps", StepsLess("a", duration(time.Hour), "b", duration(time.Minute), 60), false},
+		{"port", Port("a", str("5432")), true},
+		{"invalid port", Port("a", str("65536")), false},
+		{"host and port", HostPort("a", str("0.0.0.0:3000")), true},
+		{"no port", HostPort("a", str("0.0.0.0")), false},
+		{"absolute url", AbsoluteURL("a", str("https://example.com/websub")), true},
+		{"relative url", AbsoluteURL("a", str("/websub")), false},
+		{"disabled", IfEnabled(enabled(false), NotEmpty("a", str(""))), true},
+		{"enabled", IfEnabled(enabled(true), NotEmpty("a", str(""))), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule(); (err == "") != tt.valid {
+				t.Errorf("rule error = %q, valid %v", err, tt.valid)
+			}
+		})
+	}
+
+	errs := Validate([]Rule{NotEmpty("a", str("")), NotEmpty("b", str("x")), Port("c", str("0"))})
+	if len(errs) != 2 {
+		t.Errorf("Validate = %v, want 2 errors", errs)
+	}
+}
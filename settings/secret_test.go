@@ -0,0 +1,110 @@
+package settings
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"key/value", "host=db port=5432 dbname=ytm user=ytm password=secret sslmode=disable",
+			"host=db port=5432 dbname=ytm user=ytm password=****** sslmode=disable"},
+		{"key/value at the end", "connStr=host=db password=secret", "connStr=host=db password=******"},
+		{"key/value with spaces around =", "user=ytm password = secret sslmode=disable",
+			"user=ytm password=****** sslmode=disable"},
+		{"key/value quoted", `user=ytm password='a b\'c\\' sslmode=disable`, "user=ytm password=****** sslmode=disable"},
+		{"key/value quoted by QuoteDSN", "user=ytm password=" + QuoteDSN(`it's a \secret`) + " sslmode=disable",
+			"user=ytm password=****** sslmode=disable"},
+		{"key/value several", "password=one, password=two", "password=****** password=******"},
+		{"key/value in url query", "postgres://db/ytm?password=secret", "postgres://db/ytm?password=******"},
+		{"url", "postgres://ytm:secret@db:5432/ytm?sslmode=disable",
+			"postgres://ytm:******@db:5432/ytm?sslmode=disable"},
+		{"url postgresql", "dial postgresql://ytm:secret@db/ytm: refused", "dial postgresql://ytm:******@db/ytm: refused"},
+		{"url with encoded password", "postgres://ytm:p%40ss%2Fw@db/ytm", "postgres://ytm:******@db/ytm"},
+		{"url with @ in password", "postgres://ytm:p@ss@db/ytm", "postgres://ytm:******@db/ytm"},
+		{"url with empty password", "postgres://ytm:@db/ytm", "postgres://ytm:******@db/ytm"},
+		{"url without user", "postgres://:secret@db/ytm", "postgres://:******@db/ytm"},
+		{"url without password", "postgres://ytm@db:5432/ytm", "postgres://ytm@db:5432/ytm"},
+		{"url without user info", "postgres://db:5432/ytm?x=a@b", "postgres://db:5432/ytm?x=a@b"},
+		{"no password", "cannot connect to db:5432", "cannot connect to db:5432"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.s); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteDSN(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"secret", "secret"},
+		{"", "''"},
+		{"a b", "'a b'"},
+		{"it's", `'it\'s'`},
+		{`a\b`, `'a\\b'`},
+	}
+
+	for _, tt := range tests {
+		if got := QuoteDSN(tt.value); got != tt.want {
+			t.Errorf("QuoteDSN(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	secrets := map[string]bool{"dbpasswd": true}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"dbpasswd", "secret", REDACTED},
+		{"dbpasswd", "", ""},
+		{"dbhost", "db", "db"},
+		{"dsn", "postgres://ytm:secret@db/ytm", "postgres://ytm:******@db/ytm"},
+	}
+
+	for _, tt := range tests {
+		if got := Display(secrets, tt.name, tt.value); got != tt.want {
+			t.Errorf("Display(%q, %q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRedactCore(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(RedactCore{Core: core}).Sugar()
+
+	logger.With("dsn", "host=db password=one").Infof("open %v", "postgres://ytm:two@db/ytm")
+	logger.Infow("connect", "dsn", "password=three", "port", 5432)
+
+	for _, entry := range logs.All() {
+		text := entry.Message
+		for _, field := range entry.Context {
+			text += " " + field.String
+		}
+		for _, secret := range []string{"one", "two", "three"} {
+			if strings.Contains(text, secret) {
+				t.Errorf("secret %q is logged: %v", secret, text)
+			}
+		}
+	}
+	if logs.Len() != 2 {
+		t.Errorf("logged %v entries, want 2", logs.Len())
+	}
+}